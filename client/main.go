@@ -1,27 +1,74 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"gophkeeper/internal/actions"
+	"gophkeeper/internal/config"
 	"gophkeeper/internal/storage"
+	"gophkeeper/internal/tracing"
 
 	"github.com/urfave/cli/v2"
 )
 
-func Init() storage.Storage {
-	storage.Init()
-	return storage.NewMemoryStorage()
+// shutdownTracing - flushes spans before exit
+var shutdownTracing = func(ctx context.Context) error { return nil }
+
+// Init loads the selected profile, sets up tracing and connects to the profile server.
+func Init(ctx *cli.Context) error {
+	shutdown, err := tracing.Setup(ctx.String("trace-exporter"), ctx.String("trace-file"), "gophkeeper-client")
+	if err != nil {
+		return err
+	}
+	shutdownTracing = shutdown
+	if err = actions.ParseOutput(ctx.String("output")); err != nil {
+		return err
+	}
+	profile, err := config.LoadProfile(ctx.String("profile"))
+	if err != nil {
+		return err
+	}
+	if ctx.IsSet("server") {
+		profile.Address = ctx.String("server")
+	}
+	if ctx.IsSet("timeout") {
+		profile.RequestTimeout = config.Duration(ctx.Duration("timeout"))
+	}
+	storage.Init(profile)
+	storage.SecondFactor = actions.SecondFactor(ctx)
+	return nil
+}
+
+// Finish flushes the spans of the command.
+func Finish(ctx *cli.Context) error {
+	return shutdownTracing(context.Background())
 }
 func main() {
-	store := Init()
+	store := storage.NewMemoryStorage()
 
 	app := cli.NewApp()
 	app.Name = "password keeper"
 	app.Usage = "keeps your passwords"
 	app.Description = "GophKeeper представляет собой клиент-серверную систему, позволяющую пользователю надёжно и безопасно хранить логины, пароли, бинарные данные и прочую приватную информацию."
 	app.Action = actions.MainAction
+	app.Before = Init
+	app.After = Finish
+	// exit codes are chosen by main after the spans are flushed
+	app.ExitErrHandler = func(*cli.Context, error) {}
+	app.Flags = []cli.Flag{
+		&cli.StringFlag{Name: "profile", Aliases: []string{"p"}, Value: config.DefaultProfile, Usage: "client profile from profiles.json"},
+		&cli.StringFlag{Name: "server", Usage: "server address, overrides the profile"},
+		&cli.DurationFlag{Name: "timeout", Value: 10 * time.Second, Usage: "deadline of every request to the server, overrides the profile"},
+		&cli.StringFlag{Name: "trace-exporter", Value: tracing.ExporterNone, Usage: "trace exporter: none, stdout or file"},
+		&cli.StringFlag{Name: "trace-file", Value: "traces.json", Usage: "file of the file trace exporter"},
+		&cli.StringFlag{Name: "output", Value: actions.OutputText, Usage: "output format of get, list, search, sync, history and status: text, json, yaml or env"},
+		&cli.StringFlag{Name: "otp", Usage: "two-factor code or recovery code, asked when the account needs it and the flag is omitted"},
+	}
 
 	app.Commands = []*cli.Command{
 
@@ -30,11 +77,39 @@ func main() {
 		actions.AddData(store),
 		actions.Sync(store),
 		actions.DelData(store),
+		actions.TwoFactor(store),
+		actions.OTP(store),
+		actions.ChangePassword(store),
+		actions.DeleteAccount(store),
+		actions.ExportAccount(store),
+		actions.History(store),
+		actions.Restore(store),
+		actions.HistoryRetention(store),
+		actions.Trash(store),
+		actions.Devices(store),
+		actions.List(store),
+		actions.Search(store),
+		actions.EditData(store),
+		actions.Status(),
+		actions.Run(store),
+		actions.Inject(store),
+		actions.Unlock(store),
+		actions.Lock(store),
+		actions.GitCredential(store),
+		actions.SSHAgent(store),
+		actions.Generate(store),
+		actions.PasswordPolicy(store),
 	}
 
-	err := app.Run(os.Args)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err := app.RunContext(ctx, os.Args)
 	if err != nil {
-		log.Fatalln(err)
+		if err.Error() != "" {
+			log.Println(err)
+		}
+		stop()
+		os.Exit(actions.ExitCode(err))
 	}
 
 }
@@ -0,0 +1,5 @@
+BEGIN ;
+ALTER TABLE users
+    DROP COLUMN IF EXISTS failed_logins,
+    DROP COLUMN IF EXISTS locked_until;
+COMMIT ;
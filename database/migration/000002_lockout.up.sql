@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS failed_logins int NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS locked_until timestamp with time zone;
+COMMIT;
@@ -0,0 +1,12 @@
+BEGIN;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret varchar(255);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled bool NOT NULL DEFAULT false;
+-- counter of the last accepted code, every code is accepted once
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_counter bigint NOT NULL DEFAULT 0;
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    user_id int references users(id) ON DELETE CASCADE NOT NULL,
+    code_hash varchar(64) NOT NULL,
+    PRIMARY KEY (user_id, code_hash)
+    );
+COMMIT;
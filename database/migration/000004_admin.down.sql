@@ -0,0 +1,5 @@
+BEGIN;
+ALTER TABLE users
+    DROP COLUMN IF EXISTS disabled,
+    DROP COLUMN IF EXISTS sessions_revoked_at;
+COMMIT;
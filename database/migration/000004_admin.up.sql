@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS disabled bool NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS sessions_revoked_at timestamp with time zone;
+COMMIT;
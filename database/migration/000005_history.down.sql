@@ -0,0 +1,9 @@
+BEGIN;
+DROP TRIGGER IF EXISTS keeper_history_trigger ON keeper;
+DROP FUNCTION IF EXISTS keeper_save_history();
+DROP TABLE IF EXISTS keeper_history;
+ALTER TABLE keeper ALTER COLUMN meta_info TYPE varchar(255);
+ALTER TABLE keeper ALTER COLUMN data_info TYPE varchar(255);
+ALTER TABLE keeper DROP COLUMN IF EXISTS revision;
+ALTER TABLE users DROP COLUMN IF EXISTS history_retention;
+COMMIT;
@@ -0,0 +1,39 @@
+BEGIN;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS history_retention int NOT NULL DEFAULT 10;
+ALTER TABLE keeper ADD COLUMN IF NOT EXISTS revision int NOT NULL DEFAULT 1;
+-- encrypted records grow by about a third, ssh keys and structured meta info do not fit in varchar(255),
+-- the revisions of keeper_history are stored the same way
+ALTER TABLE keeper ALTER COLUMN data_info TYPE text;
+ALTER TABLE keeper ALTER COLUMN meta_info TYPE text;
+CREATE TABLE IF NOT EXISTS keeper_history (
+    id SERIAL PRIMARY KEY,
+    user_id int references users(id) ON DELETE CASCADE NOT NULL,
+    data_id varchar(255) NOT NULL,
+    revision int NOT NULL,
+    data_info text NOT NULL,
+    meta_info text,
+    changed_at timestamp with time zone,
+    UNIQUE (user_id, data_id, revision)
+    );
+
+-- every update of a record gets the next revision, the replaced content is kept
+-- in keeper_history trimmed to the history_retention of the user
+CREATE OR REPLACE FUNCTION keeper_save_history() RETURNS trigger AS $$
+BEGIN
+    NEW.revision := OLD.revision + 1;
+    IF OLD.data_info IS DISTINCT FROM NEW.data_info OR OLD.meta_info IS DISTINCT FROM NEW.meta_info THEN
+        INSERT INTO keeper_history (user_id, data_id, revision, data_info, meta_info, changed_at)
+        VALUES (OLD.user_id, OLD.data_id, OLD.revision, OLD.data_info, OLD.meta_info, OLD.changed_at);
+    END IF;
+    DELETE FROM keeper_history h USING users u
+    WHERE u.id = OLD.user_id AND h.user_id = OLD.user_id AND h.data_id = OLD.data_id
+      AND h.revision <= OLD.revision - u.history_retention;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS keeper_history_trigger ON keeper;
+CREATE TRIGGER keeper_history_trigger BEFORE UPDATE ON keeper
+    FOR EACH ROW EXECUTE FUNCTION keeper_save_history();
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+DROP TABLE IF EXISTS devices;
+ALTER TABLE keeper DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE keeper DROP COLUMN IF EXISTS purge_requested;
+COMMIT;
@@ -0,0 +1,13 @@
+BEGIN;
+
+ALTER TABLE keeper ADD COLUMN IF NOT EXISTS purge_requested bool NOT NULL DEFAULT false;
+-- deletion time on the server clock, client clocks set changed_at and can not be compared with device acknowledgements
+ALTER TABLE keeper ADD COLUMN IF NOT EXISTS deleted_at timestamp with time zone;
+UPDATE keeper SET deleted_at = now() WHERE deleted AND deleted_at IS NULL;
+CREATE TABLE IF NOT EXISTS devices (
+    user_id int references users(id) ON DELETE CASCADE NOT NULL,
+    device_id varchar(64) NOT NULL,
+    acked_at timestamp with time zone NOT NULL,
+    PRIMARY KEY (user_id, device_id)
+    );
+COMMIT;
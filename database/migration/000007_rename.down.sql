@@ -0,0 +1,16 @@
+BEGIN;
+
+CREATE OR REPLACE FUNCTION keeper_save_history() RETURNS trigger AS $$
+BEGIN
+    NEW.revision := OLD.revision + 1;
+    IF OLD.data_info IS DISTINCT FROM NEW.data_info OR OLD.meta_info IS DISTINCT FROM NEW.meta_info THEN
+        INSERT INTO keeper_history (user_id, data_id, revision, data_info, meta_info, changed_at)
+        VALUES (OLD.user_id, OLD.data_id, OLD.revision, OLD.data_info, OLD.meta_info, OLD.changed_at);
+    END IF;
+    DELETE FROM keeper_history h USING users u
+    WHERE u.id = OLD.user_id AND h.user_id = OLD.user_id AND h.data_id = OLD.data_id
+      AND h.revision <= OLD.revision - u.history_retention;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+COMMIT;
@@ -0,0 +1,12 @@
+BEGIN;
+
+-- settings of a folder of the vault, the empty folder holds the defaults of the whole vault
+-- folders are part of the encrypted metadata: the settings are keyed by a hash of the folder that keeps it encrypted
+CREATE TABLE IF NOT EXISTS vault_settings (
+    user_id int references users(id) ON DELETE CASCADE NOT NULL,
+    folder_hash varchar(64) NOT NULL,
+    folder text NOT NULL,
+    password_policy jsonb NOT NULL,
+    PRIMARY KEY (user_id, folder_hash)
+    );
+COMMIT;
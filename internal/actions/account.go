@@ -0,0 +1,136 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"gophkeeper/internal/apperrors"
+	"gophkeeper/internal/datamodels"
+	"gophkeeper/internal/storage"
+
+	"github.com/urfave/cli/v2"
+)
+
+func changePassword(store storage.ClientStorage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 3 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		login := ctx.Args().Get(0)
+		oldPassword := ctx.Args().Get(1)
+		newPassword := ctx.Args().Get(2)
+		_, err := store.Login(ctx.Context, login, oldPassword)
+		if err != nil {
+			return fmt.Errorf("error login happend: %w", explain(err))
+		}
+		err = store.ChangePassword(ctx.Context, login, oldPassword, newPassword)
+		if err != nil {
+			return fmt.Errorf("error change password happend: %w", explain(err))
+		}
+		fmt.Println("password changed, other sessions are logged out")
+		return nil
+	}
+}
+
+// ChangePassword - used to change the password of the account
+func ChangePassword(store storage.ClientStorage) *cli.Command {
+	return &cli.Command{
+		Name:    "change-password",
+		Usage:   "used to change the password; you need to enter login, old and new password; example: go run main.go passwd login oldPassword newPassword",
+		Aliases: []string{"passwd"},
+		Action:  traced("passwd", changePassword(store)),
+	}
+}
+
+func deleteAccount(store storage.ClientStorage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 2 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		login := ctx.Args().Get(0)
+		password := ctx.Args().Get(1)
+		if !ctx.Bool("yes") {
+			answer, err := prompt(ctx, "all records of "+login+" will be deleted on the server and locally; type the login to confirm: ")
+			if err != nil {
+				return err
+			}
+			if answer != login {
+				return fmt.Errorf("account deletion canceled")
+			}
+		}
+		_, err := store.Login(ctx.Context, login, password)
+		if err != nil {
+			return fmt.Errorf("error login happend: %w", explain(err))
+		}
+		// the login used up its code, every code is accepted once, so the deletion is confirmed by the next one
+		err = store.DeleteAccount(ctx.Context, login, password, ctx.String("code"))
+		if apperrors.Reason(err) == "OTP_REQUIRED" {
+			c, pErr := prompt(ctx, "next code from the authenticator app, the login used the current one, or recovery code: ")
+			if pErr != nil {
+				return pErr
+			}
+			err = store.DeleteAccount(ctx.Context, login, password, c)
+		}
+		if err != nil {
+			return fmt.Errorf("error delete account happend: %w", explain(err))
+		}
+		fmt.Println("account deleted")
+		return nil
+	}
+}
+
+// DeleteAccount - used to delete the account with all records
+func DeleteAccount(store storage.ClientStorage) *cli.Command {
+	return &cli.Command{
+		Name:  "delete-account",
+		Usage: "used to delete the account with all records; you need to enter login and password, with two-factor authentication a code other than the one of the login is asked or given with --code; example: go run main.go delete-account login password",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{Name: "yes", Usage: "do not ask for confirmation"},
+			&cli.StringFlag{Name: "code", Usage: "two-factor code or recovery code confirming the deletion, not the one used for the login"},
+		},
+		Action: traced("delete-account", deleteAccount(store)),
+	}
+}
+
+func exportAccount(store storage.ClientStorage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 2 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		id, err := store.Login(ctx.Context, ctx.Args().Get(0), ctx.Args().Get(1))
+		if err != nil {
+			return fmt.Errorf("error login happend: %w", explain(err))
+		}
+		out := ctx.App.Writer
+		if name := ctx.String("file"); name != "" {
+			file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+			if err != nil {
+				return fmt.Errorf("error export happend: %w", err)
+			}
+			defer file.Close()
+			out = file
+		}
+		encoder := json.NewEncoder(out)
+		err = store.ExportAccount(ctx.Context, func(data datamodels.Data) error {
+			data.UserID = id
+			return encoder.Encode(data)
+		})
+		if err != nil {
+			return fmt.Errorf("error export happend: %w", explain(err))
+		}
+		return nil
+	}
+}
+
+// ExportAccount - used to export every record kept by the server
+func ExportAccount(store storage.ClientStorage) *cli.Command {
+	return &cli.Command{
+		Name:  "export",
+		Usage: "used to export every record kept by the server, including deleted ones, as JSON lines; you need to enter login and password; example: go run main.go export -o vault.json login password",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "file", Aliases: []string{"o"}, Usage: "file to write, created with 0600 permissions; stdout when omitted"},
+		},
+		Action: traced("export", exportAccount(store)),
+	}
+}
@@ -3,9 +3,13 @@ package actions
 import (
 	"fmt"
 	"gophkeeper/internal/datamodels"
+	"gophkeeper/internal/records"
 	"gophkeeper/internal/storage"
+	"gophkeeper/internal/tracing"
+	"io"
 
 	"github.com/urfave/cli/v2"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func auth(store storage.Storage) func(ctx *cli.Context) error {
@@ -19,9 +23,9 @@ func auth(store storage.Storage) func(ctx *cli.Context) error {
 		}
 		login := ctx.Args().Get(0)
 		password := ctx.Args().Get(1)
-		err := store.Auth(login, password)
+		err := store.Auth(ctx.Context, login, password)
 		if err != nil {
-			return fmt.Errorf("error happend: %w", err)
+			return fmt.Errorf("error happend: %w", explain(err))
 		}
 		fmt.Println("successful auth")
 		return nil
@@ -35,47 +39,88 @@ func Auth(store storage.Storage) *cli.Command {
 		Usage: "used to authenticate new users; you need to enter login and password; example: go run main.go auth login password",
 
 		Aliases: []string{"a", "auth"},
-		Action:  auth(store),
+		Action:  traced("auth", auth(store)),
 	}
 }
 
-func addData(store storage.Storage) func(ctx *cli.Context) error {
+func addData(store storage.ClientStorage) func(ctx *cli.Context) error {
 	return func(ctx *cli.Context) error {
 		n := ctx.NArg()
 		if n == 0 {
 			return fmt.Errorf("no argument provided for auth")
 		}
-		if n < 4 {
+		kind := ctx.String("kind")
+		// the generated text secret takes the place of the data argument
+		args := ctx.Args().Slice()
+		if ctx.Bool("generate") && kind == string(records.KindText) && n >= 3 {
+			args = append(args[:3:3], append([]string{""}, args[3:]...)...)
+		}
+		if len(args) < 4 {
 			return fmt.Errorf("not enough arguments provided for auth")
 		}
-		login := ctx.Args().Get(0)
-		password := ctx.Args().Get(1)
+		login := args[0]
+		password := args[1]
 
-		id, err := store.Login(login, password)
+		id, err := store.Login(ctx.Context, login, password)
 		if err != nil {
-			return fmt.Errorf("error login happend: %w", err)
+			return fmt.Errorf("error login happend: %w", explain(err))
 		}
 		var data datamodels.Data
-		data.DataID = ctx.Args().Get(2)
-		data.Data = ctx.Args().Get(3)
-		data.Metadata = ctx.Args().Get(4)
-		data.UserID = id
-		err = store.AddData(data)
+		data.DataID = args[2]
+		value, generated := args[3], ""
+		if ctx.Bool("generate") {
+			if kind != string(records.KindText) && kind != string(records.KindLogin) {
+				return fmt.Errorf("--generate needs a text or a login record")
+			}
+			if generated, err = generateSecret(ctx, store); err != nil {
+				return fmt.Errorf("error add happend: %w", err)
+			}
+			value = generated
+			if kind == string(records.KindLogin) {
+				value = args[3] + ":" + generated
+			}
+		}
+		if kind == string(records.KindSSHKey) {
+			data.Data, err = sshKeyData(ctx, value)
+		} else {
+			data.Data, err = encodeRecord(kind, value)
+		}
+		if err != nil {
+			return fmt.Errorf("error add happend: %w", err)
+		}
+		notes := ""
+		if len(args) > 4 {
+			notes = args[4]
+		}
+		data.Metadata, err = metaFromFlags(ctx, notes)
 		if err != nil {
 			return fmt.Errorf("error add happend: %w", err)
 		}
+		data.UserID = id
+		err = store.AddData(ctx.Context, data)
+		if err != nil {
+			return fmt.Errorf("error add happend: %w", explain(err))
+		}
 		fmt.Println("data added successfully")
+		if generated != "" {
+			fmt.Println("generated password: " + generated)
+		}
 		return nil
 	}
 }
 
 // AddData - used to add new data to keep it
-func AddData(store storage.Storage) *cli.Command {
+func AddData(store storage.ClientStorage) *cli.Command {
 	return &cli.Command{
 		Name:    "addData",
-		Usage:   "used to add new data to keep it; you need to enter login and password, then data name, data and meta information if needed; example: go run main.go add login password dataID data metaData",
+		Usage:   "used to add new data to keep it; you need to enter login and password, then data name, data and notes if needed; example: go run main.go add --tag work --folder work/servers --field user=admin login password dataID data notes; go run main.go add --kind login --generate --folder work login password github octocat",
 		Aliases: []string{"add"},
-		Action:  addData(store),
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{Name: "kind", Value: string(records.KindText), Usage: "kind of the record: text, otp (data is an otpauth:// uri), login (data is username:password) or ssh-key (data is the path of an OpenSSH private key)"},
+			&cli.StringFlag{Name: "passphrase", Usage: "passphrase of an encrypted ssh key, asked when omitted; the key is stored decrypted, the passphrase is not stored"},
+			&cli.BoolFlag{Name: "generate", Usage: "generate the password with the policy of --folder kept in the vault settings; data is omitted for text records and is the username for login records"},
+		}, metaFlags()...),
+		Action: traced("add", addData(store)),
 	}
 }
 func getData(store storage.Storage) func(ctx *cli.Context) error {
@@ -89,17 +134,19 @@ func getData(store storage.Storage) func(ctx *cli.Context) error {
 		}
 		login := ctx.Args().Get(0)
 		password := ctx.Args().Get(1)
-		id, err := store.Login(login, password)
+		id, err := store.Login(ctx.Context, login, password)
 		if err != nil {
-			return fmt.Errorf("error login happend: %w", err)
+			return fmt.Errorf("error login happend: %w", explain(err))
 		}
 		dataId := ctx.Args().Get(2)
-		data, err := store.GetData(dataId, id)
+		data, err := store.GetData(ctx.Context, dataId, id)
 		if err != nil {
-			return fmt.Errorf("error get happend: %w", err)
+			return fmt.Errorf("error get happend: %w", explain(err))
 		}
-		fmt.Println("DataID: " + data.DataID + " Data: " + data.Data + " Meta Info: " + data.Metadata)
-		return nil
+		data.DataID = dataId
+		return render(ctx, newRecordOutput(data, true), func(w io.Writer) {
+			fmt.Fprintln(w, "DataID: "+data.DataID+" Data: "+data.Data+" Meta Info: "+records.DecodeMeta(data.Metadata).String())
+		})
 	}
 }
 
@@ -109,7 +156,7 @@ func GetData(store storage.Storage) *cli.Command {
 		Name:    "get data",
 		Usage:   "used to get data ; you need to enter login and password, then data name; example: go run main.go get login password dataId",
 		Aliases: []string{"get", "g"},
-		Action:  getData(store),
+		Action:  traced("get", getData(store)),
 	}
 }
 func delData(store storage.Storage) func(ctx *cli.Context) error {
@@ -123,14 +170,14 @@ func delData(store storage.Storage) func(ctx *cli.Context) error {
 		}
 		login := ctx.Args().Get(0)
 		password := ctx.Args().Get(1)
-		id, err := store.Login(login, password)
+		id, err := store.Login(ctx.Context, login, password)
 		if err != nil {
-			return fmt.Errorf("error login happend: %w", err)
+			return fmt.Errorf("error login happend: %w", explain(err))
 		}
 		dataId := ctx.Args().Get(2)
-		err = store.DelData(dataId, id)
+		err = store.DelData(ctx.Context, dataId, id)
 		if err != nil {
-			return fmt.Errorf("error deletr happend: %w", err)
+			return fmt.Errorf("error deletr happend: %w", explain(err))
 		}
 		fmt.Println("data deleted successfully")
 		return nil
@@ -143,7 +190,7 @@ func DelData(store storage.Storage) *cli.Command {
 		Name:    "Delete data",
 		Usage:   "used to delete data; you need to enter login and password, then data name; example: go run main.go del login password dataId",
 		Aliases: []string{"del", "d"},
-		Action:  delData(store),
+		Action:  traced("del", delData(store)),
 	}
 }
 
@@ -158,23 +205,23 @@ func sync(store storage.Storage) func(ctx *cli.Context) error {
 		}
 		login := ctx.Args().Get(0)
 		password := ctx.Args().Get(1)
-		id, err := store.Login(login, password)
+		id, err := store.Login(ctx.Context, login, password)
 		if err != nil {
-			return fmt.Errorf("error login happend: %w", err)
+			return fmt.Errorf("error login happend: %w", explain(err))
 		}
-		err = store.ClientSync(id, nil)
+		err = store.ClientSync(ctx.Context, id, nil)
 		if err != nil {
-			return fmt.Errorf("error client sync happend: %w", err)
+			return fmt.Errorf("error client sync happend: %w", explain(err))
 		}
-		data, err := store.Sync(id)
+		data, err := store.Sync(ctx.Context, id)
 		if err != nil {
-			return fmt.Errorf("error sync happend: %w", err)
-		}
-		for _, v := range data {
-			fmt.Println("DataID: " + v.DataID + " Data: " + v.Data + " Meta Info: " + v.Metadata)
+			return fmt.Errorf("error sync happend: %w", explain(err))
 		}
-
-		return nil
+		return render(ctx, newRecordsOutput(data, true), func(w io.Writer) {
+			for _, v := range data {
+				fmt.Fprintln(w, "DataID: "+v.DataID+" Data: "+v.Data+" Meta Info: "+records.DecodeMeta(v.Metadata).String())
+			}
+		})
 	}
 }
 
@@ -184,7 +231,47 @@ func Sync(store storage.Storage) *cli.Command {
 		Name:    "synchronization",
 		Usage:   "used synchronize server and client; you need to enter login and password; example: go run main.go sync login password",
 		Aliases: []string{"sync", "s"},
-		Action:  sync(store),
+		Action:  traced("sync", sync(store)),
+	}
+}
+
+// encodeRecord - Data field of a record of the kind built from the cli value
+func encodeRecord(kind string, value string) (string, error) {
+	k, err := records.ParseKind(kind)
+	if err != nil {
+		return "", err
+	}
+	r := records.Record{Kind: k, Text: value}
+	switch k {
+	case records.KindOTP:
+		otp, err := records.NewOTP(value)
+		if err != nil {
+			return "", err
+		}
+		r = records.Record{Kind: k, OTP: &otp}
+	case records.KindLogin:
+		login, err := records.NewLogin(value)
+		if err != nil {
+			return "", err
+		}
+		r = records.Record{Kind: k, Login: &login}
+	case records.KindSSHKey:
+		key, err := records.NewSSHKey(value, "")
+		if err != nil {
+			return "", err
+		}
+		r = records.Record{Kind: k, SSHKey: &key}
+	}
+	return records.Encode(r)
+}
+
+// traced - runs the action within a span named after the command
+func traced(name string, action cli.ActionFunc) cli.ActionFunc {
+	return func(ctx *cli.Context) (err error) {
+		var span trace.Span
+		ctx.Context, span = tracing.Start(ctx.Context, "cli "+name)
+		defer func() { tracing.End(span, err) }()
+		return action(ctx)
 	}
 }
 
@@ -0,0 +1,72 @@
+package actions
+
+import (
+	"fmt"
+	"time"
+
+	"gophkeeper/internal/storage"
+	files "gophkeeper/internal/storage/filereaders"
+
+	"github.com/urfave/cli/v2"
+)
+
+func listDevices(store storage.ClientStorage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 2 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		if _, err := store.Login(ctx.Context, ctx.Args().Get(0), ctx.Args().Get(1)); err != nil {
+			return fmt.Errorf("error login happend: %w", explain(err))
+		}
+		devices, err := store.ListDevices(ctx.Context)
+		if err != nil {
+			return fmt.Errorf("error devices happend: %w", explain(err))
+		}
+		current, _ := files.DeviceID()
+		for _, v := range devices {
+			line := fmt.Sprintf("Device ID: %s Synchronized At: %s", v.ID, v.AckedAt.Format(time.RFC3339))
+			if v.ID == current {
+				line += " (this device)"
+			}
+			fmt.Println(line)
+		}
+		return nil
+	}
+}
+
+func removeDevice(store storage.ClientStorage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 3 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		if _, err := store.Login(ctx.Context, ctx.Args().Get(0), ctx.Args().Get(1)); err != nil {
+			return fmt.Errorf("error login happend: %w", explain(err))
+		}
+		device := ctx.Args().Get(2)
+		if err := store.RemoveDevice(ctx.Context, device); err != nil {
+			return fmt.Errorf("error devices remove happend: %w", explain(err))
+		}
+		fmt.Printf("%s removed, deleted records it did not synchronize can be purged\n", device)
+		return nil
+	}
+}
+
+// Devices - used to list and remove the devices the server waits for before purging deleted records
+func Devices(store storage.ClientStorage) *cli.Command {
+	return &cli.Command{
+		Name:  "devices",
+		Usage: "used to manage the devices of the account, deleted records are purged after every device synchronized them; example: go run main.go devices list login password",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "list",
+				Usage:  "list the devices and their last synchronization; example: go run main.go devices list login password",
+				Action: traced("devices list", listDevices(store)),
+			},
+			{
+				Name:   "remove",
+				Usage:  "forget a lost or retired device; example: go run main.go devices remove login password deviceId",
+				Action: traced("devices remove", removeDevice(store)),
+			},
+		},
+	}
+}
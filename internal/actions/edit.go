@@ -0,0 +1,150 @@
+package actions
+
+import (
+	"fmt"
+
+	"gophkeeper/internal/datamodels"
+	"gophkeeper/internal/records"
+	"gophkeeper/internal/storage"
+
+	"github.com/urfave/cli/v2"
+)
+
+// editRecord - applies the data flags to the record, reports whether it changed
+func editRecord(ctx *cli.Context, r *records.Record) (bool, error) {
+	changed := false
+	if ctx.IsSet("data") {
+		data, err := encodeRecord(string(r.Kind), ctx.String("data"))
+		if err != nil {
+			return false, err
+		}
+		*r = records.Decode(data)
+		changed = true
+	}
+	if ctx.IsSet("username") || ctx.IsSet("password") {
+		if r.Kind != records.KindLogin || r.Login == nil {
+			return false, fmt.Errorf("%w: --username and --password change login records", records.ErrWrongKind)
+		}
+		if ctx.IsSet("username") {
+			r.Login.Username = ctx.String("username")
+		}
+		if ctx.IsSet("password") {
+			r.Login.Password = ctx.String("password")
+		}
+		changed = true
+	}
+	return changed, nil
+}
+
+// editMeta - applies the meta information flags to the metadata, reports whether it changed
+// Tags and urls are replaced, fields are merged and a field with an empty value is removed.
+func editMeta(ctx *cli.Context, m *records.Meta) (bool, error) {
+	changed := false
+	if ctx.IsSet("tag") {
+		m.Tags, changed = ctx.StringSlice("tag"), true
+	}
+	if ctx.IsSet("folder") {
+		m.Folder, changed = ctx.String("folder"), true
+	}
+	if ctx.IsSet("url") {
+		m.URLs, changed = ctx.StringSlice("url"), true
+	}
+	if ctx.IsSet("notes") {
+		m.Notes, changed = ctx.String("notes"), true
+	}
+	if ctx.IsSet("favorite") {
+		m.Favorite, changed = ctx.Bool("favorite"), true
+	}
+	if ctx.IsSet("field") {
+		fields, err := records.ParseFields(ctx.StringSlice("field"))
+		if err != nil {
+			return false, err
+		}
+		if m.Fields == nil {
+			m.Fields = make(map[string]string, len(fields))
+		}
+		for k, v := range fields {
+			if v == "" {
+				delete(m.Fields, k)
+			} else {
+				m.Fields[k] = v
+			}
+		}
+		changed = true
+	}
+	return changed, nil
+}
+
+func editData(store storage.ClientStorage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 3 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		id, err := store.Login(ctx.Context, ctx.Args().Get(0), ctx.Args().Get(1))
+		if err != nil {
+			return fmt.Errorf("error login happend: %w", explain(err))
+		}
+		dataID := ctx.Args().Get(2)
+		current, err := store.GetData(ctx.Context, dataID, id)
+		if err != nil {
+			return fmt.Errorf("error edit happend: %w", explain(err))
+		}
+		update := datamodels.Data{UserID: id}
+		var mask []string
+
+		record := records.Decode(current.Data)
+		changed, err := editRecord(ctx, &record)
+		if err != nil {
+			return fmt.Errorf("error edit happend: %w", err)
+		}
+		if changed {
+			if update.Data, err = records.Encode(record); err != nil {
+				return fmt.Errorf("error edit happend: %w", err)
+			}
+			mask = append(mask, storage.MaskData)
+		}
+		meta := records.DecodeMeta(current.Metadata)
+		changed, err = editMeta(ctx, &meta)
+		if err != nil {
+			return fmt.Errorf("error edit happend: %w", err)
+		}
+		if changed {
+			update.Metadata = records.EncodeMeta(meta)
+			mask = append(mask, storage.MaskMetaInfo)
+		}
+		if ctx.IsSet("rename") {
+			update.DataID = ctx.String("rename")
+			mask = append(mask, storage.MaskDataID)
+		}
+		if len(mask) == 0 {
+			return fmt.Errorf("nothing to change: use --data, --username, --password, --rename or meta information flags")
+		}
+		revision := current.Revision
+		if ctx.IsSet("revision") {
+			revision = uint32(ctx.Uint("revision"))
+		}
+		updated, err := store.UpdateData(ctx.Context, dataID, update, mask, revision)
+		if err != nil {
+			return fmt.Errorf("error edit happend: %w", explain(err))
+		}
+		fmt.Printf("%s updated, revision %d\n", updated.DataID, updated.Revision)
+		return nil
+	}
+}
+
+// EditData - used to change some fields of a record
+func EditData(store storage.ClientStorage) *cli.Command {
+	return &cli.Command{
+		Name:  "edit",
+		Usage: "used to change only the given fields of a record, the server must be available; you need to enter login and password, then data name; example: go run main.go edit --password newSecret --tag work login password github",
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{Name: "data", Usage: "new data of the record, in the format of its kind"},
+			&cli.StringFlag{Name: "username", Usage: "new username of a login record"},
+			&cli.StringFlag{Name: "password", Usage: "new password of a login record"},
+			&cli.StringFlag{Name: "notes", Usage: "new notes"},
+			&cli.StringFlag{Name: "rename", Usage: "new data id, the history of the record is kept"},
+			&cli.UintFlag{Name: "revision", Usage: "revision the change is based on, by default the one read before the change; 0 overwrites any revision"},
+		}, metaFlags()...),
+		Action: traced("edit", editData(store)),
+	}
+}
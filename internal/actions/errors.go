@@ -0,0 +1,114 @@
+package actions
+
+import (
+	"errors"
+	"time"
+
+	"gophkeeper/internal/apperrors"
+
+	"github.com/urfave/cli/v2"
+)
+
+// hintError - error with a message that tells the user what to do next
+type hintError struct {
+	hint string
+	err  error
+}
+
+func (h *hintError) Error() string {
+	return h.hint
+}
+
+func (h *hintError) Unwrap() error {
+	return h.err
+}
+
+// explain - translates typed errors into actionable cli messages
+func explain(err error) error {
+	if err == nil {
+		return nil
+	}
+	hint := ""
+	reason := apperrors.Reason(err)
+	switch {
+	case errors.Is(err, apperrors.ErrUnauthenticated) && reason == "WRONG_PASSWORD":
+		hint = "wrong password; check the login and password and try again"
+	case errors.Is(err, apperrors.ErrUnauthenticated) && reason == "OTP_REQUIRED":
+		hint = "two-factor code required; pass it with --otp"
+	case errors.Is(err, apperrors.ErrUnauthenticated) && reason == "OTP_INVALID":
+		hint = "wrong two-factor code; use the current code of the authenticator app or an unused recovery code"
+	case errors.Is(err, apperrors.ErrConflict) && reason == "TOTP_ENABLED":
+		hint = "two-factor authentication is already enabled; disable it first to enroll another app"
+	case errors.Is(err, apperrors.ErrConflict) && reason == "TOTP_NOT_ENROLLED":
+		hint = "two-factor authentication is not enabled; enable it with: 2fa enable login password"
+	case errors.Is(err, apperrors.ErrUnauthenticated) && reason == "VAULT_LOCKED":
+		hint = "vault is locked; unlock it with: unlock login password"
+	case errors.Is(err, apperrors.ErrUnauthenticated):
+		hint = "session is not valid anymore; run the command again to log in"
+	case errors.Is(err, apperrors.ErrNotFound) && reason == "USER_NOT_FOUND":
+		hint = "user is not registered; create it with: auth login password"
+	case errors.Is(err, apperrors.ErrNotFound) && reason == "VERSION_NOT_FOUND":
+		hint = "no such version; list the kept versions with: history login password dataId"
+	case errors.Is(err, apperrors.ErrNotFound):
+		hint = "no data with this id; check the data id or run sync to fetch data from the server"
+	case errors.Is(err, apperrors.ErrConflict) && reason == "LOGIN_EXISTS":
+		hint = "login is already taken; choose another one"
+	case errors.Is(err, apperrors.ErrConflict) && reason == "REVISION_MISMATCH":
+		hint = "record was changed on another device; run get to see the current version and edit again"
+	case errors.Is(err, apperrors.ErrConflict) && reason == "DATA_EXISTS":
+		hint = "another record has this data id; choose another name or delete that record first"
+	case errors.Is(err, apperrors.ErrConflict):
+		hint = "data was changed by another client; run sync and try again"
+	case errors.Is(err, apperrors.ErrValidation):
+		hint = "invalid input: " + err.Error()
+	case errors.Is(err, apperrors.ErrPermission) && reason == "ACCOUNT_DISABLED":
+		hint = "account is disabled; contact the server operator"
+	case errors.Is(err, apperrors.ErrQuotaExceeded) && reason == "LOGIN_LOCKED":
+		hint = "too many failed login attempts; try again in " + retryDelay(err).String()
+	case errors.Is(err, apperrors.ErrQuotaExceeded):
+		hint = "limit exceeded: " + err.Error() + "; try again later"
+	case errors.Is(err, apperrors.ErrUnavailable):
+		hint = "server is unreachable; check --server or --profile, or try again later"
+	default:
+		return err
+	}
+	return &hintError{hint: hint, err: err}
+}
+
+// retryDelay - delay the server asked to wait before retrying, at least one second
+func retryDelay(err error) time.Duration {
+	var e *apperrors.Error
+	if errors.As(err, &e) && e.RetryDelay > 0 {
+		return e.RetryDelay
+	}
+	return time.Second
+}
+
+// Exit codes of the client
+const (
+	ExitError    = 1
+	ExitNotFound = 3
+	ExitAuth     = 4
+	ExitConflict = 5
+	ExitNetwork  = 6
+)
+
+// ExitCode returns the exit code of the client for the error of a command, run returns the code of its child.
+func ExitCode(err error) int {
+	var coder cli.ExitCoder
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &coder):
+		return coder.ExitCode()
+	case errors.Is(err, apperrors.ErrNotFound):
+		return ExitNotFound
+	case errors.Is(err, apperrors.ErrUnauthenticated), errors.Is(err, apperrors.ErrPermission):
+		return ExitAuth
+	case errors.Is(err, apperrors.ErrConflict):
+		return ExitConflict
+	case errors.Is(err, apperrors.ErrUnavailable):
+		return ExitNetwork
+	}
+	return ExitError
+}
@@ -0,0 +1,220 @@
+package actions
+
+import (
+	"fmt"
+	"sort"
+
+	"gophkeeper/internal/passgen"
+	"gophkeeper/internal/records"
+	"gophkeeper/internal/storage"
+
+	"github.com/urfave/cli/v2"
+)
+
+// policyFlags - flags of password-policy set and generate
+func policyFlags() []cli.Flag {
+	def := passgen.DefaultPolicy()
+	return []cli.Flag{
+		&cli.IntFlag{Name: "length", Value: def.Length, Usage: "number of characters"},
+		&cli.BoolFlag{Name: "lower", Value: def.Lower, Usage: "use lowercase letters, --lower=false to leave them out"},
+		&cli.BoolFlag{Name: "upper", Value: def.Upper, Usage: "use uppercase letters, --upper=false to leave them out"},
+		&cli.BoolFlag{Name: "digits", Value: def.Digits, Usage: "use digits, --digits=false to leave them out"},
+		&cli.BoolFlag{Name: "symbols", Value: def.Symbols, Usage: "use symbols, --symbols=false to leave them out"},
+		&cli.IntFlag{Name: "min-lower", Value: def.MinLower, Usage: "required number of lowercase letters"},
+		&cli.IntFlag{Name: "min-upper", Value: def.MinUpper, Usage: "required number of uppercase letters"},
+		&cli.IntFlag{Name: "min-digits", Value: def.MinDigits, Usage: "required number of digits"},
+		&cli.IntFlag{Name: "min-symbols", Value: def.MinSymbols, Usage: "required number of symbols"},
+		&cli.BoolFlag{Name: "exclude-similar", Usage: "leave out look-alike characters " + passgen.SimilarChars},
+		&cli.IntFlag{Name: "words", Usage: "generate a diceware passphrase of the number of words instead of a password"},
+		&cli.StringFlag{Name: "separator", Value: def.Separator, Usage: "separator of the passphrase words"},
+	}
+}
+
+// policyFromFlags - the policy with the set flags applied, a class left out by a flag is not required anymore
+func policyFromFlags(ctx *cli.Context, p passgen.Policy) passgen.Policy {
+	ints := map[string]*int{"length": &p.Length, "min-lower": &p.MinLower, "min-upper": &p.MinUpper, "min-digits": &p.MinDigits, "min-symbols": &p.MinSymbols, "words": &p.Words}
+	for name, v := range ints {
+		if ctx.IsSet(name) {
+			*v = ctx.Int(name)
+		}
+	}
+	classes := []struct {
+		name string
+		on   *bool
+		min  *int
+	}{
+		{"lower", &p.Lower, &p.MinLower},
+		{"upper", &p.Upper, &p.MinUpper},
+		{"digits", &p.Digits, &p.MinDigits},
+		{"symbols", &p.Symbols, &p.MinSymbols},
+	}
+	for _, c := range classes {
+		if !ctx.IsSet(c.name) {
+			continue
+		}
+		*c.on = ctx.Bool(c.name)
+		if !*c.on && !ctx.IsSet("min-"+c.name) {
+			*c.min = 0
+		}
+	}
+	if ctx.IsSet("exclude-similar") {
+		p.ExcludeSimilar = ctx.Bool("exclude-similar")
+	}
+	if ctx.IsSet("separator") {
+		p.Separator = ctx.String("separator")
+	}
+	return p
+}
+
+// folderPolicy - policy of the --folder flag stored in the vault settings
+func folderPolicy(ctx *cli.Context, store storage.ClientStorage) (passgen.Policy, error) {
+	policies, err := store.PasswordPolicies(ctx.Context)
+	if err != nil {
+		return passgen.Policy{}, err
+	}
+	return passgen.ForFolder(policies, records.CleanFolder(ctx.String("folder"))), nil
+}
+
+// generateSecret - password of the policy of the record folder for add --generate
+func generateSecret(ctx *cli.Context, store storage.ClientStorage) (string, error) {
+	policy, err := folderPolicy(ctx, store)
+	if err != nil {
+		return "", explain(err)
+	}
+	return passgen.Generate(policy)
+}
+
+func generate(store storage.ClientStorage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		policy := passgen.DefaultPolicy()
+		switch ctx.NArg() {
+		case 0:
+			if ctx.IsSet("folder") {
+				return fmt.Errorf("login and password are needed to use the policy of the folder")
+			}
+		case 2:
+			if _, err := store.Login(ctx.Context, ctx.Args().Get(0), ctx.Args().Get(1)); err != nil {
+				return fmt.Errorf("error login happend: %w", explain(err))
+			}
+			var err error
+			if policy, err = folderPolicy(ctx, store); err != nil {
+				return fmt.Errorf("error generate happend: %w", explain(err))
+			}
+		default:
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		password, err := passgen.Generate(policyFromFlags(ctx, policy))
+		if err != nil {
+			return fmt.Errorf("error generate happend: %w", err)
+		}
+		fmt.Fprintln(ctx.App.Writer, password)
+		return nil
+	}
+}
+
+// Generate - used to generate a password
+func Generate(store storage.ClientStorage) *cli.Command {
+	return &cli.Command{
+		Name:  "generate",
+		Usage: "used to generate a password or a passphrase; with login and password the policy of --folder kept in the vault settings is used, flags change it; example: go run main.go generate --length 32 --exclude-similar",
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{Name: "folder", Usage: "use the password policy of the folder"},
+		}, policyFlags()...),
+		Action: traced("generate", generate(store)),
+	}
+}
+
+func listPolicies(store storage.ClientStorage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 2 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		if _, err := store.Login(ctx.Context, ctx.Args().Get(0), ctx.Args().Get(1)); err != nil {
+			return fmt.Errorf("error login happend: %w", explain(err))
+		}
+		policies, err := store.PasswordPolicies(ctx.Context)
+		if err != nil {
+			return fmt.Errorf("error password policy happend: %w", explain(err))
+		}
+		if _, ok := policies[""]; !ok {
+			fmt.Printf("Folder: (vault) Policy: %s (built-in)\n", passgen.DefaultPolicy())
+		}
+		folders := make([]string, 0, len(policies))
+		for folder := range policies {
+			folders = append(folders, folder)
+		}
+		sort.Strings(folders)
+		for _, folder := range folders {
+			name := folder
+			if name == "" {
+				name = "(vault)"
+			}
+			fmt.Printf("Folder: %s Policy: %s\n", name, policies[folder])
+		}
+		return nil
+	}
+}
+
+func setPolicy(store storage.ClientStorage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 2 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		if _, err := store.Login(ctx.Context, ctx.Args().Get(0), ctx.Args().Get(1)); err != nil {
+			return fmt.Errorf("error login happend: %w", explain(err))
+		}
+		policy := policyFromFlags(ctx, passgen.DefaultPolicy())
+		if err := policy.Validate(); err != nil {
+			return fmt.Errorf("error password policy happend: %w", err)
+		}
+		if err := store.SetPasswordPolicy(ctx.Context, records.CleanFolder(ctx.String("folder")), &policy); err != nil {
+			return fmt.Errorf("error password policy happend: %w", explain(err))
+		}
+		fmt.Printf("password policy set: %s\n", policy)
+		return nil
+	}
+}
+
+func deletePolicy(store storage.ClientStorage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 2 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		if _, err := store.Login(ctx.Context, ctx.Args().Get(0), ctx.Args().Get(1)); err != nil {
+			return fmt.Errorf("error login happend: %w", explain(err))
+		}
+		if err := store.SetPasswordPolicy(ctx.Context, records.CleanFolder(ctx.String("folder")), nil); err != nil {
+			return fmt.Errorf("error password policy happend: %w", explain(err))
+		}
+		fmt.Println("password policy deleted")
+		return nil
+	}
+}
+
+// PasswordPolicy - used to keep the password policies of folders in the vault settings
+func PasswordPolicy(store storage.ClientStorage) *cli.Command {
+	folder := &cli.StringFlag{Name: "folder", Usage: "folder of the policy, the whole vault when omitted"}
+	return &cli.Command{
+		Name:  "password-policy",
+		Usage: "used to manage the password policies of folders kept in the vault settings; example: go run main.go password-policy set --folder work --length 32 login password",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "list",
+				Usage:  "list the policies; example: go run main.go password-policy list login password",
+				Action: traced("password-policy list", listPolicies(store)),
+			},
+			{
+				Name:   "set",
+				Usage:  "set the policy of a folder, records of its subfolders use it too; example: go run main.go password-policy set --folder personal --words 6 login password",
+				Flags:  append([]cli.Flag{folder}, policyFlags()...),
+				Action: traced("password-policy set", setPolicy(store)),
+			},
+			{
+				Name:   "delete",
+				Usage:  "remove the policy of a folder, the policy of its parent is used then; example: go run main.go password-policy delete --folder work login password",
+				Flags:  []cli.Flag{folder},
+				Action: traced("password-policy delete", deletePolicy(store)),
+			},
+		},
+	}
+}
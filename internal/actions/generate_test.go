@@ -0,0 +1,38 @@
+package actions
+
+import (
+	"testing"
+
+	"gophkeeper/internal/passgen"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+// parsePolicy - policy of the command line applied to base
+func parsePolicy(t *testing.T, base passgen.Policy, args ...string) passgen.Policy {
+	var p passgen.Policy
+	app := &cli.App{Flags: policyFlags(), Action: func(ctx *cli.Context) error {
+		p = policyFromFlags(ctx, base)
+		return nil
+	}}
+	require.NoError(t, app.Run(append([]string{"generate"}, args...)))
+	return p
+}
+
+func TestPolicyFromFlags(t *testing.T) {
+	folder := passgen.Policy{Words: 6, Separator: " "}
+	assert.Equal(t, folder, parsePolicy(t, folder), "flags that are not set keep the folder policy")
+
+	p := parsePolicy(t, passgen.DefaultPolicy(), "--length", "12", "--symbols=false", "--min-digits", "3", "--exclude-similar")
+	assert.Equal(t, 12, p.Length)
+	assert.False(t, p.Symbols)
+	assert.Zero(t, p.MinSymbols, "left out classes are not required")
+	assert.Equal(t, 3, p.MinDigits)
+	assert.True(t, p.ExcludeSimilar)
+	assert.NoError(t, p.Validate())
+
+	p = parsePolicy(t, passgen.DefaultPolicy(), "--upper=false", "--min-upper", "2")
+	assert.ErrorIs(t, p.Validate(), passgen.ErrPolicy)
+}
@@ -0,0 +1,118 @@
+package actions
+
+import (
+	"fmt"
+	"time"
+
+	"gophkeeper/internal/datamodels"
+	"gophkeeper/internal/gitcred"
+	"gophkeeper/internal/records"
+	"gophkeeper/internal/storage"
+
+	"github.com/urfave/cli/v2"
+)
+
+// gitCredential - runs an operation of the helper protocol with the records of the unlocked session
+func gitCredential(store storage.ClientStorage, op func(ctx *cli.Context, id uint32, c gitcred.Credential, data []datamodels.Data) error) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		c, err := gitcred.Read(ctx.App.Reader)
+		if err != nil {
+			return fmt.Errorf("error git-credential happend: %w", err)
+		}
+		// git runs the helper on every fetch, so it never asks for the password
+		id, err := store.Resume(ctx.Context)
+		if err != nil {
+			return fmt.Errorf("error git-credential happend: %w", explain(err))
+		}
+		data, err := store.ListData(ctx.Context, id)
+		if err != nil {
+			return fmt.Errorf("error git-credential happend: %w", explain(err))
+		}
+		return op(ctx, id, c, data)
+	}
+}
+
+// gitGet - writes the credential of the host, nothing when there is none so git asks other helpers
+func gitGet(ctx *cli.Context, id uint32, c gitcred.Credential, data []datamodels.Data) error {
+	_, found, ok := gitcred.Find(data, c)
+	if !ok {
+		return nil
+	}
+	return gitcred.Write(ctx.App.Writer, found)
+}
+
+// gitStore - keeps the credential git used successfully, the record of the host and username gets the new password
+func gitStore(store storage.ClientStorage) func(ctx *cli.Context, id uint32, c gitcred.Credential, data []datamodels.Data) error {
+	return func(ctx *cli.Context, id uint32, c gitcred.Credential, data []datamodels.Data) error {
+		if c.Username == "" || c.Password == "" {
+			return nil
+		}
+		d, found, ok := gitcred.Find(data, c)
+		if ok && found.Password == c.Password {
+			return nil
+		}
+		var err error
+		if ok {
+			login := records.Login{Username: c.Username, Password: c.Password}
+			if d.Data, err = records.Encode(records.Record{Kind: records.KindLogin, Login: &login}); err != nil {
+				return err
+			}
+			// the metadata of the record is kept
+			d.Metadata = ""
+		} else {
+			if d, err = gitcred.NewRecord(c); err != nil {
+				return err
+			}
+			for _, v := range data {
+				if v.DataID == d.DataID {
+					d.DataID += "/" + c.Username
+				}
+			}
+		}
+		d.UserID, d.ChangedAt = id, time.Time{}
+		if err = store.AddData(ctx.Context, d); err != nil {
+			return fmt.Errorf("error git-credential happend: %w", explain(err))
+		}
+		return nil
+	}
+}
+
+// gitErase - moves the credential git rejected to the trash, only when it is the stored one
+func gitErase(store storage.ClientStorage) func(ctx *cli.Context, id uint32, c gitcred.Credential, data []datamodels.Data) error {
+	return func(ctx *cli.Context, id uint32, c gitcred.Credential, data []datamodels.Data) error {
+		d, found, ok := gitcred.Find(data, c)
+		if !ok || c.Password != "" && found.Password != c.Password {
+			return nil
+		}
+		if err := store.DelData(ctx.Context, d.DataID, id); err != nil {
+			return fmt.Errorf("error git-credential happend: %w", explain(err))
+		}
+		return nil
+	}
+}
+
+// GitCredential - used as a git credential helper
+func GitCredential(store storage.ClientStorage) *cli.Command {
+	return &cli.Command{
+		Name: "git-credential",
+		Usage: "used as a git credential helper with the session of unlock, credentials are login records found by the urls of their metadata " +
+			"or named after the host; example: git config --global credential.helper '!gophkeeper git-credential'",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "get",
+				Usage:  "write the username and password of the host",
+				Action: traced("git-credential get", gitCredential(store, gitGet)),
+			},
+			{
+				Name:   "store",
+				Usage:  "keep the credential git used successfully",
+				Action: traced("git-credential store", gitCredential(store, gitStore(store))),
+			},
+			{
+				Name:   "erase",
+				Usage:  "move the credential git rejected to the trash",
+				Action: traced("git-credential erase", gitCredential(store, gitErase(store))),
+			},
+		},
+	}
+}
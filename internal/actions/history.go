@@ -0,0 +1,120 @@
+package actions
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"gophkeeper/internal/storage"
+
+	"github.com/urfave/cli/v2"
+)
+
+func history(store storage.ClientStorage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 3 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		_, err := store.Login(ctx.Context, ctx.Args().Get(0), ctx.Args().Get(1))
+		if err != nil {
+			return fmt.Errorf("error login happend: %w", explain(err))
+		}
+		versions, err := store.ListVersions(ctx.Context, ctx.Args().Get(2))
+		if err != nil {
+			return fmt.Errorf("error history happend: %w", explain(err))
+		}
+		out := historyOutput{DataID: ctx.Args().Get(2), Versions: make([]versionOutput, 0, len(versions))}
+		for _, v := range versions {
+			out.Versions = append(out.Versions, versionOutput{Revision: v.Revision, ChangedAt: v.ChangedAt, Current: v.Current})
+		}
+		return render(ctx, out, func(w io.Writer) {
+			for _, v := range versions {
+				line := fmt.Sprintf("Version: %d Changed At: %s", v.Revision, v.ChangedAt.Format(time.RFC3339))
+				if v.Current {
+					line += " (current)"
+				}
+				fmt.Fprintln(w, line)
+			}
+		})
+	}
+}
+
+// History - used to list the versions of a record
+func History(store storage.ClientStorage) *cli.Command {
+	return &cli.Command{
+		Name:   "history",
+		Usage:  "used to list the versions of a record kept by the server; you need to enter login and password, then data name; example: go run main.go history login password dataId",
+		Action: traced("history", history(store)),
+	}
+}
+
+func restore(store storage.ClientStorage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 3 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		if !ctx.IsSet("version") {
+			return fmt.Errorf("--version is required")
+		}
+		id, err := store.Login(ctx.Context, ctx.Args().Get(0), ctx.Args().Get(1))
+		if err != nil {
+			return fmt.Errorf("error login happend: %w", explain(err))
+		}
+		dataID := ctx.Args().Get(2)
+		version := ctx.Uint("version")
+		data, err := store.GetVersion(ctx.Context, dataID, uint32(version))
+		if err != nil {
+			return fmt.Errorf("error restore happend: %w", explain(err))
+		}
+		data.UserID, data.ChangedAt = id, time.Time{}
+		// the restored content becomes a new version, the replaced one stays in the history
+		if err = store.AddData(ctx.Context, data); err != nil {
+			return fmt.Errorf("error restore happend: %w", explain(err))
+		}
+		fmt.Printf("version %d of %s restored\n", version, dataID)
+		return nil
+	}
+}
+
+// Restore - used to restore an older version of a record
+func Restore(store storage.ClientStorage) *cli.Command {
+	return &cli.Command{
+		Name:  "restore",
+		Usage: "used to restore a version of a record listed by history; you need to enter login and password, then data name; example: go run main.go restore --version 3 login password dataId",
+		Flags: []cli.Flag{
+			&cli.UintFlag{Name: "version", Usage: "version to restore"},
+		},
+		Action: traced("restore", restore(store)),
+	}
+}
+
+func historyRetention(store storage.ClientStorage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 3 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		versions, err := strconv.ParseUint(ctx.Args().Get(2), 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid number of versions: %w", err)
+		}
+		_, err = store.Login(ctx.Context, ctx.Args().Get(0), ctx.Args().Get(1))
+		if err != nil {
+			return fmt.Errorf("error login happend: %w", explain(err))
+		}
+		if err = store.SetHistoryRetention(ctx.Context, uint32(versions)); err != nil {
+			return fmt.Errorf("error history retention happend: %w", explain(err))
+		}
+		fmt.Printf("%d older versions of every record are kept\n", versions)
+		return nil
+	}
+}
+
+// HistoryRetention - used to set how many older versions of records are kept
+func HistoryRetention(store storage.ClientStorage) *cli.Command {
+	return &cli.Command{
+		Name:   "history-retention",
+		Usage:  "used to set how many older versions of every record the server keeps; you need to enter login and password, then the number; example: go run main.go history-retention login password 10",
+		Action: traced("history-retention", historyRetention(store)),
+	}
+}
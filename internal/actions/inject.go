@@ -0,0 +1,114 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"gophkeeper/internal/storage"
+
+	"github.com/urfave/cli/v2"
+)
+
+// reference - gk://profile/dataID#field, the field is optional
+var reference = regexp.MustCompile(`gk://([A-Za-z0-9_.-]+)/([^\s#"'<>]+)(?:#([A-Za-z0-9_.-]+))?`)
+
+// renderTemplate - replaces the references of the profile with the values returned by lookup
+// Every unresolved reference is reported, the error never has values.
+func renderTemplate(template string, profile string, lookup func(dataID string, field string) (string, error)) (string, error) {
+	var errs []error
+	out := reference.ReplaceAllStringFunc(template, func(ref string) string {
+		m := reference.FindStringSubmatch(ref)
+		if m[1] != profile {
+			errs = append(errs, fmt.Errorf("%s: reference to profile %q, the current profile is %q", ref, m[1], profile))
+			return ref
+		}
+		value, err := lookup(m[2], m[3])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", ref, err))
+			return ref
+		}
+		return value
+	})
+	if errs != nil {
+		return "", errors.Join(errs...)
+	}
+	return out, nil
+}
+
+// writeSecretFile - writes the file with 0600 permissions through a temporary file, so it is never partly written
+func writeSecretFile(name string, content string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = io.WriteString(tmp, content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+func inject(store storage.ClientStorage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 2 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		var template []byte
+		var err error
+		if in := ctx.String("in"); in == "-" {
+			template, err = io.ReadAll(ctx.App.Reader)
+		} else {
+			template, err = os.ReadFile(in)
+		}
+		if err != nil {
+			return fmt.Errorf("error inject happend: %w", err)
+		}
+		id, err := store.Login(ctx.Context, ctx.Args().Get(0), ctx.Args().Get(1))
+		if err != nil {
+			return fmt.Errorf("error login happend: %w", explain(err))
+		}
+		r := newResolver(store, id)
+		out, err := renderTemplate(string(template), storage.CurrentProfile().Name, func(dataID string, field string) (string, error) {
+			value, err := r.field(ctx.Context, dataID, field)
+			return value, explain(err)
+		})
+		if err != nil {
+			return fmt.Errorf("error inject happend: unresolved references:\n%w", err)
+		}
+		if name := ctx.String("out"); name != "-" {
+			err = writeSecretFile(name, out)
+		} else {
+			_, err = io.WriteString(ctx.App.Writer, out)
+		}
+		if err != nil {
+			return fmt.Errorf("error inject happend: %w", err)
+		}
+		return nil
+	}
+}
+
+// Inject - used to render templates with references to secrets
+func Inject(store storage.ClientStorage) *cli.Command {
+	return &cli.Command{
+		Name: "inject",
+		Usage: "used to replace references gk://profile/dataID#field in a template with fields of records, it fails when any reference is not resolved; " +
+			"you need to enter login and password; example: go run main.go inject -i config.yml.tpl -o config.yml login password",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "in", Aliases: []string{"i"}, Value: "-", Usage: "template file, - reads stdin"},
+			&cli.StringFlag{Name: "out", Aliases: []string{"o"}, Value: "-", Usage: "output file written with 0600 permissions, - writes stdout"},
+		},
+		Action: traced("inject", inject(store)),
+	}
+}
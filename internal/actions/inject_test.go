@@ -0,0 +1,44 @@
+package actions
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gophkeeper/internal/records"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	values := map[string]string{"db/password": "s3cret", "api/": "token"}
+	lookup := func(dataID, field string) (string, error) {
+		if v, ok := values[dataID+"/"+field]; ok {
+			return v, nil
+		}
+		return "", records.ErrUnknownField
+	}
+
+	out, err := renderTemplate("password: gk://default/db#password\ntoken: \"gk://default/api\"\n", "default", lookup)
+	require.NoError(t, err)
+	assert.Equal(t, "password: s3cret\ntoken: \"token\"\n", out)
+
+	_, err = renderTemplate("a: gk://default/db#user\nb: gk://prod/db#password\n", "default", lookup)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "gk://default/db#user")
+	assert.Contains(t, err.Error(), `profile "prod"`)
+	assert.NotContains(t, err.Error(), "s3cret")
+}
+
+func TestWriteSecretFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.yml")
+	require.NoError(t, os.WriteFile(name, []byte("old"), 0644))
+	require.NoError(t, writeSecretFile(name, "new"))
+	info, err := os.Stat(name)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	b, err := os.ReadFile(name)
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(b))
+}
@@ -0,0 +1,147 @@
+package actions
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"gophkeeper/internal/datamodels"
+	"gophkeeper/internal/records"
+	"gophkeeper/internal/storage"
+
+	"github.com/urfave/cli/v2"
+)
+
+// revealFlag - shows secrets instead of masking them
+var revealFlag = &cli.BoolFlag{Name: "reveal", Usage: "print the data of records instead of " + records.Masked}
+
+// parseSince - reads a moment as RFC3339, a date 2006-01-02 or a duration back from now like 24h
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q: use a duration like 24h, a date like 2006-01-02 or RFC3339", s)
+}
+
+// printRecords - writes the records in the --output format, the data is masked unless reveal is set
+func printRecords(ctx *cli.Context, data []datamodels.Data, reveal bool) error {
+	return render(ctx, newRecordsOutput(data, reveal), func(w io.Writer) {
+		for _, v := range data {
+			secret := records.Masked
+			if reveal {
+				secret = v.Data
+			}
+			fmt.Fprintf(w, "DataID: %s Kind: %s Changed At: %s Meta Info: %s Data: %s\n",
+				v.DataID, records.Decode(v.Data).Kind, v.ChangedAt.Format(time.RFC3339), records.DecodeMeta(v.Metadata), secret)
+		}
+	})
+}
+
+func listData(store storage.ClientStorage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 2 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		fields, err := records.ParseFields(ctx.StringSlice("field"))
+		if err != nil {
+			return err
+		}
+		filter := records.Filter{
+			Tags:     ctx.StringSlice("tag"),
+			Name:     ctx.String("name"),
+			Folder:   ctx.String("folder"),
+			Fields:   fields,
+			Favorite: ctx.Bool("favorite"),
+		}
+		for _, k := range ctx.StringSlice("kind") {
+			kind, err := records.ParseKind(k)
+			if err != nil {
+				return err
+			}
+			filter.Kinds = append(filter.Kinds, kind)
+		}
+		if ctx.IsSet("since") {
+			since, err := parseSince(ctx.String("since"), time.Now())
+			if err != nil {
+				return err
+			}
+			filter.Since = since
+		}
+		id, err := store.Login(ctx.Context, ctx.Args().Get(0), ctx.Args().Get(1))
+		if err != nil {
+			return fmt.Errorf("error login happend: %w", explain(err))
+		}
+		data, err := store.ListData(ctx.Context, id)
+		if err != nil {
+			return fmt.Errorf("error list happend: %w", explain(err))
+		}
+		var found []datamodels.Data
+		for _, v := range data {
+			if filter.Match(v) {
+				found = append(found, v)
+			}
+		}
+		return printRecords(ctx, found, ctx.Bool("reveal"))
+	}
+}
+
+// List - used to list the records of the local vault
+func List(store storage.ClientStorage) *cli.Command {
+	return &cli.Command{
+		Name:    "list",
+		Usage:   "used to list the records kept on this device, run sync first to get the records of other devices; you need to enter login and password; example: go run main.go list --kind otp --tag work --folder work --name 'git*' --since 24h login password",
+		Aliases: []string{"ls"},
+		Flags: []cli.Flag{
+			&cli.StringSliceFlag{Name: "kind", Usage: "only records of the kind, can be repeated"},
+			&cli.StringSliceFlag{Name: "tag", Usage: "only records with the tag, can be repeated"},
+			&cli.StringFlag{Name: "name", Usage: "substring of the data id or a glob pattern"},
+			&cli.StringFlag{Name: "folder", Usage: "only records in the folder or its subfolders"},
+			&cli.StringSliceFlag{Name: "field", Usage: "only records with the custom field key=value, can be repeated"},
+			&cli.BoolFlag{Name: "favorite", Usage: "only favorite records"},
+			&cli.StringFlag{Name: "since", Usage: "only records changed since: a duration like 24h, a date like 2006-01-02 or RFC3339"},
+			revealFlag,
+		},
+		Action: traced("list", listData(store)),
+	}
+}
+
+func searchData(store storage.ClientStorage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() < 3 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		id, err := store.Login(ctx.Context, ctx.Args().Get(0), ctx.Args().Get(1))
+		if err != nil {
+			return fmt.Errorf("error login happend: %w", explain(err))
+		}
+		data, err := store.ListData(ctx.Context, id)
+		if err != nil {
+			return fmt.Errorf("error search happend: %w", explain(err))
+		}
+		query := strings.Join(ctx.Args().Slice()[2:], " ")
+		var found []datamodels.Data
+		for _, v := range data {
+			if records.Search(v, query) {
+				found = append(found, v)
+			}
+		}
+		return printRecords(ctx, found, ctx.Bool("reveal"))
+	}
+}
+
+// Search - used to find records by their names and meta information
+func Search(store storage.ClientStorage) *cli.Command {
+	return &cli.Command{
+		Name:   "search",
+		Usage:  "used to find records kept on this device whose data id or meta information contains every word of the query; you need to enter login and password, then the query; example: go run main.go search login password github work",
+		Flags:  []cli.Flag{revealFlag},
+		Action: traced("search", searchData(store)),
+	}
+}
@@ -0,0 +1,34 @@
+package actions
+
+import (
+	"gophkeeper/internal/records"
+
+	"github.com/urfave/cli/v2"
+)
+
+// metaFlags - flags of the structured meta information of add
+func metaFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{Name: "tag", Usage: "tag of the record, can be repeated"},
+		&cli.StringFlag{Name: "folder", Usage: "folder of the record like work/servers"},
+		&cli.StringSliceFlag{Name: "url", Usage: "url of the record, can be repeated"},
+		&cli.StringSliceFlag{Name: "field", Usage: "custom field key=value, can be repeated"},
+		&cli.BoolFlag{Name: "favorite", Usage: "mark the record as favorite"},
+	}
+}
+
+// metaFromFlags - returns the Metadata field made of the flags and the notes
+func metaFromFlags(ctx *cli.Context, notes string) (string, error) {
+	fields, err := records.ParseFields(ctx.StringSlice("field"))
+	if err != nil {
+		return "", err
+	}
+	return records.EncodeMeta(records.Meta{
+		Tags:     ctx.StringSlice("tag"),
+		Folder:   ctx.String("folder"),
+		URLs:     ctx.StringSlice("url"),
+		Notes:    notes,
+		Fields:   fields,
+		Favorite: ctx.Bool("favorite"),
+	}), nil
+}
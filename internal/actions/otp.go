@@ -0,0 +1,47 @@
+package actions
+
+import (
+	"fmt"
+	"time"
+
+	"gophkeeper/internal/records"
+	"gophkeeper/internal/storage"
+
+	"github.com/urfave/cli/v2"
+)
+
+func otpCode(store storage.Storage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 3 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		id, err := store.Login(ctx.Context, ctx.Args().Get(0), ctx.Args().Get(1))
+		if err != nil {
+			return fmt.Errorf("error login happend: %w", explain(err))
+		}
+		data, err := store.GetData(ctx.Context, ctx.Args().Get(2), id)
+		if err != nil {
+			return fmt.Errorf("error get happend: %w", explain(err))
+		}
+		r := records.Decode(data.Data)
+		if r.Kind != records.KindOTP || r.OTP == nil {
+			return fmt.Errorf("error otp happend: %w: %s", records.ErrWrongKind, r.Kind)
+		}
+		key, err := r.OTP.Key()
+		if err != nil {
+			return fmt.Errorf("error otp happend: %w", err)
+		}
+		now := time.Now()
+		fmt.Printf("%s (%d seconds remaining)\n", key.Code(now), int(key.Remaining(now).Seconds()))
+		return nil
+	}
+}
+
+// OTP - used to print the current one-time code of an otp record
+func OTP(store storage.Storage) *cli.Command {
+	return &cli.Command{
+		Name:   "otp",
+		Usage:  "used to print the current one-time code of an otp record; you need to enter login and password, then data name; example: go run main.go otp login password dataId",
+		Action: traced("otp", otpCode(store)),
+	}
+}
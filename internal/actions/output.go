@@ -0,0 +1,203 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"gophkeeper/internal/datamodels"
+	"gophkeeper/internal/records"
+
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats of the --output flag
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+	OutputYAML = "yaml"
+	OutputEnv  = "env"
+)
+
+// ParseOutput checks the value of the --output flag.
+func ParseOutput(format string) error {
+	switch format {
+	case OutputText, OutputJSON, OutputYAML, OutputEnv:
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q: use text, json, yaml or env", format)
+}
+
+// metaOutput - schema of the meta information of a record
+type metaOutput struct {
+	Tags     []string          `json:"tags" yaml:"tags"`
+	Folder   string            `json:"folder" yaml:"folder"`
+	URLs     []string          `json:"urls" yaml:"urls"`
+	Notes    string            `json:"notes" yaml:"notes"`
+	Fields   map[string]string `json:"fields" yaml:"fields"`
+	Favorite bool              `json:"favorite" yaml:"favorite"`
+}
+
+// recordOutput - schema of a record
+type recordOutput struct {
+	DataID    string     `json:"data_id" yaml:"data_id"`
+	Kind      string     `json:"kind" yaml:"kind"`
+	Data      string     `json:"data" yaml:"data"`
+	Meta      metaOutput `json:"meta" yaml:"meta"`
+	ChangedAt time.Time  `json:"changed_at" yaml:"changed_at"`
+	Revision  uint32     `json:"revision" yaml:"revision"`
+	Deleted   bool       `json:"deleted" yaml:"deleted"`
+}
+
+// recordsOutput - schema of list, search and sync
+type recordsOutput struct {
+	Records []recordOutput `json:"records" yaml:"records"`
+}
+
+// versionOutput - schema of a version of a record
+type versionOutput struct {
+	Revision  uint32    `json:"revision" yaml:"revision"`
+	ChangedAt time.Time `json:"changed_at" yaml:"changed_at"`
+	Current   bool      `json:"current" yaml:"current"`
+}
+
+// historyOutput - schema of history
+type historyOutput struct {
+	DataID   string          `json:"data_id" yaml:"data_id"`
+	Versions []versionOutput `json:"versions" yaml:"versions"`
+}
+
+// newRecordOutput - record in the output schema, the data is masked unless reveal is set
+func newRecordOutput(d datamodels.Data, reveal bool) recordOutput {
+	m := records.DecodeMeta(d.Metadata)
+	out := recordOutput{
+		DataID:    d.DataID,
+		Kind:      string(records.Decode(d.Data).Kind),
+		Data:      records.Masked,
+		Meta:      metaOutput{Tags: m.Tags, Folder: m.Folder, URLs: m.URLs, Notes: m.Notes, Fields: m.Fields, Favorite: m.Favorite},
+		ChangedAt: d.ChangedAt,
+		Revision:  d.Revision,
+		Deleted:   d.Deleted,
+	}
+	if reveal {
+		out.Data = d.Data
+	}
+	// empty lists and maps keep their type in json and yaml
+	if out.Meta.Tags == nil {
+		out.Meta.Tags = []string{}
+	}
+	if out.Meta.URLs == nil {
+		out.Meta.URLs = []string{}
+	}
+	if out.Meta.Fields == nil {
+		out.Meta.Fields = map[string]string{}
+	}
+	return out
+}
+
+// newRecordsOutput - records in the output schema
+func newRecordsOutput(data []datamodels.Data, reveal bool) recordsOutput {
+	out := recordsOutput{Records: make([]recordOutput, 0, len(data))}
+	for _, d := range data {
+		out.Records = append(out.Records, newRecordOutput(d, reveal))
+	}
+	return out
+}
+
+// render - writes v in the --output format, the text format is written by text
+func render(ctx *cli.Context, v any, text func(w io.Writer)) error {
+	w := ctx.App.Writer
+	switch ctx.String("output") {
+	case OutputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case OutputYAML:
+		b, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	case OutputEnv:
+		return writeEnv(w, v)
+	}
+	text(w)
+	return nil
+}
+
+// writeEnv - writes v as sorted NAME='value' lines of the json schema
+// Nested names are joined with _, list items get their index and a NAME_COUNT line.
+func writeEnv(w io.Writer, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var tree any
+	if err = dec.Decode(&tree); err != nil {
+		return err
+	}
+	vars := map[string]string{}
+	flattenEnv("", tree, vars)
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, err = fmt.Fprintf(w, "%s=%s\n", name, shellQuote(vars[name])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flattenEnv - adds the scalars of the json tree to vars
+func flattenEnv(prefix string, v any, vars map[string]string) {
+	join := func(name string) string {
+		if prefix == "" {
+			return envName(name)
+		}
+		return prefix + "_" + envName(name)
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		for k, item := range v {
+			flattenEnv(join(k), item, vars)
+		}
+	case []any:
+		vars[join("COUNT")] = fmt.Sprint(len(v))
+		for i, item := range v {
+			flattenEnv(join(fmt.Sprint(i)), item, vars)
+		}
+	case nil:
+		vars[prefix] = ""
+	default:
+		vars[prefix] = fmt.Sprint(v)
+	}
+}
+
+// envName - upper case name with letters, digits and _ only
+func envName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// shellQuote - single quoted value safe for sh
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,48 @@
+package actions
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"gophkeeper/internal/apperrors"
+	"gophkeeper/internal/datamodels"
+	"gophkeeper/internal/records"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteEnv(t *testing.T) {
+	changed := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	meta := records.EncodeMeta(records.Meta{Tags: []string{"work"}, Fields: map[string]string{"db-user": "it's me"}})
+	out := newRecordsOutput([]datamodels.Data{{DataID: "db", Data: "secret", Metadata: meta, ChangedAt: changed, Revision: 2}}, false)
+
+	var b bytes.Buffer
+	require.NoError(t, writeEnv(&b, out))
+	assert.Equal(t, `RECORDS_0_CHANGED_AT='2023-01-01T00:00:00Z'
+RECORDS_0_DATA='********'
+RECORDS_0_DATA_ID='db'
+RECORDS_0_DELETED='false'
+RECORDS_0_KIND='text'
+RECORDS_0_META_FAVORITE='false'
+RECORDS_0_META_FIELDS_DB_USER='it'\''s me'
+RECORDS_0_META_FOLDER=''
+RECORDS_0_META_NOTES=''
+RECORDS_0_META_TAGS_0='work'
+RECORDS_0_META_TAGS_COUNT='1'
+RECORDS_0_META_URLS_COUNT='0'
+RECORDS_0_REVISION='2'
+RECORDS_COUNT='1'
+`, b.String())
+}
+
+func TestExitCode(t *testing.T) {
+	assert.Equal(t, 0, ExitCode(nil))
+	assert.Equal(t, ExitNotFound, ExitCode(fmt.Errorf("error get happend: %w", explain(apperrors.NotFound("DATA_NOT_FOUND", "not found")))))
+	assert.Equal(t, ExitAuth, ExitCode(explain(apperrors.Unauthenticated("WRONG_PASSWORD", "invalid password"))))
+	assert.Equal(t, ExitConflict, ExitCode(apperrors.Conflict("REVISION_MISMATCH", "changed")))
+	assert.Equal(t, ExitNetwork, ExitCode(&apperrors.Error{Kind: apperrors.ErrUnavailable}))
+	assert.Equal(t, ExitError, ExitCode(fmt.Errorf("wrong amount of arguments")))
+}
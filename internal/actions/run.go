@@ -0,0 +1,133 @@
+package actions
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"syscall"
+
+	"gophkeeper/internal/storage"
+
+	"github.com/urfave/cli/v2"
+)
+
+// parseEnvMapping - parses NAME=dataID[.field]
+func parseEnvMapping(mapping string) (string, string, error) {
+	name, ref, ok := strings.Cut(mapping, "=")
+	name, ref = strings.TrimSpace(name), strings.TrimSpace(ref)
+	if !ok || name == "" || ref == "" || strings.ContainsAny(name, " \t") {
+		return "", "", fmt.Errorf("invalid mapping %q: use NAME=dataID[.field]", mapping)
+	}
+	return name, ref, nil
+}
+
+// readEnvFile - reads NAME=dataID[.field] lines, empty lines and lines starting with # are skipped
+func readEnvFile(name string, env map[string]string) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		k, ref, err := parseEnvMapping(strings.TrimPrefix(line, "export "))
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", name, n, err)
+		}
+		env[k] = ref
+	}
+	return scanner.Err()
+}
+
+// exitCode - exit code of the finished child, 128+signal when it was killed
+func exitCode(err *exec.ExitError) int {
+	if status, ok := err.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return err.ExitCode()
+}
+
+func runCommand(store storage.ClientStorage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		args := ctx.Args().Slice()
+		if len(args) < 3 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		command := args[2:]
+		if command[0] == "--" {
+			command = command[1:]
+		}
+		if len(command) == 0 {
+			return fmt.Errorf("no command to run")
+		}
+		refs := map[string]string{}
+		for _, name := range ctx.StringSlice("env-file") {
+			if err := readEnvFile(name, refs); err != nil {
+				return fmt.Errorf("error run happend: %w", err)
+			}
+		}
+		for _, mapping := range ctx.StringSlice("env") {
+			name, ref, err := parseEnvMapping(mapping)
+			if err != nil {
+				return err
+			}
+			refs[name] = ref
+		}
+		id, err := store.Login(ctx.Context, args[0], args[1])
+		if err != nil {
+			return fmt.Errorf("error login happend: %w", explain(err))
+		}
+		// every reference is resolved before the command starts, so it never runs with a part of its secrets
+		names := make([]string, 0, len(refs))
+		for name := range refs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		r := newResolver(store, id)
+		env := os.Environ()
+		for _, name := range names {
+			value, err := r.resolve(ctx.Context, refs[name])
+			if err != nil {
+				return fmt.Errorf("error run happend: %s: %w", name, err)
+			}
+			env = append(env, name+"="+value)
+		}
+
+		cmd := exec.CommandContext(ctx.Context, command[0], command[1:]...)
+		cmd.Env = env
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		// the child gets the interrupt as well and decides when to stop
+		cmd.Cancel = func() error { return cmd.Process.Signal(os.Interrupt) }
+		err = cmd.Run()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return cli.Exit("", exitCode(exitErr))
+		}
+		if err != nil {
+			return fmt.Errorf("error run happend: %w", err)
+		}
+		return nil
+	}
+}
+
+// Run - used to run a command with secrets in its environment
+func Run(store storage.ClientStorage) *cli.Command {
+	return &cli.Command{
+		Name:      "run",
+		Usage:     "used to run a command with fields of records in its environment, the exit code of the command is returned; you need to enter login and password, then the command after --; example: go run main.go run --env DB_PASS=db.password --env-file .env.gk login password -- ./deploy.sh",
+		ArgsUsage: "login password -- command [args]",
+		Flags: []cli.Flag{
+			&cli.StringSliceFlag{Name: "env", Usage: "NAME=dataID[.field], can be repeated"},
+			&cli.StringSliceFlag{Name: "env-file", Usage: "file of NAME=dataID[.field] lines, can be repeated; --env wins"},
+		},
+		Action: traced("run", runCommand(store)),
+	}
+}
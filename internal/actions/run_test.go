@@ -0,0 +1,24 @@
+package actions
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadEnvFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), ".env.gk")
+	require.NoError(t, os.WriteFile(name, []byte("# database\nDB_PASS=db.password\n\nexport DB_HOST = db.host\n"), 0600))
+	env := map[string]string{}
+	require.NoError(t, readEnvFile(name, env))
+	assert.Equal(t, map[string]string{"DB_PASS": "db.password", "DB_HOST": "db.host"}, env)
+
+	require.NoError(t, os.WriteFile(name, []byte("DB_PASS\n"), 0600))
+	assert.ErrorContains(t, readEnvFile(name, env), ":1:")
+
+	_, _, err := parseEnvMapping("=db")
+	assert.Error(t, err)
+}
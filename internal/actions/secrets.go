@@ -0,0 +1,61 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gophkeeper/internal/apperrors"
+	"gophkeeper/internal/datamodels"
+	"gophkeeper/internal/records"
+	"gophkeeper/internal/storage"
+)
+
+// resolver - reads fields of records of the logged in user, every record is read once
+type resolver struct {
+	store  storage.ClientStorage
+	userID uint32
+	cache  map[string]datamodels.Data
+}
+
+// newResolver - resolver of the records of the user
+func newResolver(store storage.ClientStorage, userID uint32) *resolver {
+	return &resolver{store: store, userID: userID, cache: make(map[string]datamodels.Data)}
+}
+
+// get - reads the record once
+func (r *resolver) get(ctx context.Context, dataID string) (datamodels.Data, error) {
+	if d, ok := r.cache[dataID]; ok {
+		return d, nil
+	}
+	d, err := r.store.GetData(ctx, dataID, r.userID)
+	if err != nil {
+		return datamodels.Data{}, err
+	}
+	r.cache[dataID] = d
+	return d, nil
+}
+
+// field - returns the field of the record, see records.Field
+func (r *resolver) field(ctx context.Context, dataID string, field string) (string, error) {
+	d, err := r.get(ctx, dataID)
+	if err != nil {
+		return "", err
+	}
+	return records.Field(d, field, time.Now())
+}
+
+// resolve - returns the value of a dataID[.field] reference, a data id with dots is tried as a whole first
+// Errors name the reference, never the value.
+func (r *resolver) resolve(ctx context.Context, ref string) (string, error) {
+	v, err := r.field(ctx, ref, "")
+	if i := strings.LastIndex(ref, "."); errors.Is(err, apperrors.ErrNotFound) && i > 0 {
+		v, err = r.field(ctx, ref[:i], ref[i+1:])
+	}
+	if err != nil {
+		return "", fmt.Errorf("reference %q: %w", ref, explain(err))
+	}
+	return v, nil
+}
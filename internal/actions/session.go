@@ -0,0 +1,70 @@
+package actions
+
+import (
+	"fmt"
+	"time"
+
+	"gophkeeper/internal/sessionagent"
+	"gophkeeper/internal/storage"
+	files "gophkeeper/internal/storage/filereaders"
+
+	"github.com/urfave/cli/v2"
+)
+
+func unlock(store storage.ClientStorage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 2 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		login := ctx.Args().Get(0)
+		id, err := store.Login(ctx.Context, login, ctx.Args().Get(1))
+		if err != nil {
+			return fmt.Errorf("error login happend: %w", explain(err))
+		}
+		session, err := store.Unlock(ctx.Context, login, id, ctx.Duration("ttl"))
+		if err != nil {
+			return fmt.Errorf("error unlock happend: %w", err)
+		}
+		socket, err := files.SessionSocket()
+		if err != nil {
+			return fmt.Errorf("error unlock happend: %w", err)
+		}
+		l, err := sessionagent.Listen(socket)
+		if err != nil {
+			return fmt.Errorf("error unlock happend: %w", err)
+		}
+		// the session is kept in the memory of this process only, it ends with the process
+		fmt.Fprintf(ctx.App.ErrWriter, "unlocked until %s, press Ctrl+C or run lock to lock\n", session.Expires.Format(time.RFC3339))
+		if err = sessionagent.Serve(ctx.Context, l, session); err != nil {
+			return fmt.Errorf("error unlock happend: %w", err)
+		}
+		return nil
+	}
+}
+
+// Unlock - used to keep the session for commands run without credentials
+func Unlock(store storage.ClientStorage) *cli.Command {
+	return &cli.Command{
+		Name:  "unlock",
+		Usage: "used to keep the session for commands run by other programs without credentials, like git-credential, until interrupted, locked or ttl passes; you need to enter login and password; example: go run main.go unlock --ttl 8h login password &",
+		Flags: []cli.Flag{
+			&cli.DurationFlag{Name: "ttl", Value: 12 * time.Hour, Usage: "how long the session is kept"},
+		},
+		Action: traced("unlock", unlock(store)),
+	}
+}
+
+// Lock - used to end the session kept by unlock
+func Lock(store storage.ClientStorage) *cli.Command {
+	return &cli.Command{
+		Name:  "lock",
+		Usage: "used to end the session kept by unlock; example: go run main.go lock",
+		Action: traced("lock", func(ctx *cli.Context) error {
+			if err := store.Lock(ctx.Context); err != nil {
+				return fmt.Errorf("error lock happend: %w", err)
+			}
+			fmt.Println("locked")
+			return nil
+		}),
+	}
+}
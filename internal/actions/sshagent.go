@@ -0,0 +1,111 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gophkeeper/internal/records"
+	"gophkeeper/internal/sshagent"
+	"gophkeeper/internal/storage"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/crypto/ssh"
+)
+
+// sshKeyData - Data field of an ssh-key record of the key file decrypted with the passphrase, asked when the key is encrypted and --passphrase is omitted
+func sshKeyData(ctx *cli.Context, path string) (string, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	passphrase := ctx.String("passphrase")
+	key, err := records.NewSSHKey(string(pem), passphrase)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		if passphrase, err = prompt(ctx, "passphrase of "+path+": "); err != nil {
+			return "", err
+		}
+		key, err = records.NewSSHKey(string(pem), passphrase)
+	}
+	if err != nil {
+		return "", err
+	}
+	return records.Encode(records.Record{Kind: records.KindSSHKey, SSHKey: &key})
+}
+
+// confirmKey - asks on the terminal whether the key may sign
+func confirmKey(ctx *cli.Context) sshagent.Confirm {
+	return func(comment string) bool {
+		answer, err := prompt(ctx, "allow use of ssh key "+comment+"? [y/N] ")
+		return err == nil && strings.EqualFold(answer, "y")
+	}
+}
+
+func sshAgent(store storage.ClientStorage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		var id uint32
+		var err error
+		switch ctx.NArg() {
+		case 0:
+			id, err = store.Resume(ctx.Context)
+		case 2:
+			id, err = store.Login(ctx.Context, ctx.Args().Get(0), ctx.Args().Get(1))
+		default:
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		if err != nil {
+			return fmt.Errorf("error login happend: %w", explain(err))
+		}
+		data, err := store.ListData(ctx.Context, id)
+		if err != nil {
+			return fmt.Errorf("error ssh-agent happend: %w", explain(err))
+		}
+		filter := records.Filter{Kinds: []records.Kind{records.KindSSHKey}, Tags: ctx.StringSlice("tag")}
+		keys := data[:0]
+		for _, d := range data {
+			if filter.Match(d) {
+				keys = append(keys, d)
+			}
+		}
+		opts := sshagent.Options{Lifetime: ctx.Duration("lifetime")}
+		if ctx.Bool("confirm") {
+			opts.Confirm = confirmKey(ctx)
+		}
+		agent, err := sshagent.New(keys, opts)
+		if err != nil {
+			return fmt.Errorf("error ssh-agent happend: %w", err)
+		}
+		l, err := sshagent.Listen(ctx.String("socket"))
+		if err != nil {
+			return fmt.Errorf("error ssh-agent happend: %w", err)
+		}
+		socket := l.Addr().String()
+		if !ctx.IsSet("socket") {
+			defer os.Remove(filepath.Dir(socket))
+		}
+		// the agent stays in the foreground to ask --confirm questions, so ssh is pointed at it from another shell
+		fmt.Fprintf(ctx.App.ErrWriter, "serving %d keys, press Ctrl+C to stop; to use them run in another shell:\nexport SSH_AUTH_SOCK=%s\n", len(keys), socket)
+		if err = sshagent.Serve(ctx.Context, l, agent); err != nil {
+			return fmt.Errorf("error ssh-agent happend: %w", err)
+		}
+		return nil
+	}
+}
+
+// SSHAgent - used to serve the ssh keys of the vault to ssh
+func SSHAgent(store storage.ClientStorage) *cli.Command {
+	return &cli.Command{
+		Name:  "ssh-agent",
+		Usage: "used to serve the ssh keys of the vault to ssh until interrupted, SSH_AUTH_SOCK to set in another shell is printed; you need to enter login and password, or nothing when the vault is unlocked; example: go run main.go ssh-agent --confirm --lifetime 1h login password",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "socket", Usage: "path of the agent socket, a private temporary directory by default"},
+			&cli.BoolFlag{Name: "confirm", Usage: "ask before every use of a key"},
+			&cli.DurationFlag{Name: "lifetime", Usage: "keys are removed from the agent after it, kept until the agent stops by default"},
+			&cli.StringSliceFlag{Name: "tag", Usage: "serve the keys having the tag only"},
+		},
+		Action: traced("ssh-agent", sshAgent(store)),
+	}
+}
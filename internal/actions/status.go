@@ -0,0 +1,43 @@
+package actions
+
+import (
+	"fmt"
+	"io"
+
+	"gophkeeper/internal/storage"
+	files "gophkeeper/internal/storage/filereaders"
+
+	"github.com/urfave/cli/v2"
+)
+
+// statusOutput - schema of status
+type statusOutput struct {
+	Profile      string `json:"profile" yaml:"profile"`
+	Server       string `json:"server" yaml:"server"`
+	ServerStatus string `json:"server_status" yaml:"server_status"`
+	DeviceID     string `json:"device_id" yaml:"device_id"`
+}
+
+func status(ctx *cli.Context) error {
+	if ctx.NArg() != 0 {
+		return fmt.Errorf("wrong amount of arguments")
+	}
+	profile := storage.CurrentProfile()
+	device, err := files.DeviceID()
+	if err != nil {
+		return fmt.Errorf("error status happend: %w", err)
+	}
+	out := statusOutput{Profile: profile.Name, Server: profile.Address, ServerStatus: storage.ServerStatus(ctx.Context), DeviceID: device}
+	return render(ctx, out, func(w io.Writer) {
+		fmt.Fprintf(w, "Profile: %s Server: %s Server Status: %s Device ID: %s\n", out.Profile, out.Server, out.ServerStatus, out.DeviceID)
+	})
+}
+
+// Status - used to show the profile, the server health and the id of this device
+func Status() *cli.Command {
+	return &cli.Command{
+		Name:   "status",
+		Usage:  "used to show the profile, whether its server is serving and the id of this device; example: go run main.go status",
+		Action: traced("status", status),
+	}
+}
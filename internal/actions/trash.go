@@ -0,0 +1,95 @@
+package actions
+
+import (
+	"fmt"
+	"time"
+
+	"gophkeeper/internal/storage"
+
+	"github.com/urfave/cli/v2"
+)
+
+func listTrash(store storage.ClientStorage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 2 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		_, err := store.Login(ctx.Context, ctx.Args().Get(0), ctx.Args().Get(1))
+		if err != nil {
+			return fmt.Errorf("error login happend: %w", explain(err))
+		}
+		data, err := store.ListTrash(ctx.Context)
+		if err != nil {
+			return fmt.Errorf("error trash happend: %w", explain(err))
+		}
+		// only the names are printed, the content stays in the trash until it is restored
+		for _, v := range data {
+			fmt.Printf("Data ID: %s Deleted At: %s\n", v.DataID, v.ChangedAt.Format(time.RFC3339))
+		}
+		return nil
+	}
+}
+
+func restoreTrash(store storage.ClientStorage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 3 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		id, err := store.Login(ctx.Context, ctx.Args().Get(0), ctx.Args().Get(1))
+		if err != nil {
+			return fmt.Errorf("error login happend: %w", explain(err))
+		}
+		dataID := ctx.Args().Get(2)
+		if err = store.RestoreData(ctx.Context, dataID, id); err != nil {
+			return fmt.Errorf("error trash restore happend: %w", explain(err))
+		}
+		fmt.Printf("%s restored\n", dataID)
+		return nil
+	}
+}
+
+func emptyTrash(store storage.ClientStorage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 2 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		_, err := store.Login(ctx.Context, ctx.Args().Get(0), ctx.Args().Get(1))
+		if err != nil {
+			return fmt.Errorf("error login happend: %w", explain(err))
+		}
+		purged, pending, err := store.EmptyTrash(ctx.Context)
+		if err != nil {
+			return fmt.Errorf("error trash empty happend: %w", explain(err))
+		}
+		fmt.Printf("%d records removed\n", purged)
+		if pending > 0 {
+			fmt.Printf("%d records will be removed after every device of the account synchronizes\n", pending)
+		}
+		return nil
+	}
+}
+
+// Trash - used to list, restore and remove deleted records
+func Trash(store storage.ClientStorage) *cli.Command {
+	return &cli.Command{
+		Name:  "trash",
+		Usage: "used to manage deleted records kept by the server; example: go run main.go trash list login password",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "list",
+				Usage:  "list deleted records; example: go run main.go trash list login password",
+				Action: traced("trash list", listTrash(store)),
+			},
+			{
+				Name:   "restore",
+				Usage:  "take a record out of the trash; example: go run main.go trash restore login password dataId",
+				Action: traced("trash restore", restoreTrash(store)),
+			},
+			{
+				Name:   "empty",
+				Usage:  "remove deleted records for good; example: go run main.go trash empty login password",
+				Action: traced("trash empty", emptyTrash(store)),
+			},
+		},
+	}
+}
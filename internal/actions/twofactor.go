@@ -0,0 +1,159 @@
+package actions
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+
+	"gophkeeper/internal/storage"
+	"gophkeeper/internal/totp"
+
+	"github.com/urfave/cli/v2"
+)
+
+// issuer - name of the service shown by authenticator apps
+const issuer = "GophKeeper"
+
+// prompt - reads a line from the app input after writing the question to the error output
+func prompt(ctx *cli.Context, question string) (string, error) {
+	fmt.Fprint(ctx.App.ErrWriter, question)
+	line, err := bufio.NewReader(ctx.App.Reader).ReadString('\n')
+	line = strings.TrimSpace(line)
+	if line == "" && err != nil {
+		return "", fmt.Errorf("no input: %w", err)
+	}
+	return line, nil
+}
+
+// SecondFactor - returns the --otp flag or asks for the code when the server requires it
+func SecondFactor(ctx *cli.Context) func(context.Context) (string, error) {
+	return func(context.Context) (string, error) {
+		if code := ctx.String("otp"); code != "" {
+			return code, nil
+		}
+		return prompt(ctx, "two-factor code or recovery code: ")
+	}
+}
+
+// code - the --code flag of the command or the code asked from the user
+func code(ctx *cli.Context) (string, error) {
+	if c := ctx.String("code"); c != "" {
+		return c, nil
+	}
+	return prompt(ctx, "code from the authenticator app or recovery code: ")
+}
+
+// printRecoveryCodes - shows the recovery codes with a warning that they are shown once
+func printRecoveryCodes(codes []string) {
+	fmt.Println("recovery codes, each works once; keep them somewhere safe, they are not shown again:")
+	for _, c := range codes {
+		fmt.Println(c)
+	}
+}
+
+func enableTwoFactor(store storage.ClientStorage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 2 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		login := ctx.Args().Get(0)
+		_, err := store.Login(ctx.Context, login, ctx.Args().Get(1))
+		if err != nil {
+			return fmt.Errorf("error login happend: %w", explain(err))
+		}
+		secret, err := store.EnrollTOTP(ctx.Context)
+		if err != nil {
+			return fmt.Errorf("error enroll happend: %w", explain(err))
+		}
+		raw, err := totp.DecodeSecret(secret)
+		if err != nil {
+			return fmt.Errorf("error enroll happend: %w", err)
+		}
+		fmt.Println("add the key to your authenticator app: " + secret)
+		fmt.Println("or import the uri: " + totp.NewKey(raw).URI(issuer, login))
+		c, err := code(ctx)
+		if err != nil {
+			return err
+		}
+		codes, err := store.ConfirmTOTP(ctx.Context, c)
+		if err != nil {
+			return fmt.Errorf("error confirm happend: %w", explain(err))
+		}
+		fmt.Println("two-factor authentication enabled")
+		printRecoveryCodes(codes)
+		return nil
+	}
+}
+
+func disableTwoFactor(store storage.ClientStorage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 2 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		_, err := store.Login(ctx.Context, ctx.Args().Get(0), ctx.Args().Get(1))
+		if err != nil {
+			return fmt.Errorf("error login happend: %w", explain(err))
+		}
+		c, err := code(ctx)
+		if err != nil {
+			return err
+		}
+		if err = store.DisableTOTP(ctx.Context, c); err != nil {
+			return fmt.Errorf("error disable happend: %w", explain(err))
+		}
+		fmt.Println("two-factor authentication disabled")
+		return nil
+	}
+}
+
+func recoveryCodes(store storage.ClientStorage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 2 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		_, err := store.Login(ctx.Context, ctx.Args().Get(0), ctx.Args().Get(1))
+		if err != nil {
+			return fmt.Errorf("error login happend: %w", explain(err))
+		}
+		c, err := code(ctx)
+		if err != nil {
+			return err
+		}
+		codes, err := store.RegenerateRecoveryCodes(ctx.Context, c)
+		if err != nil {
+			return fmt.Errorf("error recovery codes happend: %w", explain(err))
+		}
+		printRecoveryCodes(codes)
+		return nil
+	}
+}
+
+// TwoFactor - used to manage two-factor authentication of the account
+func TwoFactor(store storage.ClientStorage) *cli.Command {
+	codeFlag := &cli.StringFlag{Name: "code", Usage: "current code of the authenticator app or a recovery code, asked when omitted"}
+	return &cli.Command{
+		Name:  "2fa",
+		Usage: "used to manage two-factor authentication; example: go run main.go 2fa enable login password",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "enable",
+				Usage:  "enroll an authenticator app and get recovery codes; example: go run main.go 2fa enable login password",
+				Flags:  []cli.Flag{codeFlag},
+				Action: traced("2fa enable", enableTwoFactor(store)),
+			},
+			{
+				Name:   "disable",
+				Usage:  "turn two-factor authentication off; example: go run main.go 2fa disable --code 123456 login password",
+				Flags:  []cli.Flag{codeFlag},
+				Action: traced("2fa disable", disableTwoFactor(store)),
+			},
+			{
+				Name:   "recovery-codes",
+				Usage:  "replace the recovery codes; example: go run main.go 2fa recovery-codes --code 123456 login password",
+				Flags:  []cli.Flag{codeFlag},
+				Action: traced("2fa recovery-codes", recoveryCodes(store)),
+			},
+		},
+	}
+}
@@ -0,0 +1,220 @@
+// Package apperrors provides typed errors shared by the server and the client
+// and their mapping to gRPC status codes with error details.
+package apperrors
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Domain - domain of ErrorInfo details
+const Domain = "gophkeeper"
+
+// Error kinds, match them with errors.Is
+var (
+	ErrNotFound        = errors.New("not found")
+	ErrConflict        = errors.New("conflict")
+	ErrUnauthenticated = errors.New("unauthenticated")
+	ErrPermission      = errors.New("permission denied")
+	ErrQuotaExceeded   = errors.New("quota exceeded")
+	ErrValidation      = errors.New("validation failed")
+	ErrUnavailable     = errors.New("server unavailable")
+	ErrInternal        = errors.New("internal error")
+)
+
+// FieldViolation - describes a single invalid field of a request
+type FieldViolation struct {
+	Field       string
+	Description string
+}
+
+// Error - typed error, Kind is one of the module error kinds
+type Error struct {
+	Kind       error
+	Reason     string
+	Message    string
+	Metadata   map[string]string
+	Violations []FieldViolation
+	RetryDelay time.Duration
+	Err        error
+}
+
+// Error returns the message of the error.
+func (e *Error) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = e.Kind.Error()
+	}
+	if len(e.Violations) > 0 {
+		parts := make([]string, 0, len(e.Violations))
+		for _, v := range e.Violations {
+			parts = append(parts, v.Field+": "+v.Description)
+		}
+		msg += " (" + strings.Join(parts, "; ") + ")"
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Is reports whether target is the kind of the error.
+func (e *Error) Is(target error) bool {
+	return e.Kind == target
+}
+
+// Unwrap returns the cause of the error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// NotFound creates an error for a missing entity.
+func NotFound(reason string, message string) *Error {
+	return &Error{Kind: ErrNotFound, Reason: reason, Message: message}
+}
+
+// Conflict creates an error for an entity that clashes with an existing one.
+func Conflict(reason string, message string) *Error {
+	return &Error{Kind: ErrConflict, Reason: reason, Message: message}
+}
+
+// Unauthenticated creates an error for missing or wrong credentials.
+func Unauthenticated(reason string, message string) *Error {
+	return &Error{Kind: ErrUnauthenticated, Reason: reason, Message: message}
+}
+
+// PermissionDenied creates an error for a caller that is known but not allowed to act.
+func PermissionDenied(reason string, message string) *Error {
+	return &Error{Kind: ErrPermission, Reason: reason, Message: message}
+}
+
+// QuotaExceeded creates an error for an exhausted limit.
+func QuotaExceeded(reason string, message string) *Error {
+	return &Error{Kind: ErrQuotaExceeded, Reason: reason, Message: message}
+}
+
+// Validation creates an error for invalid request fields.
+func Validation(message string, violations ...FieldViolation) *Error {
+	return &Error{Kind: ErrValidation, Reason: "INVALID_ARGUMENT", Message: message, Violations: violations}
+}
+
+// Internal wraps an unexpected error, its cause is never sent to the client.
+func Internal(err error) *Error {
+	return &Error{Kind: ErrInternal, Reason: "INTERNAL", Message: "internal error", Err: err}
+}
+
+// kinds - maps error kinds to grpc codes
+var kinds = []struct {
+	kind error
+	code codes.Code
+}{
+	{ErrNotFound, codes.NotFound},
+	{ErrConflict, codes.AlreadyExists},
+	{ErrUnauthenticated, codes.Unauthenticated},
+	{ErrPermission, codes.PermissionDenied},
+	{ErrQuotaExceeded, codes.ResourceExhausted},
+	{ErrValidation, codes.InvalidArgument},
+	{ErrUnavailable, codes.Unavailable},
+}
+
+// ToStatus converts err to a grpc status with ErrorInfo, BadRequest and RetryInfo details.
+// Errors that are not typed become codes.Internal without any details of the cause.
+func ToStatus(err error) *status.Status {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return status.Convert(err)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return status.New(codes.DeadlineExceeded, "request deadline exceeded")
+	}
+	if errors.Is(err, context.Canceled) {
+		return status.New(codes.Canceled, "request canceled")
+	}
+	var e *Error
+	if !errors.As(err, &e) {
+		e = Internal(err)
+	}
+	code := codes.Internal
+	for _, k := range kinds {
+		if e.Kind == k.kind {
+			code = k.code
+		}
+	}
+	msg := e.Message
+	if msg == "" {
+		msg = e.Kind.Error()
+	}
+	st := status.New(code, msg)
+	info := &errdetails.ErrorInfo{Reason: e.Reason, Domain: Domain, Metadata: e.Metadata}
+	withDetails, dErr := st.WithDetails(info)
+	if dErr != nil {
+		return st
+	}
+	if len(e.Violations) > 0 {
+		br := &errdetails.BadRequest{}
+		for _, v := range e.Violations {
+			br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{Field: v.Field, Description: v.Description})
+		}
+		if withViolations, vErr := withDetails.WithDetails(br); vErr == nil {
+			withDetails = withViolations
+		}
+	}
+	if e.RetryDelay > 0 {
+		if withRetry, rErr := withDetails.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(e.RetryDelay)}); rErr == nil {
+			withDetails = withRetry
+		}
+	}
+	return withDetails
+}
+
+// FromStatus converts a grpc error back into a typed error.
+func FromStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	e := &Error{Kind: ErrInternal, Message: st.Message()}
+	for _, k := range kinds {
+		if st.Code() == k.code {
+			e.Kind = k.kind
+		}
+	}
+	if st.Code() == codes.DeadlineExceeded || st.Code() == codes.Canceled {
+		e.Kind = ErrUnavailable
+	}
+	for _, d := range st.Details() {
+		switch v := d.(type) {
+		case *errdetails.ErrorInfo:
+			e.Reason = v.Reason
+			e.Metadata = v.Metadata
+		case *errdetails.BadRequest:
+			for _, fv := range v.FieldViolations {
+				e.Violations = append(e.Violations, FieldViolation{Field: fv.Field, Description: fv.Description})
+			}
+		case *errdetails.RetryInfo:
+			e.RetryDelay = v.RetryDelay.AsDuration()
+		}
+	}
+	return e
+}
+
+// Reason returns the machine readable reason of a typed error.
+func Reason(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Reason
+	}
+	return ""
+}
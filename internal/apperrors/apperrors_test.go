@@ -0,0 +1,46 @@
+package apperrors
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+)
+
+func TestToStatus_FromStatus(t *testing.T) {
+	err := Validation("invalid data", FieldViolation{Field: "data.data_id", Description: "must not be empty"})
+	st := ToStatus(fmt.Errorf("add: %w", err))
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	assert.Len(t, st.Details(), 2)
+
+	back := FromStatus(st.Err())
+	assert.True(t, errors.Is(back, ErrValidation))
+	var e *Error
+	assert.True(t, errors.As(back, &e))
+	assert.Equal(t, "INVALID_ARGUMENT", e.Reason)
+	assert.Equal(t, []FieldViolation{{Field: "data.data_id", Description: "must not be empty"}}, e.Violations)
+}
+
+func TestToStatus_Internal(t *testing.T) {
+	st := ToStatus(errors.New("pq: connection refused"))
+	assert.Equal(t, codes.Internal, st.Code())
+	assert.Equal(t, "internal error", st.Message())
+	assert.True(t, errors.Is(FromStatus(st.Err()), ErrInternal))
+}
+
+func TestError_Is(t *testing.T) {
+	err := fmt.Errorf("login: %w", NotFound("USER_NOT_FOUND", "user not found"))
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.False(t, errors.Is(err, ErrConflict))
+	assert.Equal(t, "USER_NOT_FOUND", Reason(err))
+}
+
+func TestPermissionDenied(t *testing.T) {
+	st := ToStatus(PermissionDenied("ACCOUNT_DISABLED", "account is disabled"))
+	assert.Equal(t, codes.PermissionDenied, st.Code())
+	back := FromStatus(st.Err())
+	assert.True(t, errors.Is(back, ErrPermission))
+	assert.Equal(t, "ACCOUNT_DISABLED", Reason(back))
+}
@@ -0,0 +1,125 @@
+// Package config provides client profiles and server settings.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gophkeeper/internal/lockout"
+	"gophkeeper/internal/ratelimit"
+	files "gophkeeper/internal/storage/filereaders"
+)
+
+// DefaultProfile - name of the profile used when none is selected
+const DefaultProfile = "default"
+
+// profilesFile - file with client profiles in the directory of the client files
+const profilesFile = "profiles.json"
+
+// Module errors
+var (
+	ErrProfileNotFound = errors.New("profile not found")
+)
+
+// Duration - time.Duration that is written to json as a string like "5s"
+type Duration time.Duration
+
+// MarshalJSON encodes the duration as a string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON decodes the duration from a string.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Profile - client settings used to reach a server
+type Profile struct {
+	Name           string   `json:"Name"`
+	Address        string   `json:"Address"`
+	RequestTimeout Duration `json:"RequestTimeout"`
+}
+
+// NewProfile returns a profile with default settings.
+func NewProfile() Profile {
+	return Profile{Name: DefaultProfile, Address: ":3200", RequestTimeout: Duration(10 * time.Second)}
+}
+
+// LoadProfile reads the profile with the given name from profiles.json of the directory of the client files,
+// so the profiles are found from any working directory. If the file does not exist the default profile is returned.
+func LoadProfile(name string) (Profile, error) {
+	if name == "" {
+		name = DefaultProfile
+	}
+	dir, err := files.Dir()
+	if err != nil {
+		return Profile{}, err
+	}
+	b, err := os.ReadFile(filepath.Join(dir, profilesFile))
+	if errors.Is(err, os.ErrNotExist) {
+		if name != DefaultProfile {
+			return Profile{}, ErrProfileNotFound
+		}
+		return NewProfile(), nil
+	}
+	if err != nil {
+		return Profile{}, errors.New("failed to open file")
+	}
+	profiles := make(map[string]Profile)
+	if err = json.Unmarshal(b, &profiles); err != nil {
+		return Profile{}, errors.New("failed to decode profiles")
+	}
+	p, ok := profiles[name]
+	if !ok {
+		if name != DefaultProfile {
+			return Profile{}, ErrProfileNotFound
+		}
+		return NewProfile(), nil
+	}
+	def := NewProfile()
+	p.Name = name
+	if p.Address == "" {
+		p.Address = def.Address
+	}
+	if p.RequestTimeout <= 0 {
+		p.RequestTimeout = def.RequestTimeout
+	}
+	return p, nil
+}
+
+// Server - server settings
+type Server struct {
+	Address            string
+	DSN                string
+	MaxRequestDuration time.Duration
+	DrainTimeout       time.Duration
+	HealthInterval     time.Duration
+	Reflection         bool
+	LogLevel           string
+	LogFormat          string
+	MetricsAddress     string
+	TraceExporter      string
+	TraceFile          string
+	HTTPAddress        string
+	TLSCert            string
+	TLSKey             string
+	RevocationInterval time.Duration
+	TrashRetention     time.Duration
+	DeviceExpiry       time.Duration
+	PurgeInterval      time.Duration
+	LoginPolicy        lockout.Policy
+	RateLimits         ratelimit.Config
+}
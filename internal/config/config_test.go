@@ -0,0 +1,47 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	files "gophkeeper/internal/storage/filereaders"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ExampleDuration_MarshalJSON() {
+	b, _ := json.Marshal(Profile{Name: "work", Address: ":3200", RequestTimeout: Duration(5 * time.Second)})
+	fmt.Println(string(b))
+	var p Profile
+	_ = json.Unmarshal(b, &p)
+	fmt.Println(time.Duration(p.RequestTimeout))
+	//Output:
+	//{"Name":"work","Address":":3200","RequestTimeout":"5s"}
+	//5s
+}
+
+func TestLoadProfile(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	p, err := LoadProfile("")
+	require.NoError(t, err)
+	assert.Equal(t, NewProfile(), p, "without profiles.json the default profile is used")
+	_, err = LoadProfile("work")
+	assert.ErrorIs(t, err, ErrProfileNotFound)
+
+	dir, err := files.Dir()
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, profilesFile), []byte(`{"work": {"Address": "keeper:3200"}}`), 0600))
+	// git runs the credential helper in the repository, the profiles are not read from the working directory
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(t.TempDir()))
+	defer os.Chdir(wd)
+	p, err = LoadProfile("work")
+	require.NoError(t, err)
+	assert.Equal(t, Profile{Name: "work", Address: "keeper:3200", RequestTimeout: NewProfile().RequestTimeout}, p)
+}
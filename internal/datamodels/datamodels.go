@@ -1,19 +1,56 @@
 // Package datamodels represents structs used in program
 package datamodels
 
-import "time"
+import (
+	"log/slog"
+	"time"
+)
 
 // Auth - struct used to save info about new user
 type Auth struct {
-	ID       uint32 `json:"ID"`
-	Login    string `json:"Login"`
-	Password string `json:"Password"`
+	ID         uint32    `json:"ID"`
+	Login      string    `json:"Login"`
+	Password   string    `json:"Password"`
+	VaultKey   *VaultKey `json:"VaultKey,omitempty"`
+	PendingKey *VaultKey `json:"PendingKey,omitempty"`
 }
 
 // Login - struct for login
 type Login struct {
 	ID       uint32 `json:"ID"`
 	Password string `json:"Password"`
+	// VaultKey - key of the local records wrapped with the password
+	VaultKey *VaultKey `json:"VaultKey,omitempty"`
+	// PendingKey - the same key wrapped with the new password of a password change not finished yet
+	PendingKey *VaultKey `json:"PendingKey,omitempty"`
+}
+
+// VaultKey - key of the local records encrypted with a key derived from the password and the salt
+type VaultKey struct {
+	Wrapped string `json:"Wrapped"`
+	Salt    string `json:"Salt"`
+}
+
+// Session - unlocked session of the client kept in the memory of the session agent between commands
+type Session struct {
+	Login   string    `json:"login"`
+	ID      uint32    `json:"id"`
+	Token   string    `json:"token"`
+	Key     []byte    `json:"key"`
+	Expires time.Time `json:"expires"`
+}
+
+// UserStats - account state and storage usage of a user shown to server operators
+type UserStats struct {
+	ID           uint32
+	Login        string
+	Disabled     bool
+	TOTP         bool
+	FailedLogins int
+	LockedUntil  time.Time
+	Records      int
+	Deleted      int
+	Bytes        int64
 }
 
 // Data - struct for all information about 1 note
@@ -24,6 +61,32 @@ type Data struct {
 	Metadata  string    `json:"Metadata"`
 	ChangedAt time.Time `json:"ChangedAt"`
 	Deleted   bool      `json:"Deleted"`
+	// Revision - revision of the record on the server, 0 when unknown
+	Revision uint32 `json:"Revision,omitempty"`
+}
+
+// LogValue - hides Data and Metadata from logs
+func (d Data) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Any("user_id", d.UserID),
+		slog.String("data_id", d.DataID),
+		slog.Time("changed_at", d.ChangedAt),
+		slog.Bool("deleted", d.Deleted),
+	)
+}
+
+// Version - revision of a record kept in the history
+type Version struct {
+	Revision  uint32    `json:"revision"`
+	ChangedAt time.Time `json:"changed_at"`
+	Current   bool      `json:"current"`
+}
+
+// Device - client installation of a user, deleted records are purged after every device synchronized them
+type Device struct {
+	ID string `json:"id"`
+	// AckedAt - server time of the last synchronization of the device
+	AckedAt time.Time `json:"acked_at"`
 }
 
 // UniqueData - unique constraint from database for in memory storage
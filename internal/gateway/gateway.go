@@ -0,0 +1,108 @@
+// Package gateway provides the REST/JSON gateway to the Gophkeeper gRPC service.
+package gateway
+
+import (
+	"context"
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"strings"
+
+	pb "gophkeeper/proto"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+// SessionHeader - response header of /v1/login with the session token
+const SessionHeader = "X-Session-Token"
+
+// sessionKey - metadata key of the session token in the gRPC service
+const sessionKey = "userid"
+
+// Metadata keys of the address of the http client, set by the gateway only
+const (
+	privatePrefix = "gophkeeper-gateway-"
+	peerKey       = privatePrefix + "peer"
+	secretKey     = privatePrefix + "secret"
+)
+
+// New creates the http handler that proxies JSON requests to the gRPC service at endpoint.
+// The address of the http client is passed along with secret, so the service can tell it from metadata set by other callers.
+// The handler also serves the OpenAPI document at /openapi.json.
+func New(ctx context.Context, endpoint string, secret string) (http.Handler, error) {
+	gw := runtime.NewServeMux(
+		runtime.WithMetadata(sessionMetadata),
+		runtime.WithMetadata(peerMetadata(secret)),
+		runtime.WithIncomingHeaderMatcher(incomingHeader),
+		runtime.WithOutgoingHeaderMatcher(outgoingHeader),
+	)
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(otelgrpc.UnaryClientInterceptor()),
+	}
+	if err := pb.RegisterGophkeeperHandlerFromEndpoint(ctx, gw, endpoint, opts); err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/v1/", gw)
+	mux.HandleFunc("/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(pb.OpenAPI)
+	})
+	return mux, nil
+}
+
+// sessionMetadata - passes the bearer token of the Authorization header as the session token
+func sessionMetadata(ctx context.Context, r *http.Request) metadata.MD {
+	auth := r.Header.Get("Authorization")
+	token, ok := strings.CutPrefix(auth, "Bearer ")
+	if !ok || token == "" {
+		return nil
+	}
+	return metadata.Pairs(sessionKey, strings.TrimSpace(token))
+}
+
+// peerMetadata - passes the address of the http client, X-Forwarded-For is not trusted
+func peerMetadata(secret string) func(ctx context.Context, r *http.Request) metadata.MD {
+	return func(ctx context.Context, r *http.Request) metadata.MD {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			return nil
+		}
+		return metadata.Pairs(peerKey, host, secretKey, secret)
+	}
+}
+
+// incomingHeader - default mapping of http headers to metadata, clients can not set the private keys of the gateway
+func incomingHeader(key string) (string, bool) {
+	name, ok := runtime.DefaultHeaderMatcher(key)
+	if ok && strings.HasPrefix(strings.ToLower(name), privatePrefix) {
+		return "", false
+	}
+	return name, ok
+}
+
+// ClientIP returns the address of the http client of a request proxied by the gateway holding secret,
+// false is returned for requests of other callers.
+func ClientIP(md metadata.MD, secret string) (string, bool) {
+	secrets, peers := md.Get(secretKey), md.Get(peerKey)
+	if secret == "" || len(secrets) != 1 || len(peers) != 1 {
+		return "", false
+	}
+	if subtle.ConstantTimeCompare([]byte(secrets[0]), []byte(secret)) != 1 {
+		return "", false
+	}
+	return peers[0], true
+}
+
+// outgoingHeader - returns the session token of Login as SessionHeader and hides other metadata
+func outgoingHeader(key string) (string, bool) {
+	if key == sessionKey {
+		return SessionHeader, true
+	}
+	return "", false
+}
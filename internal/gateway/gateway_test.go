@@ -0,0 +1,81 @@
+package gateway
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	pb "gophkeeper/proto"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type fakeKeeper struct {
+	pb.UnimplementedGophkeeperServer
+	// client - address of the http client seen by the service
+	client string
+}
+
+func (f *fakeKeeper) Login(ctx context.Context, in *pb.AuthLoginRequest) (*pb.AuthLoginResponse, error) {
+	_ = grpc.SetHeader(ctx, metadata.Pairs(sessionKey, "token-"+in.Login))
+	return &pb.AuthLoginResponse{Id: 7}, nil
+}
+
+func (f *fakeKeeper) GetData(ctx context.Context, in *pb.GetDataRequest) (*pb.GetDataResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	f.client, _ = ClientIP(md, "secret")
+	if v := md.Get(sessionKey); len(v) == 0 || v[0] != "token-user" {
+		return nil, status.Error(codes.Unauthenticated, "user unauthenticated")
+	}
+	return &pb.GetDataResponse{Data: &pb.Data{DataId: in.DataId, Data: "value"}}, nil
+}
+
+func TestGateway(t *testing.T) {
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := grpc.NewServer()
+	keeper := &fakeKeeper{}
+	pb.RegisterGophkeeperServer(s, keeper)
+	go s.Serve(listen)
+	defer s.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	handler, err := New(ctx, listen.Addr().String(), "secret")
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/login", strings.NewReader(`{"login":"user","password":"1"}`)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	token := rec.Header().Get(SessionHeader)
+	assert.Equal(t, "token-user", token)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/data/card", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/data/card", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("X-Forwarded-For", "10.0.0.1")
+	req.Header.Set("Grpc-Metadata-Gophkeeper-Gateway-Peer", "10.0.0.2")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"dataId":"card"`)
+	assert.Equal(t, "192.0.2.1", keeper.client, "only the address of the http connection is passed")
+
+	_, ok := ClientIP(metadata.Pairs(peerKey, "10.0.0.3", secretKey, "guess"), "secret")
+	assert.False(t, ok, "callers without the secret are not trusted")
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	assert.Contains(t, rec.Body.String(), `"swagger": "2.0"`)
+}
@@ -0,0 +1,123 @@
+// Package gitcred implements the git credential helper protocol over login records.
+package gitcred
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"gophkeeper/internal/datamodels"
+	"gophkeeper/internal/records"
+)
+
+// Credential - attributes of the git credential helper protocol
+type Credential struct {
+	Protocol string
+	Host     string
+	Path     string
+	Username string
+	Password string
+}
+
+// Read parses key=value lines until an empty line or EOF, an url attribute fills protocol, host and path.
+func Read(r io.Reader) (Credential, error) {
+	var c Credential
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			break
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return Credential{}, fmt.Errorf("invalid credential line %q", key)
+		}
+		switch key {
+		case "protocol":
+			c.Protocol = value
+		case "host":
+			c.Host = value
+		case "path":
+			c.Path = value
+		case "username":
+			c.Username = value
+		case "password":
+			c.Password = value
+		case "url":
+			u, err := url.Parse(value)
+			if err != nil {
+				return Credential{}, fmt.Errorf("invalid credential url: %w", err)
+			}
+			c.Protocol, c.Host, c.Path = u.Scheme, u.Host, strings.TrimPrefix(u.Path, "/")
+			if u.User != nil {
+				c.Username = u.User.Username()
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Credential{}, err
+	}
+	if c.Host == "" {
+		return Credential{}, fmt.Errorf("credential without host")
+	}
+	return c, nil
+}
+
+// Write writes the username and password of the credential for git.
+func Write(w io.Writer, c Credential) error {
+	_, err := fmt.Fprintf(w, "username=%s\npassword=%s\n", c.Username, c.Password)
+	return err
+}
+
+// DataID returns the data id of new records of the host, records are also found by the urls of their metadata.
+func DataID(c Credential) string {
+	return "git/" + c.Host
+}
+
+// Match returns the credential of a login record for the host: the record has an url of the host and protocol
+// or the data id is the host or git/host. The username must match when git knows it.
+func Match(d datamodels.Data, c Credential) (Credential, bool) {
+	r := records.Decode(d.Data)
+	if r.Kind != records.KindLogin || r.Login == nil {
+		return Credential{}, false
+	}
+	if c.Username != "" && r.Login.Username != c.Username {
+		return Credential{}, false
+	}
+	found := d.DataID == c.Host || d.DataID == DataID(c)
+	for _, raw := range records.DecodeMeta(d.Metadata).URLs {
+		u, err := url.Parse(raw)
+		found = found || err == nil && strings.EqualFold(u.Host, c.Host) && (c.Protocol == "" || u.Scheme == c.Protocol)
+	}
+	if !found {
+		return Credential{}, false
+	}
+	c.Username, c.Password = r.Login.Username, r.Login.Password
+	return c, true
+}
+
+// Find returns the first record of the list with a credential for the host.
+func Find(data []datamodels.Data, c Credential) (datamodels.Data, Credential, bool) {
+	for _, d := range data {
+		if found, ok := Match(d, c); ok {
+			return d, found, true
+		}
+	}
+	return datamodels.Data{}, Credential{}, false
+}
+
+// NewRecord returns a login record of the credential with the url of the host.
+func NewRecord(c Credential) (datamodels.Data, error) {
+	data, err := records.Encode(records.Record{Kind: records.KindLogin, Login: &records.Login{Username: c.Username, Password: c.Password}})
+	if err != nil {
+		return datamodels.Data{}, err
+	}
+	protocol := c.Protocol
+	if protocol == "" {
+		protocol = "https"
+	}
+	meta := records.EncodeMeta(records.Meta{URLs: []string{protocol + "://" + c.Host}, Tags: []string{"git"}})
+	return datamodels.Data{DataID: DataID(c), Data: data, Metadata: meta}, nil
+}
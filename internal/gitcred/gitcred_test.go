@@ -0,0 +1,63 @@
+package gitcred
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"gophkeeper/internal/datamodels"
+	"gophkeeper/internal/records"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRead(t *testing.T) {
+	c, err := Read(strings.NewReader("protocol=https\nhost=github.com\nusername=octocat\n\nignored=1\n"))
+	require.NoError(t, err)
+	assert.Equal(t, Credential{Protocol: "https", Host: "github.com", Username: "octocat"}, c)
+
+	c, err = Read(strings.NewReader("url=https://bob@git.example.com:8443/team/repo.git\n"))
+	require.NoError(t, err)
+	assert.Equal(t, Credential{Protocol: "https", Host: "git.example.com:8443", Path: "team/repo.git", Username: "bob"}, c)
+
+	_, err = Read(strings.NewReader("protocol=https\n"))
+	assert.Error(t, err)
+}
+
+func TestFind(t *testing.T) {
+	login := func(username, password string) string {
+		data, err := records.Encode(records.Record{Kind: records.KindLogin, Login: &records.Login{Username: username, Password: password}})
+		require.NoError(t, err)
+		return data
+	}
+	data := []datamodels.Data{
+		{DataID: "github.com", Data: "not a login"},
+		{DataID: "work", Data: login("bob", "token1"), Metadata: records.EncodeMeta(records.Meta{URLs: []string{"https://git.example.com"}})},
+		{DataID: "github.com", Data: login("octocat", "token2")},
+	}
+
+	d, c, ok := Find(data, Credential{Protocol: "https", Host: "git.example.com"})
+	require.True(t, ok)
+	assert.Equal(t, "work", d.DataID)
+	assert.Equal(t, "token1", c.Password)
+
+	_, _, ok = Find(data, Credential{Protocol: "ssh", Host: "git.example.com"})
+	assert.False(t, ok, "protocol of the url must match")
+	_, _, ok = Find(data, Credential{Host: "github.com", Username: "someone"})
+	assert.False(t, ok)
+	_, c, ok = Find(data, Credential{Host: "github.com"})
+	require.True(t, ok)
+	assert.Equal(t, "octocat", c.Username)
+
+	var b bytes.Buffer
+	require.NoError(t, Write(&b, c))
+	assert.Equal(t, "username=octocat\npassword=token2\n", b.String())
+
+	d, err := NewRecord(Credential{Host: "gitlab.com", Username: "me", Password: "pw"})
+	require.NoError(t, err)
+	_, c, ok = Find([]datamodels.Data{d}, Credential{Protocol: "https", Host: "gitlab.com"})
+	require.True(t, ok)
+	assert.Equal(t, "pw", c.Password)
+	assert.Equal(t, "git/gitlab.com", d.DataID)
+}
@@ -0,0 +1,102 @@
+package grpcfuncs
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"gophkeeper/internal/apperrors"
+	"gophkeeper/internal/datamodels"
+	"gophkeeper/internal/utils"
+	pb "gophkeeper/proto"
+
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// ChangePassword replaces the password of the user and revokes the other sessions of the user.
+func (g *GophKeeperServer) ChangePassword(ctx context.Context, in *pb.ChangePasswordRequest) (*emptypb.Empty, error) {
+	id, err := g.session(ctx)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	if in.NewPassword == "" {
+		return nil, mapErr(apperrors.Validation("invalid password", apperrors.FieldViolation{Field: "new_password", Description: "must not be empty"}))
+	}
+	login, err := g.db.UserLogin(ctx, id)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	ip := g.peerIP(ctx)
+	if err = g.guard.Check(ctx, login, ip); err != nil {
+		return nil, mapErr(err)
+	}
+	// the session already passed the second factor, the old password proves the user is still at the keyboard
+	err = g.db.ChangePassword(ctx, id, utils.GetMD5Hash(in.OldPassword), utils.GetMD5Hash(in.NewPassword))
+	if errors.Is(err, apperrors.ErrUnauthenticated) {
+		if gErr := g.guard.Failure(ctx, login, ip); gErr != nil {
+			return nil, mapErr(gErr)
+		}
+	}
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	if err = g.guard.Success(ctx, login); err != nil {
+		return nil, mapErr(err)
+	}
+	revoked := g.users.RevokeUser(id, GetUserId(ctx))
+	slog.InfoContext(ctx, "password changed", slog.Any("user_id", id), slog.Int("revoked_sessions", revoked))
+	return new(emptypb.Empty), nil
+}
+
+// DeleteAccount removes the user with all records and sessions, it needs the password and the second factor again.
+// The code the session was opened with is used up, a new one is needed.
+func (g *GophKeeperServer) DeleteAccount(ctx context.Context, in *pb.DeleteAccountRequest) (*emptypb.Empty, error) {
+	id, err := g.session(ctx)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	login, err := g.db.UserLogin(ctx, id)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	ip := g.peerIP(ctx)
+	if err = g.guard.Check(ctx, login, ip); err != nil {
+		return nil, mapErr(err)
+	}
+	// the password is checked first, so a wrong password does not use up a recovery code
+	passHash := utils.GetMD5Hash(in.Password)
+	_, err = g.db.Login(ctx, login, passHash)
+	if err == nil {
+		err = g.secondFactor(ctx, id, in.OtpCode)
+	}
+	if err == nil {
+		err = g.db.DeleteUser(ctx, id, passHash)
+	}
+	if errors.Is(err, apperrors.ErrUnauthenticated) && !errors.Is(err, ErrOTPRequired) {
+		if gErr := g.guard.Failure(ctx, login, ip); gErr != nil {
+			return nil, mapErr(gErr)
+		}
+	}
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	revoked := g.users.RevokeUser(id, "")
+	slog.InfoContext(ctx, "account deleted", slog.Any("user_id", id), slog.Int("revoked_sessions", revoked))
+	return new(emptypb.Empty), nil
+}
+
+// ExportAccount streams every record of the user including deleted ones.
+func (g *GophKeeperServer) ExportAccount(in *emptypb.Empty, stream pb.Gophkeeper_ExportAccountServer) error {
+	ctx := stream.Context()
+	id, err := g.session(ctx)
+	if err != nil {
+		return mapErr(err)
+	}
+	err = g.db.Export(ctx, id, func(d datamodels.Data) error {
+		return stream.Send(toPB(d))
+	})
+	if err != nil {
+		return mapErr(err)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package grpcfuncs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gophkeeper/internal/apperrors"
+	"gophkeeper/internal/datamodels"
+	"gophkeeper/internal/lockout"
+	"gophkeeper/internal/sessionstorage"
+	"gophkeeper/internal/storage"
+	"gophkeeper/internal/totp"
+	"gophkeeper/internal/utils"
+	pb "gophkeeper/proto"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// accountStorage - storage of a single user that supports password changes, deletion and export
+type accountStorage struct {
+	totpStorage
+	records []datamodels.Data
+	deleted bool
+	failed  []string
+}
+
+func (s *accountStorage) UserLogin(ctx context.Context, userID uint32) (string, error) {
+	return "login", nil
+}
+
+func (s *accountStorage) RecordLoginFailure(ctx context.Context, login string) (int, error) {
+	s.failed = append(s.failed, login)
+	return len(s.failed), nil
+}
+
+func (s *accountStorage) DeleteUser(ctx context.Context, userID uint32, password string) error {
+	if password != s.password {
+		return storage.ErrWrongPassword
+	}
+	s.deleted, s.records = true, nil
+	return nil
+}
+
+func (s *accountStorage) Export(ctx context.Context, userID uint32, send func(datamodels.Data) error) error {
+	for _, r := range s.records {
+		if err := send(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportStream - server stream that collects the sent records
+type exportStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent []*pb.Data
+}
+
+func (s *exportStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *exportStream) Send(d *pb.Data) error {
+	s.sent = append(s.sent, d)
+	return nil
+}
+
+func (s *accountStorage) ChangePassword(ctx context.Context, userID uint32, oldPassword string, newPassword string) error {
+	if oldPassword != s.password {
+		return storage.ErrWrongPassword
+	}
+	s.password = newPassword
+	return nil
+}
+
+func TestChangePassword(t *testing.T) {
+	db := &accountStorage{totpStorage: totpStorage{password: utils.GetMD5Hash("old")}}
+	g := GophKeeperServer{db: db, users: sessionstorage.NewAuthUsersStorage(), now: time.Now}
+	g.guard = lockout.NewGuard(lockout.NewPolicy(), db)
+	require.NoError(t, g.users.AddUser("current", 1))
+	require.NoError(t, g.users.AddUser("other", 1))
+	require.NoError(t, g.users.AddUser("stranger", 2))
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("userid", "current"))
+
+	_, err := g.ChangePassword(ctx, &pb.ChangePasswordRequest{OldPassword: "old"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = g.ChangePassword(ctx, &pb.ChangePasswordRequest{OldPassword: "wrong", NewPassword: "new"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = g.users.GetUser("other")
+	assert.NoError(t, err, "failed change keeps sessions")
+	assert.Equal(t, []string{"login"}, db.failed, "a wrong old password counts toward the lockout of the login")
+
+	_, err = g.ChangePassword(ctx, &pb.ChangePasswordRequest{OldPassword: "old", NewPassword: "new"})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err), "the failure blocks the peer for a second")
+	g.guard = lockout.NewGuard(lockout.NewPolicy(), db)
+	_, err = g.ChangePassword(ctx, &pb.ChangePasswordRequest{OldPassword: "old", NewPassword: "new"})
+	require.NoError(t, err)
+	assert.Equal(t, utils.GetMD5Hash("new"), db.password)
+
+	_, err = g.users.GetUser("current")
+	assert.NoError(t, err)
+	_, err = g.users.GetUser("other")
+	assert.Error(t, err)
+	_, err = g.users.GetUser("stranger")
+	assert.NoError(t, err)
+}
+
+func TestDeleteAndExportAccount(t *testing.T) {
+	db := &accountStorage{totpStorage: totpStorage{password: utils.GetMD5Hash("password")}}
+	db.records = []datamodels.Data{
+		{DataID: "a", Data: "secret"},
+		{DataID: "b", Deleted: true},
+	}
+	g := GophKeeperServer{db: db, users: sessionstorage.NewAuthUsersStorage(), now: time.Now}
+	g.guard = lockout.NewGuard(lockout.Policy{MaxFailures: 100, Lockout: time.Minute}, db)
+	require.NoError(t, g.users.AddUser("current", 1))
+	require.NoError(t, g.users.AddUser("other", 1))
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("userid", "current"))
+
+	err := g.ExportAccount(&emptypb.Empty{}, &exportStream{ctx: context.Background()})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	stream := &exportStream{ctx: ctx}
+	require.NoError(t, g.ExportAccount(&emptypb.Empty{}, stream))
+	require.Len(t, stream.sent, 2)
+	assert.Equal(t, "secret", stream.sent[0].Data)
+	assert.True(t, stream.sent[1].Deleted, "tombstones are exported")
+
+	_, err = g.DeleteAccount(ctx, &pb.DeleteAccountRequest{Password: "wrong"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	assert.False(t, db.deleted)
+
+	db.secret, db.enabled = "GEZDGNBVGY3TQOJQ", true
+	_, err = g.DeleteAccount(ctx, &pb.DeleteAccountRequest{Password: "password"})
+	assert.Equal(t, "OTP_REQUIRED", apperrors.Reason(apperrors.FromStatus(err)))
+	recovery := "abcde-12345"
+	db.recovery = map[string]bool{totp.HashRecoveryCode(recovery): true}
+	_, err = g.DeleteAccount(ctx, &pb.DeleteAccountRequest{Password: "wrong", OtpCode: recovery})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	assert.True(t, db.recovery[totp.HashRecoveryCode(recovery)], "a wrong password does not use up the recovery code")
+	assert.Equal(t, []string{"login", "login"}, db.failed, "wrong passwords count toward the lockout of the login")
+
+	// the code the session was opened with is rejected like any code used before
+	delete(db.recovery, totp.HashRecoveryCode(recovery))
+	_, err = g.DeleteAccount(ctx, &pb.DeleteAccountRequest{Password: "password", OtpCode: recovery})
+	assert.Equal(t, "OTP_INVALID", apperrors.Reason(apperrors.FromStatus(err)), "a used code does not confirm the deletion")
+	assert.False(t, db.deleted)
+	fresh := "fghij-67890"
+	db.recovery[totp.HashRecoveryCode(fresh)] = true
+	_, err = g.DeleteAccount(ctx, &pb.DeleteAccountRequest{Password: "password", OtpCode: fresh})
+	require.NoError(t, err)
+	assert.True(t, db.deleted)
+	assert.Equal(t, 0, g.users.Len(), "every session is revoked")
+}
@@ -0,0 +1,78 @@
+package grpcfuncs
+
+import (
+	"context"
+
+	"gophkeeper/internal/apperrors"
+	"gophkeeper/internal/datamodels"
+	"gophkeeper/internal/records"
+	"gophkeeper/internal/storage"
+	pb "gophkeeper/proto"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// toPB - converts a record to its message with the structured meta information
+func toPB(d datamodels.Data) *pb.Data {
+	m := records.DecodeMeta(d.Metadata)
+	return &pb.Data{
+		DataId:    d.DataID,
+		Data:      d.Data,
+		MetaInfo:  d.Metadata,
+		Deleted:   d.Deleted,
+		ChangedAt: timestamppb.New(d.ChangedAt),
+		Revision:  d.Revision,
+		Meta:      &pb.Meta{Tags: m.Tags, Folder: m.Folder, Urls: m.URLs, Notes: m.Notes, Fields: m.Fields, Favorite: m.Favorite},
+	}
+}
+
+// metaInfo - returns the meta information of the message, the structured one wins over meta_info
+func metaInfo(in *pb.Data) string {
+	if in.Meta == nil {
+		return in.MetaInfo
+	}
+	m := in.Meta
+	return records.EncodeMeta(records.Meta{Tags: m.Tags, Folder: m.Folder, URLs: m.Urls, Notes: m.Notes, Fields: m.Fields, Favorite: m.Favorite})
+}
+
+// UpdateData handles the request to change some fields of a record.
+func (g *GophKeeperServer) UpdateData(ctx context.Context, in *pb.UpdateDataRequest) (*pb.UpdateDataResponse, error) {
+	id, err := g.session(ctx)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	var violations []apperrors.FieldViolation
+	if in.DataId == "" {
+		violations = append(violations, apperrors.FieldViolation{Field: "data_id", Description: "must not be empty"})
+	}
+	if in.Data == nil {
+		in.Data = new(pb.Data)
+	}
+	var mask []string
+	for _, path := range in.GetUpdateMask().GetPaths() {
+		switch path {
+		case storage.MaskDataID:
+			if in.Data.DataId == "" {
+				violations = append(violations, apperrors.FieldViolation{Field: "data.data_id", Description: "must not be empty"})
+			}
+		case storage.MaskData:
+		case storage.MaskMetaInfo, "meta":
+			path = storage.MaskMetaInfo
+		default:
+			violations = append(violations, apperrors.FieldViolation{Field: "update_mask", Description: "unknown field " + path})
+		}
+		mask = append(mask, path)
+	}
+	if len(mask) == 0 {
+		violations = append(violations, apperrors.FieldViolation{Field: "update_mask", Description: "must not be empty"})
+	}
+	if violations != nil {
+		return nil, mapErr(apperrors.Validation("invalid update", violations...))
+	}
+	data := datamodels.Data{DataID: in.Data.DataId, Data: in.Data.Data, Metadata: metaInfo(in.Data), ChangedAt: g.now()}
+	updated, err := g.db.UpdateData(ctx, id, in.DataId, data, mask, in.Revision)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return &pb.UpdateDataResponse{Data: &pb.Data{DataId: updated.DataID, ChangedAt: timestamppb.New(updated.ChangedAt), Revision: updated.Revision}}, nil
+}
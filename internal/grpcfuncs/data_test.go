@@ -0,0 +1,94 @@
+package grpcfuncs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gophkeeper/internal/datamodels"
+	"gophkeeper/internal/sessionstorage"
+	"gophkeeper/internal/storage"
+	pb "gophkeeper/proto"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func TestMetaRoundTrip(t *testing.T) {
+	meta := &pb.Meta{Tags: []string{"work"}, Folder: "work/servers", Urls: []string{"https://example.com"}, Notes: "n", Fields: map[string]string{"port": "22"}, Favorite: true}
+	in := &pb.Data{DataId: "ssh", MetaInfo: "ignored", Meta: meta}
+
+	out := toPB(datamodels.Data{DataID: "ssh", Metadata: metaInfo(in), ChangedAt: time.Now()})
+	require.NotNil(t, out.Meta)
+	assert.Equal(t, meta.Fields, out.Meta.Fields)
+	assert.Equal(t, meta.Folder, out.Meta.Folder)
+	assert.True(t, out.Meta.Favorite)
+
+	assert.Equal(t, "plain notes", metaInfo(&pb.Data{MetaInfo: "plain notes"}))
+	assert.Equal(t, "plain notes", toPB(datamodels.Data{Metadata: "plain notes"}).Meta.Notes)
+}
+
+// updateStorage - storage with one record and its revision
+type updateStorage struct {
+	storage.ServerStorage
+	record datamodels.Data
+	mask   []string
+}
+
+func (s *updateStorage) UpdateData(ctx context.Context, userID uint32, dataID string, data datamodels.Data, mask []string, revision uint32) (datamodels.Data, error) {
+	if dataID != s.record.DataID {
+		return datamodels.Data{}, storage.ErrNotFound
+	}
+	if revision != 0 && revision != s.record.Revision {
+		return datamodels.Data{}, storage.ErrRevision
+	}
+	s.mask = mask
+	for _, path := range mask {
+		switch path {
+		case storage.MaskDataID:
+			s.record.DataID = data.DataID
+		case storage.MaskData:
+			s.record.Data = data.Data
+		case storage.MaskMetaInfo:
+			s.record.Metadata = data.Metadata
+		}
+	}
+	s.record.Revision++
+	s.record.ChangedAt = data.ChangedAt
+	return s.record, nil
+}
+
+func TestUpdateData(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	db := &updateStorage{record: datamodels.Data{DataID: "a", Data: "old", Metadata: "notes", Revision: 3}}
+	g := GophKeeperServer{db: db, users: sessionstorage.NewAuthUsersStorage(), now: func() time.Time { return now }}
+	require.NoError(t, g.users.AddUser("token", 1))
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("userid", "token"))
+
+	_, err := g.UpdateData(ctx, &pb.UpdateDataRequest{DataId: "a", Data: &pb.Data{Data: "new"}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err), "empty mask")
+	_, err = g.UpdateData(ctx, &pb.UpdateDataRequest{DataId: "a", UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"deleted"}}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = g.UpdateData(ctx, &pb.UpdateDataRequest{DataId: "a", UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"data_id"}}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err), "rename to an empty id")
+
+	resp, err := g.UpdateData(ctx, &pb.UpdateDataRequest{DataId: "a", Revision: 3, Data: &pb.Data{Data: "new", MetaInfo: "ignored"}, UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"data"}}})
+	require.NoError(t, err)
+	assert.Equal(t, uint32(4), resp.Data.Revision)
+	assert.Equal(t, now, resp.Data.ChangedAt.AsTime())
+	assert.Equal(t, "new", db.record.Data)
+	assert.Equal(t, "notes", db.record.Metadata, "fields out of the mask are kept")
+
+	_, err = g.UpdateData(ctx, &pb.UpdateDataRequest{DataId: "a", Revision: 3, Data: &pb.Data{Data: "lost"}, UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"data"}}})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err), "stale revision")
+
+	resp, err = g.UpdateData(ctx, &pb.UpdateDataRequest{DataId: "a", Data: &pb.Data{DataId: "b", Meta: &pb.Meta{Tags: []string{"work"}}}, UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"data_id", "meta"}}})
+	require.NoError(t, err)
+	assert.Equal(t, "b", resp.Data.DataId)
+	assert.Equal(t, []string{storage.MaskDataID, storage.MaskMetaInfo}, db.mask)
+	assert.Equal(t, []string{"work"}, toPB(db.record).Meta.Tags)
+}
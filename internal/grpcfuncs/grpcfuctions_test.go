@@ -6,10 +6,12 @@ import (
 	"net"
 	"testing"
 
-	"github.com/stretchr/testify/assert"
+	"gophkeeper/internal/config"
 	"gophkeeper/internal/grpcfuncs"
 	pb "gophkeeper/proto"
 
+	"github.com/stretchr/testify/assert"
+
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/metadata"
@@ -17,7 +19,7 @@ import (
 
 func TestAuth(t *testing.T) {
 	// Start the gRPC server in a separate goroutine
-	g := grpcfuncs.NewGophKeeperServer()
+	g := grpcfuncs.NewGophKeeperServer(config.Server{DSN: "postgresql://localhost:5432/shvm"})
 	go func() {
 
 		listen, err := net.Listen("tcp", ":3200")
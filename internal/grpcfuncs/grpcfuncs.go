@@ -3,21 +3,25 @@ package grpcfuncs
 
 import (
 	"context"
+	"errors"
 	"log"
+	"log/slog"
 	"time"
 
+	"gophkeeper/internal/apperrors"
+	"gophkeeper/internal/config"
 	"gophkeeper/internal/datamodels"
+	"gophkeeper/internal/gateway"
+	"gophkeeper/internal/lockout"
 	"gophkeeper/internal/sessionstorage"
 	"gophkeeper/internal/storage"
 	"gophkeeper/internal/utils"
 	pb "gophkeeper/proto"
 
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
-	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/protobuf/types/known/emptypb"
-	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // GetUserId - search UserID key in metadata
@@ -35,61 +39,113 @@ func GetUserId(ctx context.Context) string {
 	return ""
 }
 
-// mapErr - maps err from storage to grpc error codes
+// Module errors
+var (
+	ErrTokenEmpty      = apperrors.Unauthenticated("TOKEN_MISSING", "token is empty")
+	ErrUnauthenticated = apperrors.Unauthenticated("SESSION_NOT_FOUND", "user unauthenticated")
+)
+
+// mapErr - maps err from storage to grpc status with error details
+// The cause of internal errors is logged because it is not sent to the client.
 func mapErr(err error) error {
-	if err == storage.ErrDuplicate {
-		return status.Errorf(codes.AlreadyExists, "login already exists")
-	}
-	if err == storage.ErrWrongPassword {
-		return status.Errorf(codes.InvalidArgument, "wrong password")
+	if errors.Is(err, apperrors.ErrInternal) {
+		slog.Error("internal error", slog.Any("error", err))
 	}
-	if err == storage.ErrNotFound {
-		return status.Errorf(codes.NotFound, "not found")
-	}
-	return status.Errorf(codes.Internal, "internal error")
+	return apperrors.ToStatus(err).Err()
 }
 
 // GophKeeperServer is the gRPC server implementation for GophKeeper.
 type GophKeeperServer struct {
 	pb.UnimplementedGophkeeperServer
-	db    storage.Storage
+	db    storage.ServerStorage
 	users sessionstorage.SessionStorage
+	guard *lockout.Guard
+	now   func() time.Time
+	// gatewaySecret - shared with the gateway in this process only
+	gatewaySecret string
 }
 
-// Init initializes the gRPC server.
-func NewGophKeeperServer() GophKeeperServer {
+// NewGophKeeperServer initializes the gRPC server with the settings of cfg.
+func NewGophKeeperServer(cfg config.Server) GophKeeperServer {
 	var err error
 	var g GophKeeperServer
-	g.db, err = storage.NewDBStorage("postgresql://localhost:5432/shvm")
+	g.db, err = storage.NewDBStorage(cfg.DSN)
 	g.users = sessionstorage.NewAuthUsersStorage()
 	if err != nil {
 		log.Fatalf("err pinging db")
 	}
+	policy := cfg.LoginPolicy
+	if policy.MaxFailures == 0 {
+		policy = lockout.NewPolicy()
+	}
+	g.guard = lockout.NewGuard(policy, g.db)
+	g.now = time.Now
+	g.gatewaySecret = utils.GenerateRandomString(32)
 	return g
 }
 
+// session - user ID of the session token of the request
+func (g *GophKeeperServer) session(ctx context.Context) (uint32, error) {
+	token := GetUserId(ctx)
+	if token == "" {
+		return 0, ErrTokenEmpty
+	}
+	id, err := g.users.GetUser(token)
+	if err != nil {
+		return 0, ErrUnauthenticated
+	}
+	return id, nil
+}
+
+// peerIP - IP of the client, requests proxied by the gateway of this server are attributed to the address of the http client
+func (g *GophKeeperServer) peerIP(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ip, ok := gateway.ClientIP(md, g.gatewaySecret); ok {
+			return ip
+		}
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	return lockout.PeerHost(p.Addr.String())
+}
+
+// GatewaySecret returns the secret the REST/JSON gateway of this server passes the address of its clients with.
+func (g *GophKeeperServer) GatewaySecret() string {
+	return g.gatewaySecret
+}
+
+// isLoginFailure - reports whether err is a wrong login or password
+func isLoginFailure(err error) bool {
+	return errors.Is(err, apperrors.ErrUnauthenticated) || errors.Is(err, apperrors.ErrNotFound)
+}
+
 // Auth handles the authentication request.
 func (g *GophKeeperServer) Auth(ctx context.Context, in *pb.AuthLoginRequest) (*pb.AuthLoginResponse, error) {
 	var resp pb.AuthLoginResponse
+	if err := g.guard.Check(ctx, "", g.peerIP(ctx)); err != nil {
+		return nil, mapErr(err)
+	}
 	passHash := utils.GetMD5Hash(in.Password)
-	err := g.db.Auth(in.Login, passHash)
+	err := g.db.Auth(ctx, in.Login, passHash)
 	if err != nil {
 		return nil, mapErr(err)
 	}
-	id, err := g.db.Login(in.Login, passHash)
+	id, err := g.db.Login(ctx, in.Login, passHash)
 	if err != nil {
 		return nil, mapErr(err)
 	}
 	token := utils.GenerateRandomString(5)
 	if err = g.users.AddUser(token, id); err != nil {
-		return nil, err
+		return nil, mapErr(err)
 	}
 	resp.Id = id
 	md2 := metadata.New(map[string]string{"userid": token})
 	outgoingCtx := metadata.NewOutgoingContext(ctx, md2)
 	err = grpc.SetHeader(outgoingCtx, md2)
 	if err != nil {
-		return nil, status.Error(codes.Internal, "SetHeader err")
+		return nil, mapErr(apperrors.Internal(err))
 	}
 	return &resp, nil
 }
@@ -97,15 +153,36 @@ func (g *GophKeeperServer) Auth(ctx context.Context, in *pb.AuthLoginRequest) (*
 // Login handles the login request.
 func (g *GophKeeperServer) Login(ctx context.Context, in *pb.AuthLoginRequest) (*pb.AuthLoginResponse, error) {
 	var resp pb.AuthLoginResponse
+	ip := g.peerIP(ctx)
+	if err := g.guard.Check(ctx, in.Login, ip); err != nil {
+		return nil, mapErr(err)
+	}
 	passHash := utils.GetMD5Hash(in.Password)
-	id, err := g.db.Login(in.Login, passHash)
+	id, err := g.db.Login(ctx, in.Login, passHash)
+	if isLoginFailure(err) {
+		if gErr := g.guard.Failure(ctx, in.Login, ip); gErr != nil {
+			return nil, mapErr(gErr)
+		}
+	}
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	err = g.secondFactor(ctx, id, in.OtpCode)
+	if errors.Is(err, ErrOTPInvalid) {
+		if gErr := g.guard.Failure(ctx, in.Login, ip); gErr != nil {
+			return nil, mapErr(gErr)
+		}
+	}
 	if err != nil {
 		return nil, mapErr(err)
 	}
+	if err = g.guard.Success(ctx, in.Login); err != nil {
+		return nil, mapErr(err)
+	}
 	token := utils.GenerateRandomString(5)
 	err = g.users.AddUser(token, id)
 	if err != nil {
-		return nil, err
+		return nil, mapErr(err)
 	}
 	resp.Id = id
 	header := metadata.Pairs("userid", token)
@@ -119,13 +196,13 @@ func (g *GophKeeperServer) AddData(ctx context.Context, in *pb.AddDataRequest) (
 	//TODO хранить зашифровано
 	token := GetUserId(ctx)
 	if token == "" {
-		return nil, status.Error(codes.Unauthenticated, "token is empty")
+		return nil, mapErr(ErrTokenEmpty)
 	}
 	id, err := g.users.GetUser(token)
 	if err != nil {
-		return nil, status.Error(codes.Unauthenticated, "user unauthenticated")
+		return nil, mapErr(ErrUnauthenticated)
 	}
-	err = g.db.AddData(datamodels.Data{UserID: id, DataID: in.Data.DataId, Data: in.Data.Data, Metadata: in.Data.MetaInfo, ChangedAt: time.Now()})
+	err = g.db.AddData(ctx, datamodels.Data{UserID: id, DataID: in.Data.DataId, Data: in.Data.Data, Metadata: metaInfo(in.Data), ChangedAt: time.Now()})
 	if err != nil {
 		return nil, mapErr(err)
 	}
@@ -137,17 +214,18 @@ func (g *GophKeeperServer) GetData(ctx context.Context, in *pb.GetDataRequest) (
 	var resp pb.GetDataResponse
 	token := GetUserId(ctx)
 	if token == "" {
-		return nil, status.Error(codes.Unauthenticated, "token is empty")
+		return nil, mapErr(ErrTokenEmpty)
 	}
 	id, err := g.users.GetUser(token)
 	if err != nil {
-		return nil, status.Error(codes.Unauthenticated, "user unauthenticated")
+		return nil, mapErr(ErrUnauthenticated)
 	}
-	data, err := g.db.GetData(in.DataId, id)
+	data, err := g.db.GetData(ctx, in.DataId, id)
 	if err != nil {
 		return nil, mapErr(err)
 	}
-	resp.Data = &pb.Data{DataId: in.DataId, Data: data.Data, MetaInfo: data.Metadata, ChangedAt: timestamppb.New(data.ChangedAt), Deleted: false}
+	data.DataID, data.Deleted = in.DataId, false
+	resp.Data = toPB(data)
 	return &resp, nil
 }
 
@@ -155,13 +233,13 @@ func (g *GophKeeperServer) GetData(ctx context.Context, in *pb.GetDataRequest) (
 func (g *GophKeeperServer) DelData(ctx context.Context, in *pb.GetDataRequest) (*emptypb.Empty, error) {
 	token := GetUserId(ctx)
 	if token == "" {
-		return nil, status.Error(codes.Unauthenticated, "token is empty")
+		return nil, mapErr(ErrTokenEmpty)
 	}
 	id, err := g.users.GetUser(token)
 	if err != nil {
-		return nil, status.Error(codes.Unauthenticated, "user unauthenticated")
+		return nil, mapErr(ErrUnauthenticated)
 	}
-	err = g.db.DelData(in.DataId, id)
+	err = g.db.DelData(ctx, in.DataId, id)
 	if err != nil {
 		return nil, mapErr(err)
 	}
@@ -173,19 +251,25 @@ func (g *GophKeeperServer) Sync(ctx context.Context, in *emptypb.Empty) (*pb.Syn
 	var resp pb.SynchronizationResponse
 	token := GetUserId(ctx)
 	if token == "" {
-		return nil, status.Error(codes.Unauthenticated, "token is empty")
+		return nil, mapErr(ErrTokenEmpty)
 	}
 	id, err := g.users.GetUser(token)
 	if err != nil {
-		return nil, status.Error(codes.Unauthenticated, "user unauthenticated")
+		return nil, mapErr(ErrUnauthenticated)
 	}
-	data, err := g.db.Sync(id)
+	// taken before the read, so the device acknowledges only deletions it receives
+	at, err := g.db.Now(ctx)
 	if err != nil {
 		return nil, mapErr(err)
 	}
+	data, err := g.db.Sync(ctx, id)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	g.ackDevice(ctx, id, at)
 	if data != nil {
 		for _, v := range data {
-			resp.Data = append(resp.Data, &pb.Data{DataId: v.DataID, Data: v.Data, MetaInfo: v.Metadata, Deleted: v.Deleted, ChangedAt: timestamppb.New(v.ChangedAt)})
+			resp.Data = append(resp.Data, toPB(v))
 		}
 	}
 	return &resp, nil
@@ -196,13 +280,16 @@ func (g *GophKeeperServer) Sync(ctx context.Context, in *emptypb.Empty) (*pb.Syn
 func (g *GophKeeperServer) ClientSync(ctx context.Context, in *pb.ClientSyncRequest) (*emptypb.Empty, error) {
 	token := GetUserId(ctx)
 	if token == "" {
-		return nil, status.Error(codes.Unauthenticated, "token is empty")
+		return nil, mapErr(ErrTokenEmpty)
 	}
 	id, err := g.users.GetUser(token)
 	if err != nil {
-		return nil, status.Error(codes.Unauthenticated, "user unauthenticated")
+		return nil, mapErr(ErrUnauthenticated)
+	}
+	for _, v := range in.Data {
+		v.MetaInfo = metaInfo(v)
 	}
-	err = g.db.ClientSync(id, in.Data)
+	err = g.db.ClientSync(ctx, id, in.Data)
 	if err != nil {
 		return nil, mapErr(err)
 	}
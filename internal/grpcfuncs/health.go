@@ -0,0 +1,59 @@
+package grpcfuncs
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	pb "gophkeeper/proto"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// ServiceName - name of the Gophkeeper service in health checks
+var ServiceName = pb.Gophkeeper_ServiceDesc.ServiceName
+
+// WatchHealth - reports the database reachability to hs every interval until ctx is done
+func (g *GophKeeperServer) WatchHealth(ctx context.Context, hs *health.Server, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		g.checkHealth(ctx, hs, interval)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkHealth - pings the database and sets the serving status of the server and the service
+func (g *GophKeeperServer) checkHealth(ctx context.Context, hs *health.Server, timeout time.Duration) {
+	pingCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	st := healthpb.HealthCheckResponse_SERVING
+	if err := g.db.Ping(pingCtx); err != nil {
+		st = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	hs.SetServingStatus("", st)
+	hs.SetServingStatus(ServiceName, st)
+}
+
+// Close releases the database of the server.
+func (g *GophKeeperServer) Close() error {
+	return g.db.Close()
+}
+
+// ActiveSessions returns the number of authenticated sessions.
+func (g *GophKeeperServer) ActiveSessions() int {
+	return g.users.Len()
+}
+
+// DBStats returns the connection pool statistics of the database.
+func (g *GophKeeperServer) DBStats() sql.DBStats {
+	return g.db.Stats()
+}
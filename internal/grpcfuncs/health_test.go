@@ -0,0 +1,40 @@
+package grpcfuncs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gophkeeper/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+type pingStorage struct {
+	storage.ServerStorage
+	err error
+}
+
+func (p *pingStorage) Ping(ctx context.Context) error {
+	return p.err
+}
+
+func TestCheckHealth(t *testing.T) {
+	db := &pingStorage{}
+	g := GophKeeperServer{db: db}
+	hs := health.NewServer()
+
+	g.checkHealth(context.Background(), hs, time.Second)
+	resp, err := hs.Check(context.Background(), &healthpb.HealthCheckRequest{Service: ServiceName})
+	assert.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+
+	db.err = errors.New("connection refused")
+	g.checkHealth(context.Background(), hs, time.Second)
+	resp, err = hs.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, resp.Status)
+}
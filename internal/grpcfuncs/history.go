@@ -0,0 +1,53 @@
+package grpcfuncs
+
+import (
+	"context"
+
+	pb "gophkeeper/proto"
+
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ListVersions handles the request to list the revisions of a record.
+func (g *GophKeeperServer) ListVersions(ctx context.Context, in *pb.GetDataRequest) (*pb.ListVersionsResponse, error) {
+	id, err := g.session(ctx)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	versions, err := g.db.ListVersions(ctx, id, in.DataId)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	var resp pb.ListVersionsResponse
+	for _, v := range versions {
+		resp.Versions = append(resp.Versions, &pb.DataVersion{Revision: v.Revision, ChangedAt: timestamppb.New(v.ChangedAt), Current: v.Current})
+	}
+	return &resp, nil
+}
+
+// GetVersion handles the request to get a revision of a record.
+func (g *GophKeeperServer) GetVersion(ctx context.Context, in *pb.GetVersionRequest) (*pb.GetDataResponse, error) {
+	id, err := g.session(ctx)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	data, err := g.db.GetVersion(ctx, id, in.DataId, in.Revision)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	data.DataID, data.Deleted = in.DataId, false
+	return &pb.GetDataResponse{Data: toPB(data)}, nil
+}
+
+// SetHistoryRetention handles the request to change how many older revisions of records are kept.
+func (g *GophKeeperServer) SetHistoryRetention(ctx context.Context, in *pb.HistoryRetentionRequest) (*emptypb.Empty, error) {
+	id, err := g.session(ctx)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	if err = g.db.SetHistoryRetention(ctx, id, int(in.Versions)); err != nil {
+		return nil, mapErr(err)
+	}
+	return new(emptypb.Empty), nil
+}
@@ -0,0 +1,84 @@
+package grpcfuncs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gophkeeper/internal/datamodels"
+	"gophkeeper/internal/sessionstorage"
+	"gophkeeper/internal/storage"
+	pb "gophkeeper/proto"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// historyStorage - storage with revisions of a single record
+type historyStorage struct {
+	storage.ServerStorage
+	versions  map[uint32]datamodels.Data
+	current   uint32
+	retention int
+}
+
+func (s *historyStorage) ListVersions(ctx context.Context, userID uint32, dataID string) ([]datamodels.Version, error) {
+	var resp []datamodels.Version
+	for rev := s.current; rev > 0; rev-- {
+		if v, ok := s.versions[rev]; ok {
+			resp = append(resp, datamodels.Version{Revision: rev, ChangedAt: v.ChangedAt, Current: rev == s.current})
+		}
+	}
+	return resp, nil
+}
+
+func (s *historyStorage) GetVersion(ctx context.Context, userID uint32, dataID string, revision uint32) (datamodels.Data, error) {
+	v, ok := s.versions[revision]
+	if !ok {
+		return datamodels.Data{}, storage.ErrVersionNotFound
+	}
+	return v, nil
+}
+
+func (s *historyStorage) SetHistoryRetention(ctx context.Context, userID uint32, versions int) error {
+	s.retention = versions
+	return nil
+}
+
+func TestHistory(t *testing.T) {
+	changed := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	db := &historyStorage{
+		versions: map[uint32]datamodels.Data{
+			2: {Data: "old", ChangedAt: changed},
+			3: {Data: "new", ChangedAt: changed.Add(time.Hour)},
+		},
+		current: 3,
+	}
+	g := GophKeeperServer{db: db, users: sessionstorage.NewAuthUsersStorage()}
+	require.NoError(t, g.users.AddUser("token", 1))
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("userid", "token"))
+
+	_, err := g.ListVersions(context.Background(), &pb.GetDataRequest{DataId: "a"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	list, err := g.ListVersions(ctx, &pb.GetDataRequest{DataId: "a"})
+	require.NoError(t, err)
+	require.Len(t, list.Versions, 2)
+	assert.Equal(t, uint32(3), list.Versions[0].Revision)
+	assert.True(t, list.Versions[0].Current)
+	assert.False(t, list.Versions[1].Current)
+
+	v, err := g.GetVersion(ctx, &pb.GetVersionRequest{DataId: "a", Revision: 2})
+	require.NoError(t, err)
+	assert.Equal(t, "old", v.Data.Data)
+	assert.Equal(t, "a", v.Data.DataId)
+	_, err = g.GetVersion(ctx, &pb.GetVersionRequest{DataId: "a", Revision: 1})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = g.SetHistoryRetention(ctx, &pb.HistoryRetentionRequest{Versions: 5})
+	require.NoError(t, err)
+	assert.Equal(t, 5, db.retention)
+}
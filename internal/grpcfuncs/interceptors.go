@@ -0,0 +1,101 @@
+package grpcfuncs
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// TimeoutInterceptor - limits the duration of every unary request
+func TimeoutInterceptor(maxDuration time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if maxDuration > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, maxDuration)
+			defer cancel()
+		}
+		return handler(ctx, req)
+	}
+}
+
+// timeoutStream - grpc.ServerStream with the context limited by TimeoutStreamInterceptor
+type timeoutStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the limited context of the stream.
+func (s *timeoutStream) Context() context.Context {
+	return s.ctx
+}
+
+// TimeoutStreamInterceptor - limits the duration of every stream like TimeoutInterceptor does for unary requests
+func TimeoutStreamInterceptor(maxDuration time.Duration) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if maxDuration <= 0 {
+			return handler(srv, ss)
+		}
+		ctx, cancel := context.WithTimeout(ss.Context(), maxDuration)
+		defer cancel()
+		return handler(srv, &timeoutStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// RateLimitKey - caller of a request for rate limiting: the user of the session or the peer IP of unauthenticated calls
+func (g *GophKeeperServer) RateLimitKey(ctx context.Context) string {
+	if id, err := g.users.GetUser(GetUserId(ctx)); err == nil {
+		return "user:" + strconv.FormatUint(uint64(id), 10)
+	}
+	return "peer:" + g.peerIP(ctx)
+}
+
+// AccessLogUnaryInterceptor - writes an access log record for every unary request
+// Requests and responses are never logged, they carry user data.
+func (g *GophKeeperServer) AccessLogUnaryInterceptor(log *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		g.accessLog(ctx, log, info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// AccessLogStreamInterceptor - writes an access log record for every stream
+func (g *GophKeeperServer) AccessLogStreamInterceptor(log *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		g.accessLog(ss.Context(), log, info.FullMethod, start, err)
+		return err
+	}
+}
+
+// accessLog - writes method, user ID, duration, status code and peer of a finished request
+func (g *GophKeeperServer) accessLog(ctx context.Context, log *slog.Logger, method string, start time.Time, err error) {
+	code := status.Code(err)
+	attrs := []slog.Attr{
+		slog.String("method", method),
+		slog.Duration("duration", time.Since(start)),
+		slog.String("code", code.String()),
+	}
+	if id, uErr := g.users.GetUser(GetUserId(ctx)); uErr == nil {
+		attrs = append(attrs, slog.Any("user_id", id))
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		attrs = append(attrs, slog.String("peer", p.Addr.String()))
+	}
+	level := slog.LevelInfo
+	switch code {
+	case codes.Internal, codes.Unknown, codes.DataLoss:
+		level = slog.LevelError
+	case codes.DeadlineExceeded, codes.Unavailable, codes.ResourceExhausted:
+		level = slog.LevelWarn
+	}
+	log.LogAttrs(ctx, level, "request", attrs...)
+}
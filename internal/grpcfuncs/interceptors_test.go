@@ -0,0 +1,42 @@
+package grpcfuncs
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"gophkeeper/internal/sessionstorage"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func TestRateLimitKey(t *testing.T) {
+	g := GophKeeperServer{users: sessionstorage.NewAuthUsersStorage(), gatewaySecret: "secret"}
+	local := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 5000}})
+
+	spoofed := metadata.NewIncomingContext(local, metadata.Pairs("x-forwarded-for", "10.0.0.1", "gophkeeper-gateway-peer", "10.0.0.2"))
+	assert.Equal(t, "peer:127.0.0.1", g.RateLimitKey(spoofed), "forwarded addresses of other callers are ignored")
+
+	proxied := metadata.NewIncomingContext(local, metadata.Pairs("gophkeeper-gateway-peer", "192.0.2.1", "gophkeeper-gateway-secret", "secret"))
+	assert.Equal(t, "peer:192.0.2.1", g.RateLimitKey(proxied))
+}
+
+func TestTimeoutStreamInterceptor(t *testing.T) {
+	var deadline time.Time
+	var ok bool
+	handler := func(srv interface{}, ss grpc.ServerStream) error {
+		deadline, ok = ss.Context().Deadline()
+		return nil
+	}
+	stream := &exportStream{ctx: context.Background()}
+	assert.NoError(t, TimeoutStreamInterceptor(time.Minute)(nil, stream, &grpc.StreamServerInfo{}, handler))
+	assert.True(t, ok, "streams get the maximum request duration")
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+
+	assert.NoError(t, TimeoutStreamInterceptor(0)(nil, stream, &grpc.StreamServerInfo{}, handler))
+	assert.False(t, ok, "zero keeps streams unlimited")
+}
@@ -0,0 +1,48 @@
+package grpcfuncs
+
+import (
+	"context"
+	"sort"
+
+	"gophkeeper/internal/passgen"
+	"gophkeeper/internal/records"
+	pb "gophkeeper/proto"
+
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// ListPasswordPolicies handles the request to list the password policies of the vault ordered by folder.
+func (g *GophKeeperServer) ListPasswordPolicies(ctx context.Context, in *emptypb.Empty) (*pb.PasswordPoliciesResponse, error) {
+	id, err := g.session(ctx)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	policies, err := g.db.PasswordPolicies(ctx, id)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	var resp pb.PasswordPoliciesResponse
+	for folder, p := range policies {
+		resp.Policies = append(resp.Policies, &pb.FolderPasswordPolicy{Folder: folder, Policy: passgen.ToPB(p)})
+	}
+	sort.Slice(resp.Policies, func(i, j int) bool { return resp.Policies[i].Folder < resp.Policies[j].Folder })
+	return &resp, nil
+}
+
+// SetPasswordPolicy handles the request to set or, without a policy, to remove the password policy of a folder.
+func (g *GophKeeperServer) SetPasswordPolicy(ctx context.Context, in *pb.FolderPasswordPolicy) (*emptypb.Empty, error) {
+	id, err := g.session(ctx)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	folder := records.CleanFolder(in.Folder)
+	var policy *passgen.Policy
+	if in.Policy != nil {
+		p := passgen.FromPB(in.Policy)
+		policy = &p
+	}
+	if err = g.db.SetPasswordPolicy(ctx, id, folder, policy); err != nil {
+		return nil, mapErr(err)
+	}
+	return new(emptypb.Empty), nil
+}
@@ -0,0 +1,70 @@
+package grpcfuncs
+
+import (
+	"context"
+	"testing"
+
+	"gophkeeper/internal/apperrors"
+	"gophkeeper/internal/passgen"
+	"gophkeeper/internal/sessionstorage"
+	"gophkeeper/internal/storage"
+	pb "gophkeeper/proto"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// policyStorage - storage with the password policies kept in memory
+type policyStorage struct {
+	storage.ServerStorage
+	policies map[string]passgen.Policy
+}
+
+func (s *policyStorage) PasswordPolicies(ctx context.Context, userID uint32) (map[string]passgen.Policy, error) {
+	return s.policies, nil
+}
+
+func (s *policyStorage) SetPasswordPolicy(ctx context.Context, userID uint32, folder string, policy *passgen.Policy) error {
+	if policy == nil {
+		delete(s.policies, folder)
+		return nil
+	}
+	if err := policy.Validate(); err != nil {
+		return apperrors.Validation("invalid password policy", apperrors.FieldViolation{Field: "policy", Description: err.Error()})
+	}
+	s.policies[folder] = *policy
+	return nil
+}
+
+func TestPasswordPolicies(t *testing.T) {
+	db := &policyStorage{policies: map[string]passgen.Policy{}}
+	g := GophKeeperServer{db: db, users: sessionstorage.NewAuthUsersStorage()}
+	require.NoError(t, g.users.AddUser("token", 1))
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("userid", "token"))
+
+	_, err := g.SetPasswordPolicy(context.Background(), &pb.FolderPasswordPolicy{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	words := passgen.Policy{Words: 6, Separator: " "}
+	_, err = g.SetPasswordPolicy(ctx, &pb.FolderPasswordPolicy{Folder: "/work/servers/", Policy: passgen.ToPB(words)})
+	require.NoError(t, err)
+	_, err = g.SetPasswordPolicy(ctx, &pb.FolderPasswordPolicy{Policy: passgen.ToPB(passgen.DefaultPolicy())})
+	require.NoError(t, err)
+	_, err = g.SetPasswordPolicy(ctx, &pb.FolderPasswordPolicy{Folder: "home", Policy: &pb.PasswordPolicy{Length: 10}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	resp, err := g.ListPasswordPolicies(ctx, new(emptypb.Empty))
+	require.NoError(t, err)
+	require.Len(t, resp.Policies, 2)
+	assert.Equal(t, "", resp.Policies[0].Folder)
+	assert.Equal(t, "work/servers", resp.Policies[1].Folder)
+	assert.Equal(t, words, passgen.FromPB(resp.Policies[1].Policy))
+
+	_, err = g.SetPasswordPolicy(ctx, &pb.FolderPasswordPolicy{Folder: "work/servers"})
+	require.NoError(t, err)
+	assert.NotContains(t, db.policies, "work/servers")
+}
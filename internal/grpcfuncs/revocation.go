@@ -0,0 +1,48 @@
+package grpcfuncs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// WatchRevocations - ends the sessions of disabled users and users logged out by an operator every interval until ctx is done
+// Sessions live in the memory of the server, the admin commands only mark the users in the database.
+func (g *GophKeeperServer) WatchRevocations(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	// revocations are stamped by the database clock, the clock of this server may differ from it
+	since, err := g.db.Now(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "session revocation check failed", slog.Any("error", err))
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if since.IsZero() {
+			if since, err = g.db.Now(ctx); err != nil {
+				slog.WarnContext(ctx, "session revocation check failed", slog.Any("error", err))
+				continue
+			}
+		}
+		since = g.revokeSessions(ctx, since)
+	}
+}
+
+// revokeSessions - ends the sessions of users revoked after since, returns the since of the next check
+func (g *GophKeeperServer) revokeSessions(ctx context.Context, since time.Time) time.Time {
+	ids, latest, err := g.db.RevokedUsers(ctx, since)
+	if err != nil {
+		slog.WarnContext(ctx, "session revocation check failed", slog.Any("error", err))
+		return since
+	}
+	for _, id := range ids {
+		if n := g.users.RevokeUser(id, ""); n > 0 {
+			slog.InfoContext(ctx, "sessions revoked", slog.Any("user_id", id), slog.Int("sessions", n))
+		}
+	}
+	return latest
+}
@@ -0,0 +1,74 @@
+package grpcfuncs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"gophkeeper/internal/sessionstorage"
+	"gophkeeper/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// revokedStorage - storage that reports fixed revoked users
+type revokedStorage struct {
+	storage.ServerStorage
+	mu     sync.Mutex
+	ids    []uint32
+	latest time.Time
+	now    time.Time
+	err    error
+	since  time.Time
+}
+
+func (s *revokedStorage) Now(ctx context.Context) (time.Time, error) {
+	return s.now, nil
+}
+
+func (s *revokedStorage) RevokedUsers(ctx context.Context, since time.Time) ([]uint32, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.since = since
+	return s.ids, s.latest, s.err
+}
+
+func (s *revokedStorage) checkedSince() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.since
+}
+
+func TestRevokeSessions(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	db := &revokedStorage{ids: []uint32{1}, latest: start.Add(time.Minute)}
+	g := GophKeeperServer{db: db, users: sessionstorage.NewAuthUsersStorage()}
+	require.NoError(t, g.users.AddUser("a", 1))
+	require.NoError(t, g.users.AddUser("b", 1))
+	require.NoError(t, g.users.AddUser("c", 2))
+
+	next := g.revokeSessions(context.Background(), start)
+	assert.Equal(t, start, db.since)
+	assert.Equal(t, start.Add(time.Minute), next)
+	assert.Equal(t, 1, g.users.Len())
+	_, err := g.users.GetUser("c")
+	assert.NoError(t, err)
+
+	db.err = errors.New("connection refused")
+	assert.Equal(t, next, g.revokeSessions(context.Background(), next), "failed checks are repeated")
+}
+
+func TestWatchRevocations(t *testing.T) {
+	dbNow := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	db := &revokedStorage{now: dbNow}
+	g := GophKeeperServer{db: db, users: sessionstorage.NewAuthUsersStorage(), now: func() time.Time { return dbNow.Add(time.Hour) }}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go g.WatchRevocations(ctx, time.Millisecond)
+	require.Eventually(t, func() bool { return !db.checkedSince().IsZero() }, time.Second, time.Millisecond)
+	assert.Equal(t, dbNow, db.checkedSince(), "checks start at the database time")
+}
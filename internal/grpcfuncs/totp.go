@@ -0,0 +1,195 @@
+package grpcfuncs
+
+import (
+	"context"
+	"errors"
+
+	"gophkeeper/internal/apperrors"
+	"gophkeeper/internal/totp"
+	pb "gophkeeper/proto"
+
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// recoveryCodes - number of recovery codes issued to a user
+const recoveryCodes = 10
+
+// Two-factor authentication errors
+var (
+	ErrOTPRequired     = apperrors.Unauthenticated("OTP_REQUIRED", "two-factor code required")
+	ErrOTPInvalid      = apperrors.Unauthenticated("OTP_INVALID", "invalid two-factor code")
+	ErrTOTPEnabled     = apperrors.Conflict("TOTP_ENABLED", "two-factor authentication is already enabled")
+	ErrTOTPNotEnrolled = apperrors.Conflict("TOTP_NOT_ENROLLED", "two-factor authentication is not enrolled")
+)
+
+// secondFactor - checks the one-time code of users with two-factor authentication
+func (g *GophKeeperServer) secondFactor(ctx context.Context, id uint32, code string) error {
+	secret, enabled, err := g.db.TOTPSecret(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return nil
+	}
+	if code == "" {
+		return ErrOTPRequired
+	}
+	return g.verifyCode(ctx, id, secret, code)
+}
+
+// verifyCode - checks a TOTP code or uses up a recovery code
+func (g *GophKeeperServer) verifyCode(ctx context.Context, id uint32, secret string, code string) error {
+	if totp.IsRecoveryCode(code) {
+		err := g.db.UseRecoveryCode(ctx, id, totp.HashRecoveryCode(code))
+		if errors.Is(err, apperrors.ErrNotFound) {
+			return ErrOTPInvalid
+		}
+		return err
+	}
+	key, err := totpKey(secret)
+	if err != nil {
+		return err
+	}
+	return g.acceptCode(ctx, id, key, code)
+}
+
+// acceptCode - checks a TOTP code and records its time step, so the code can not be used again
+func (g *GophKeeperServer) acceptCode(ctx context.Context, id uint32, key totp.Key, code string) error {
+	last, err := g.db.TOTPCounter(ctx, id)
+	if err != nil {
+		return err
+	}
+	step, ok := key.Verify(code, g.now(), last)
+	if !ok {
+		return ErrOTPInvalid
+	}
+	err = g.db.UseTOTPCounter(ctx, id, step)
+	if errors.Is(err, apperrors.ErrNotFound) {
+		return ErrOTPInvalid
+	}
+	return err
+}
+
+// totpKey - key of a stored secret
+func totpKey(secret string) (totp.Key, error) {
+	raw, err := totp.DecodeSecret(secret)
+	if err != nil {
+		return totp.Key{}, apperrors.Internal(err)
+	}
+	return totp.NewKey(raw), nil
+}
+
+// issueRecoveryCodes - enables two-factor authentication with new recovery codes
+func (g *GophKeeperServer) issueRecoveryCodes(ctx context.Context, id uint32) (*pb.RecoveryCodesResponse, error) {
+	codes, err := totp.GenerateRecoveryCodes(recoveryCodes)
+	if err != nil {
+		return nil, apperrors.Internal(err)
+	}
+	hashes := make([]string, len(codes))
+	for i, c := range codes {
+		hashes[i] = totp.HashRecoveryCode(c)
+	}
+	if err = g.db.EnableTOTP(ctx, id, hashes); err != nil {
+		return nil, err
+	}
+	return &pb.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// EnrollTOTP generates a TOTP secret, it is used only after ConfirmTOTP.
+func (g *GophKeeperServer) EnrollTOTP(ctx context.Context, in *emptypb.Empty) (*pb.EnrollTOTPResponse, error) {
+	id, err := g.session(ctx)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	_, enabled, err := g.db.TOTPSecret(ctx, id)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	if enabled {
+		return nil, mapErr(ErrTOTPEnabled)
+	}
+	key, err := totp.GenerateKey()
+	if err != nil {
+		return nil, mapErr(apperrors.Internal(err))
+	}
+	secret := totp.EncodeSecret(key.Secret)
+	if err = g.db.SetTOTPSecret(ctx, id, secret); err != nil {
+		return nil, mapErr(err)
+	}
+	return &pb.EnrollTOTPResponse{Secret: secret}, nil
+}
+
+// ConfirmTOTP enables two-factor authentication once the code of the enrolled secret is valid.
+func (g *GophKeeperServer) ConfirmTOTP(ctx context.Context, in *pb.TOTPCodeRequest) (*pb.RecoveryCodesResponse, error) {
+	id, err := g.session(ctx)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	secret, enabled, err := g.db.TOTPSecret(ctx, id)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	if enabled {
+		return nil, mapErr(ErrTOTPEnabled)
+	}
+	if secret == "" {
+		return nil, mapErr(ErrTOTPNotEnrolled)
+	}
+	key, err := totpKey(secret)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	if err = g.acceptCode(ctx, id, key, in.Code); err != nil {
+		return nil, mapErr(err)
+	}
+	resp, err := g.issueRecoveryCodes(ctx, id)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return resp, nil
+}
+
+// DisableTOTP turns two-factor authentication off, it needs a valid code or recovery code.
+func (g *GophKeeperServer) DisableTOTP(ctx context.Context, in *pb.TOTPCodeRequest) (*emptypb.Empty, error) {
+	id, err := g.session(ctx)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	secret, enabled, err := g.db.TOTPSecret(ctx, id)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	if !enabled {
+		return nil, mapErr(ErrTOTPNotEnrolled)
+	}
+	if err = g.verifyCode(ctx, id, secret, in.Code); err != nil {
+		return nil, mapErr(err)
+	}
+	if err = g.db.DisableTOTP(ctx, id); err != nil {
+		return nil, mapErr(err)
+	}
+	return new(emptypb.Empty), nil
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes, it needs a valid code or recovery code.
+func (g *GophKeeperServer) RegenerateRecoveryCodes(ctx context.Context, in *pb.TOTPCodeRequest) (*pb.RecoveryCodesResponse, error) {
+	id, err := g.session(ctx)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	secret, enabled, err := g.db.TOTPSecret(ctx, id)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	if !enabled {
+		return nil, mapErr(ErrTOTPNotEnrolled)
+	}
+	if err = g.verifyCode(ctx, id, secret, in.Code); err != nil {
+		return nil, mapErr(err)
+	}
+	resp, err := g.issueRecoveryCodes(ctx, id)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return resp, nil
+}
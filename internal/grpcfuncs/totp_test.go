@@ -0,0 +1,159 @@
+package grpcfuncs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gophkeeper/internal/apperrors"
+	"gophkeeper/internal/lockout"
+	"gophkeeper/internal/sessionstorage"
+	"gophkeeper/internal/storage"
+	"gophkeeper/internal/totp"
+	"gophkeeper/internal/utils"
+	pb "gophkeeper/proto"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// totpStorage - storage of a single user with two-factor authentication
+type totpStorage struct {
+	storage.ServerStorage
+	password string
+	secret   string
+	enabled  bool
+	recovery map[string]bool
+	counter  uint64
+}
+
+func (s *totpStorage) Login(ctx context.Context, login string, password string) (uint32, error) {
+	if password != s.password {
+		return 0, storage.ErrWrongPassword
+	}
+	return 1, nil
+}
+
+func (s *totpStorage) LoginFailures(ctx context.Context, login string) (int, time.Time, error) {
+	return 0, time.Time{}, nil
+}
+
+func (s *totpStorage) RecordLoginFailure(ctx context.Context, login string) (int, error) {
+	return 1, nil
+}
+
+func (s *totpStorage) BlockLogin(ctx context.Context, login string, until time.Time) error {
+	return nil
+}
+
+func (s *totpStorage) ResetLoginFailures(ctx context.Context, login string) error {
+	return nil
+}
+
+func (s *totpStorage) SetTOTPSecret(ctx context.Context, userID uint32, secret string) error {
+	s.secret, s.enabled, s.counter = secret, false, 0
+	return nil
+}
+
+func (s *totpStorage) TOTPSecret(ctx context.Context, userID uint32) (string, bool, error) {
+	return s.secret, s.enabled, nil
+}
+
+func (s *totpStorage) EnableTOTP(ctx context.Context, userID uint32, recoveryHashes []string) error {
+	s.enabled = true
+	s.recovery = make(map[string]bool)
+	for _, h := range recoveryHashes {
+		s.recovery[h] = true
+	}
+	return nil
+}
+
+func (s *totpStorage) DisableTOTP(ctx context.Context, userID uint32) error {
+	s.secret, s.enabled, s.recovery, s.counter = "", false, nil, 0
+	return nil
+}
+
+func (s *totpStorage) UseRecoveryCode(ctx context.Context, userID uint32, recoveryHash string) error {
+	if !s.recovery[recoveryHash] {
+		return storage.ErrNotFound
+	}
+	delete(s.recovery, recoveryHash)
+	return nil
+}
+
+func (s *totpStorage) TOTPCounter(ctx context.Context, userID uint32) (uint64, error) {
+	return s.counter, nil
+}
+
+func (s *totpStorage) UseTOTPCounter(ctx context.Context, userID uint32, counter uint64) error {
+	if counter <= s.counter {
+		return storage.ErrNotFound
+	}
+	s.counter = counter
+	return nil
+}
+
+func TestTwoFactor(t *testing.T) {
+	db := &totpStorage{password: utils.GetMD5Hash("password")}
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	g := GophKeeperServer{db: db, users: sessionstorage.NewAuthUsersStorage(), now: func() time.Time { return now }}
+	g.guard = lockout.NewGuard(lockout.Policy{MaxFailures: 100, Lockout: time.Minute}, db)
+	require.NoError(t, g.users.AddUser("token", 1))
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("userid", "token"))
+
+	_, err := g.ConfirmTOTP(ctx, &pb.TOTPCodeRequest{Code: "123456"})
+	assert.Equal(t, "TOTP_NOT_ENROLLED", apperrors.Reason(apperrors.FromStatus(err)))
+
+	enrolled, err := g.EnrollTOTP(ctx, &emptypb.Empty{})
+	require.NoError(t, err)
+	secret, err := totp.DecodeSecret(enrolled.Secret)
+	require.NoError(t, err)
+	key := totp.NewKey(secret)
+
+	_, err = g.Login(context.Background(), &pb.AuthLoginRequest{Login: "user", Password: "password"})
+	require.NoError(t, err, "not confirmed secret is not required")
+
+	_, err = g.ConfirmTOTP(ctx, &pb.TOTPCodeRequest{Code: key.Code(now.Add(time.Hour))})
+	assert.Equal(t, "OTP_INVALID", apperrors.Reason(apperrors.FromStatus(err)))
+	issued, err := g.ConfirmTOTP(ctx, &pb.TOTPCodeRequest{Code: key.Code(now)})
+	require.NoError(t, err)
+	assert.Len(t, issued.RecoveryCodes, recoveryCodes)
+
+	_, err = g.Login(context.Background(), &pb.AuthLoginRequest{Login: "user", Password: "password"})
+	assert.Equal(t, "OTP_REQUIRED", apperrors.Reason(apperrors.FromStatus(err)))
+	_, err = g.Login(context.Background(), &pb.AuthLoginRequest{Login: "user", Password: "password", OtpCode: "000000"})
+	assert.Equal(t, "OTP_INVALID", apperrors.Reason(apperrors.FromStatus(err)))
+
+	_, err = g.Login(context.Background(), &pb.AuthLoginRequest{Login: "user", Password: "password", OtpCode: key.Code(now)})
+	assert.Equal(t, "OTP_INVALID", apperrors.Reason(apperrors.FromStatus(err)), "the code confirming the secret is used")
+
+	now = now.Add(time.Minute)
+	_, err = g.Login(context.Background(), &pb.AuthLoginRequest{Login: "user", Password: "password", OtpCode: key.Code(now)})
+	assert.NoError(t, err)
+	_, err = g.Login(context.Background(), &pb.AuthLoginRequest{Login: "user", Password: "password", OtpCode: key.Code(now)})
+	assert.Equal(t, "OTP_INVALID", apperrors.Reason(apperrors.FromStatus(err)), "codes are accepted once")
+	_, err = g.Login(context.Background(), &pb.AuthLoginRequest{Login: "user", Password: "password", OtpCode: key.Code(now.Add(-30 * time.Second))})
+	assert.Equal(t, "OTP_INVALID", apperrors.Reason(apperrors.FromStatus(err)), "codes older than the used one are rejected")
+
+	recovery := issued.RecoveryCodes[0]
+	_, err = g.Login(context.Background(), &pb.AuthLoginRequest{Login: "user", Password: "password", OtpCode: recovery})
+	assert.NoError(t, err)
+	_, err = g.Login(context.Background(), &pb.AuthLoginRequest{Login: "user", Password: "password", OtpCode: recovery})
+	assert.Equal(t, "OTP_INVALID", apperrors.Reason(apperrors.FromStatus(err)), "recovery codes are used once")
+
+	_, err = g.EnrollTOTP(ctx, &emptypb.Empty{})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	regenerated, err := g.RegenerateRecoveryCodes(ctx, &pb.TOTPCodeRequest{Code: issued.RecoveryCodes[1]})
+	require.NoError(t, err)
+	_, err = g.DisableTOTP(ctx, &pb.TOTPCodeRequest{Code: issued.RecoveryCodes[2]})
+	assert.Equal(t, "OTP_INVALID", apperrors.Reason(apperrors.FromStatus(err)), "old recovery codes are replaced")
+	_, err = g.DisableTOTP(ctx, &pb.TOTPCodeRequest{Code: regenerated.RecoveryCodes[0]})
+	require.NoError(t, err)
+	_, err = g.Login(context.Background(), &pb.AuthLoginRequest{Login: "user", Password: "password"})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,155 @@
+package grpcfuncs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	pb "gophkeeper/proto"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// maxDeviceID - longest device id stored by the server
+const maxDeviceID = 64
+
+// GetDeviceId gets the id of the client device from the context metadata.
+func GetDeviceId(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if value := md.Get("deviceid"); len(value) > 0 && len(value[0]) <= maxDeviceID {
+		return value[0]
+	}
+	return ""
+}
+
+// ackDevice - records that the device of the request received every change made before at
+func (g *GophKeeperServer) ackDevice(ctx context.Context, userID uint32, at time.Time) {
+	device := GetDeviceId(ctx)
+	if device == "" {
+		return
+	}
+	if err := g.db.AckDevice(ctx, userID, device, at); err != nil {
+		slog.WarnContext(ctx, "device acknowledgement failed", slog.Any("error", err))
+	}
+}
+
+// ListDevices handles the request to list the devices of the user.
+func (g *GophKeeperServer) ListDevices(ctx context.Context, in *emptypb.Empty) (*pb.ListDevicesResponse, error) {
+	id, err := g.session(ctx)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	devices, err := g.db.ListDevices(ctx, id)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	var resp pb.ListDevicesResponse
+	for _, v := range devices {
+		resp.Devices = append(resp.Devices, &pb.Device{DeviceId: v.ID, AckedAt: timestamppb.New(v.AckedAt)})
+	}
+	return &resp, nil
+}
+
+// RemoveDevice handles the request to stop waiting for a lost or retired device of the user.
+// Deleted records it did not synchronize can be purged then.
+func (g *GophKeeperServer) RemoveDevice(ctx context.Context, in *pb.DeviceRequest) (*emptypb.Empty, error) {
+	id, err := g.session(ctx)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	if err = g.db.RemoveDevice(ctx, id, in.DeviceId); err != nil {
+		return nil, mapErr(err)
+	}
+	return new(emptypb.Empty), nil
+}
+
+// ListTrash handles the request to list the deleted records of the user.
+func (g *GophKeeperServer) ListTrash(ctx context.Context, in *emptypb.Empty) (*pb.ListTrashResponse, error) {
+	id, err := g.session(ctx)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	data, err := g.db.ListTrash(ctx, id)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	var resp pb.ListTrashResponse
+	for _, v := range data {
+		v.Deleted = true
+		resp.Data = append(resp.Data, toPB(v))
+	}
+	return &resp, nil
+}
+
+// RestoreData handles the request to take a record out of the trash.
+func (g *GophKeeperServer) RestoreData(ctx context.Context, in *pb.GetDataRequest) (*emptypb.Empty, error) {
+	id, err := g.session(ctx)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	if err = g.db.RestoreData(ctx, id, in.DataId); err != nil {
+		return nil, mapErr(err)
+	}
+	return new(emptypb.Empty), nil
+}
+
+// EmptyTrash handles the request to remove the deleted records of the user for good.
+func (g *GophKeeperServer) EmptyTrash(ctx context.Context, in *emptypb.Empty) (*pb.EmptyTrashResponse, error) {
+	id, err := g.session(ctx)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	purged, pending, err := g.db.EmptyTrash(ctx, id)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return &pb.EmptyTrashResponse{Purged: purged, Pending: pending}, nil
+}
+
+// WatchTrash - removes deleted records older than retention and emptied trash every interval until ctx is done
+// A record is removed only after every device of its user synchronized the deletion,
+// devices that did not synchronize for deviceExpiry are forgotten first.
+func (g *GophKeeperServer) WatchTrash(ctx context.Context, interval, retention, deviceExpiry time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		g.expireDevices(ctx, deviceExpiry)
+		g.purgeTrash(ctx, retention)
+	}
+}
+
+// expireDevices - forgets the devices that did not synchronize for expiry, returns their number
+func (g *GophKeeperServer) expireDevices(ctx context.Context, expiry time.Duration) int64 {
+	n, err := g.db.ExpireDevices(ctx, expiry)
+	if err != nil {
+		slog.WarnContext(ctx, "device expiry failed", slog.Any("error", err))
+		return 0
+	}
+	if n > 0 {
+		slog.InfoContext(ctx, "devices expired", slog.Int64("devices", n))
+	}
+	return n
+}
+
+// purgeTrash - removes acknowledged deleted records older than retention, returns the number of removed records
+func (g *GophKeeperServer) purgeTrash(ctx context.Context, retention time.Duration) int64 {
+	n, err := g.db.PurgeAcknowledged(ctx, retention)
+	if err != nil {
+		slog.WarnContext(ctx, "trash purge failed", slog.Any("error", err))
+		return 0
+	}
+	if n > 0 {
+		slog.InfoContext(ctx, "deleted records purged", slog.Int64("records", n))
+	}
+	return n
+}
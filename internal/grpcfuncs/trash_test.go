@@ -0,0 +1,164 @@
+package grpcfuncs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gophkeeper/internal/datamodels"
+	"gophkeeper/internal/sessionstorage"
+	"gophkeeper/internal/storage"
+	pb "gophkeeper/proto"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// trashStorage - storage with deleted records and device acknowledgements kept in memory
+type trashStorage struct {
+	storage.ServerStorage
+	trash     []datamodels.Data
+	acks      map[string]time.Time
+	now       time.Time
+	olderThan time.Duration
+	expiry    time.Duration
+	err       error
+}
+
+func (s *trashStorage) Now(ctx context.Context) (time.Time, error) {
+	return s.now, nil
+}
+
+func (s *trashStorage) Sync(ctx context.Context, userID uint32) ([]datamodels.Data, error) {
+	return s.trash, nil
+}
+
+func (s *trashStorage) AckDevice(ctx context.Context, userID uint32, deviceID string, at time.Time) error {
+	s.acks[deviceID] = at
+	return nil
+}
+
+func (s *trashStorage) ListDevices(ctx context.Context, userID uint32) ([]datamodels.Device, error) {
+	var devices []datamodels.Device
+	for id, at := range s.acks {
+		devices = append(devices, datamodels.Device{ID: id, AckedAt: at})
+	}
+	return devices, nil
+}
+
+func (s *trashStorage) RemoveDevice(ctx context.Context, userID uint32, deviceID string) error {
+	if _, ok := s.acks[deviceID]; !ok {
+		return storage.ErrNotFound
+	}
+	delete(s.acks, deviceID)
+	return nil
+}
+
+func (s *trashStorage) ExpireDevices(ctx context.Context, olderThan time.Duration) (int64, error) {
+	s.expiry = olderThan
+	return 1, s.err
+}
+
+func (s *trashStorage) ListTrash(ctx context.Context, userID uint32) ([]datamodels.Data, error) {
+	return s.trash, nil
+}
+
+func (s *trashStorage) RestoreData(ctx context.Context, userID uint32, dataID string) error {
+	for i := range s.trash {
+		if s.trash[i].DataID == dataID {
+			s.trash = append(s.trash[:i], s.trash[i+1:]...)
+			return nil
+		}
+	}
+	return storage.ErrNotFound
+}
+
+func (s *trashStorage) EmptyTrash(ctx context.Context, userID uint32) (int64, int64, error) {
+	n := int64(len(s.trash))
+	s.trash = nil
+	return n, 0, nil
+}
+
+func (s *trashStorage) PurgeAcknowledged(ctx context.Context, olderThan time.Duration) (int64, error) {
+	s.olderThan = olderThan
+	return 1, s.err
+}
+
+func TestTrash(t *testing.T) {
+	db := &trashStorage{trash: []datamodels.Data{{DataID: "a", Deleted: true}, {DataID: "b", Deleted: true}}}
+	g := GophKeeperServer{db: db, users: sessionstorage.NewAuthUsersStorage()}
+	require.NoError(t, g.users.AddUser("token", 1))
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("userid", "token"))
+
+	_, err := g.ListTrash(context.Background(), new(emptypb.Empty))
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	list, err := g.ListTrash(ctx, new(emptypb.Empty))
+	require.NoError(t, err)
+	require.Len(t, list.Data, 2)
+	assert.True(t, list.Data[0].Deleted)
+
+	_, err = g.RestoreData(ctx, &pb.GetDataRequest{DataId: "a"})
+	require.NoError(t, err)
+	_, err = g.RestoreData(ctx, &pb.GetDataRequest{DataId: "a"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	resp, err := g.EmptyTrash(ctx, new(emptypb.Empty))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), resp.Purged)
+	assert.Empty(t, db.trash)
+}
+
+func TestSyncAcknowledgesDevice(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	db := &trashStorage{acks: map[string]time.Time{}, now: now}
+	g := GophKeeperServer{db: db, users: sessionstorage.NewAuthUsersStorage(), now: func() time.Time { return now.Add(time.Hour) }}
+	require.NoError(t, g.users.AddUser("token", 1))
+
+	_, err := g.Sync(metadata.NewIncomingContext(context.Background(), metadata.Pairs("userid", "token")), new(emptypb.Empty))
+	require.NoError(t, err)
+	assert.Empty(t, db.acks, "clients without a device id are not tracked")
+
+	_, err = g.Sync(metadata.NewIncomingContext(context.Background(), metadata.Pairs("userid", "token", "deviceid", "laptop")), new(emptypb.Empty))
+	require.NoError(t, err)
+	assert.Equal(t, now, db.acks["laptop"], "acknowledged at the database time")
+}
+
+func TestDevices(t *testing.T) {
+	at := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	db := &trashStorage{acks: map[string]time.Time{"laptop": at}}
+	g := GophKeeperServer{db: db, users: sessionstorage.NewAuthUsersStorage()}
+	require.NoError(t, g.users.AddUser("token", 1))
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("userid", "token"))
+
+	list, err := g.ListDevices(ctx, new(emptypb.Empty))
+	require.NoError(t, err)
+	require.Len(t, list.Devices, 1)
+	assert.Equal(t, "laptop", list.Devices[0].DeviceId)
+	assert.Equal(t, at, list.Devices[0].AckedAt.AsTime())
+
+	_, err = g.RemoveDevice(ctx, &pb.DeviceRequest{DeviceId: "laptop"})
+	require.NoError(t, err)
+	assert.Empty(t, db.acks)
+	_, err = g.RemoveDevice(ctx, &pb.DeviceRequest{DeviceId: "laptop"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	assert.Equal(t, int64(1), g.expireDevices(context.Background(), 2160*time.Hour))
+	assert.Equal(t, 2160*time.Hour, db.expiry)
+}
+
+func TestPurgeTrash(t *testing.T) {
+	db := &trashStorage{}
+	g := GophKeeperServer{db: db}
+
+	assert.Equal(t, int64(1), g.purgeTrash(context.Background(), 720*time.Hour))
+	assert.Equal(t, 720*time.Hour, db.olderThan, "the database clock measures the retention")
+
+	db.err = errors.New("connection refused")
+	assert.Zero(t, g.purgeTrash(context.Background(), 720*time.Hour))
+}
@@ -0,0 +1,170 @@
+// Package lockout protects logins from password guessing with exponential backoff and temporary lockouts.
+package lockout
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"gophkeeper/internal/apperrors"
+)
+
+// ReasonLocked - reason of the error returned for blocked attempts
+const ReasonLocked = "LOGIN_LOCKED"
+
+// Policy - limits of failed login attempts
+type Policy struct {
+	// MaxFailures - failures in a row after which the login or peer is locked out
+	MaxFailures int
+	// BaseDelay - delay after the first failure, doubled after every next failure
+	BaseDelay time.Duration
+	// Lockout - duration of the lockout
+	Lockout time.Duration
+}
+
+// NewPolicy returns the default policy.
+func NewPolicy() Policy {
+	return Policy{MaxFailures: 5, BaseDelay: time.Second, Lockout: 15 * time.Minute}
+}
+
+// BlockFor returns how long attempts are blocked after n failures in a row.
+func (p Policy) BlockFor(n int) time.Duration {
+	if n <= 0 {
+		return 0
+	}
+	if p.MaxFailures > 0 && n >= p.MaxFailures {
+		return p.Lockout
+	}
+	d := p.BaseDelay
+	for i := 1; i < n; i++ {
+		d *= 2
+		if d >= p.Lockout {
+			return p.Lockout
+		}
+	}
+	return d
+}
+
+// Store - persistent failure counters of logins
+type Store interface {
+	// LoginFailures returns the failures in a row and the end of the block of the login.
+	LoginFailures(ctx context.Context, login string) (int, time.Time, error)
+	// RecordLoginFailure increments the failures of the login and returns the new count.
+	RecordLoginFailure(ctx context.Context, login string) (int, error)
+	// BlockLogin blocks attempts of the login until the given time.
+	BlockLogin(ctx context.Context, login string, until time.Time) error
+	// ResetLoginFailures clears the failures and the block of the login.
+	ResetLoginFailures(ctx context.Context, login string) error
+}
+
+// peerState - failures of a single peer
+type peerState struct {
+	failures     int
+	blockedUntil time.Time
+	lastFailure  time.Time
+}
+
+// Guard - tracks failed attempts per login in Store and per peer IP in memory
+type Guard struct {
+	policy Policy
+	store  Store
+	mutex  sync.Mutex
+	peers  map[string]*peerState
+	now    func() time.Time
+	// lastSweep - time expired peers were last removed
+	lastSweep time.Time
+}
+
+// NewGuard creates a Guard.
+func NewGuard(policy Policy, store Store) *Guard {
+	return &Guard{policy: policy, store: store, peers: make(map[string]*peerState), now: time.Now}
+}
+
+// Check returns a QuotaExceeded error with the retry delay if attempts of the login or the peer are blocked.
+func (g *Guard) Check(ctx context.Context, login string, peer string) error {
+	now := g.now()
+	g.mutex.Lock()
+	var peerUntil time.Time
+	if st, ok := g.peers[peer]; ok {
+		peerUntil = st.blockedUntil
+	}
+	g.mutex.Unlock()
+	if peerUntil.After(now) {
+		return blocked(peerUntil.Sub(now))
+	}
+	if login == "" {
+		return nil
+	}
+	_, until, err := g.store.LoginFailures(ctx, login)
+	if err != nil {
+		return err
+	}
+	if until.After(now) {
+		return blocked(until.Sub(now))
+	}
+	return nil
+}
+
+// Failure records a failed attempt of the login from the peer.
+func (g *Guard) Failure(ctx context.Context, login string, peer string) error {
+	now := g.now()
+	g.mutex.Lock()
+	g.sweep(now)
+	st, ok := g.peers[peer]
+	if !ok || now.Sub(st.lastFailure) > g.policy.Lockout {
+		st = &peerState{}
+		g.peers[peer] = st
+	}
+	st.failures++
+	st.lastFailure = now
+	st.blockedUntil = now.Add(g.policy.BlockFor(st.failures))
+	g.mutex.Unlock()
+	if login == "" {
+		return nil
+	}
+	n, err := g.store.RecordLoginFailure(ctx, login)
+	if err != nil {
+		return err
+	}
+	return g.store.BlockLogin(ctx, login, now.Add(g.policy.BlockFor(n)))
+}
+
+// sweep - removes the peers whose failures expired and that are not blocked, called with the mutex held
+func (g *Guard) sweep(now time.Time) {
+	if now.Sub(g.lastSweep) < g.policy.Lockout {
+		return
+	}
+	g.lastSweep = now
+	for k, st := range g.peers {
+		if now.Sub(st.lastFailure) > g.policy.Lockout && !st.blockedUntil.After(now) {
+			delete(g.peers, k)
+		}
+	}
+}
+
+// Success clears the failures of the login, failures of the peer expire on their own.
+func (g *Guard) Success(ctx context.Context, login string) error {
+	return g.store.ResetLoginFailures(ctx, login)
+}
+
+// blocked - error returned while attempts are blocked
+func blocked(retry time.Duration) error {
+	retry = retry.Round(time.Second)
+	if retry < time.Second {
+		retry = time.Second
+	}
+	e := apperrors.QuotaExceeded(ReasonLocked, fmt.Sprintf("too many failed login attempts, retry in %s", retry))
+	e.RetryDelay = retry
+	return e
+}
+
+// PeerHost returns the IP of a peer address without the port.
+func PeerHost(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
@@ -0,0 +1,130 @@
+package lockout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"gophkeeper/internal/apperrors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memStore - Store kept in memory
+type memStore struct {
+	failures map[string]int
+	until    map[string]time.Time
+}
+
+func newMemStore() *memStore {
+	return &memStore{failures: make(map[string]int), until: make(map[string]time.Time)}
+}
+
+func (m *memStore) LoginFailures(ctx context.Context, login string) (int, time.Time, error) {
+	return m.failures[login], m.until[login], nil
+}
+
+func (m *memStore) RecordLoginFailure(ctx context.Context, login string) (int, error) {
+	m.failures[login]++
+	return m.failures[login], nil
+}
+
+func (m *memStore) BlockLogin(ctx context.Context, login string, until time.Time) error {
+	m.until[login] = until
+	return nil
+}
+
+func (m *memStore) ResetLoginFailures(ctx context.Context, login string) error {
+	delete(m.failures, login)
+	delete(m.until, login)
+	return nil
+}
+
+func TestPolicy_BlockFor(t *testing.T) {
+	p := Policy{MaxFailures: 5, BaseDelay: time.Second, Lockout: time.Minute}
+	assert.Equal(t, time.Duration(0), p.BlockFor(0))
+	assert.Equal(t, time.Second, p.BlockFor(1))
+	assert.Equal(t, 2*time.Second, p.BlockFor(2))
+	assert.Equal(t, 8*time.Second, p.BlockFor(4))
+	assert.Equal(t, time.Minute, p.BlockFor(5))
+	assert.Equal(t, 10*time.Second, Policy{BaseDelay: 5 * time.Second, Lockout: 10 * time.Second}.BlockFor(3))
+}
+
+func TestGuard(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	g := NewGuard(Policy{MaxFailures: 3, BaseDelay: time.Second, Lockout: time.Minute}, store)
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	g.now = func() time.Time { return now }
+
+	require.NoError(t, g.Check(ctx, "user", "10.0.0.1"))
+	require.NoError(t, g.Failure(ctx, "user", "10.0.0.1"))
+
+	err := g.Check(ctx, "user", "10.0.0.2")
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, apperrors.ErrQuotaExceeded))
+	assert.Equal(t, ReasonLocked, apperrors.Reason(err))
+	var e *apperrors.Error
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, time.Second, e.RetryDelay)
+	assert.Error(t, g.Check(ctx, "other", "10.0.0.1"), "peer is blocked for every login")
+	assert.NoError(t, g.Check(ctx, "other", "10.0.0.2"))
+
+	now = now.Add(time.Second)
+	require.NoError(t, g.Check(ctx, "user", "10.0.0.1"))
+	require.NoError(t, g.Failure(ctx, "user", "10.0.0.1"))
+	require.NoError(t, g.Failure(ctx, "user", "10.0.0.1"))
+	assert.Equal(t, 3, store.failures["user"])
+	assert.Equal(t, now.Add(time.Minute), store.until["user"])
+
+	now = now.Add(30 * time.Second)
+	require.True(t, errors.As(g.Check(ctx, "user", "10.0.0.3"), &e))
+	assert.Equal(t, 30*time.Second, e.RetryDelay)
+
+	require.NoError(t, g.Success(ctx, "user"))
+	assert.NoError(t, g.Check(ctx, "user", "10.0.0.3"))
+}
+
+func TestGuard_PeerExpires(t *testing.T) {
+	ctx := context.Background()
+	g := NewGuard(Policy{MaxFailures: 2, BaseDelay: time.Second, Lockout: time.Minute}, newMemStore())
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	g.now = func() time.Time { return now }
+
+	require.NoError(t, g.Failure(ctx, "", "10.0.0.1"))
+	require.NoError(t, g.Failure(ctx, "", "10.0.0.1"))
+	assert.Error(t, g.Check(ctx, "", "10.0.0.1"))
+
+	now = now.Add(2 * time.Minute)
+	assert.NoError(t, g.Check(ctx, "", "10.0.0.1"))
+	require.NoError(t, g.Failure(ctx, "", "10.0.0.1"))
+	assert.Equal(t, 1, g.peers["10.0.0.1"].failures)
+}
+
+func TestGuard_SweepsPeers(t *testing.T) {
+	ctx := context.Background()
+	g := NewGuard(Policy{MaxFailures: 2, BaseDelay: time.Second, Lockout: time.Minute}, newMemStore())
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	g.now = func() time.Time { return now }
+
+	for i := 0; i < 100; i++ {
+		require.NoError(t, g.Failure(ctx, "", fmt.Sprintf("10.0.0.%d", i)))
+	}
+	require.NoError(t, g.Failure(ctx, "", "10.0.1.1"))
+	assert.Len(t, g.peers, 101)
+
+	now = now.Add(90 * time.Second)
+	require.NoError(t, g.Failure(ctx, "", "10.0.1.1"))
+	require.NoError(t, g.Failure(ctx, "", "10.0.2.1"))
+	assert.Len(t, g.peers, 2, "rotated addresses do not grow the peers without limit")
+	assert.Error(t, g.Check(ctx, "", "10.0.1.1"), "active peers are kept")
+}
+
+func TestPeerHost(t *testing.T) {
+	assert.Equal(t, "10.0.0.1", PeerHost("10.0.0.1:5000"))
+	assert.Equal(t, "::1", PeerHost("[::1]:5000"))
+	assert.Equal(t, "pipe", PeerHost("pipe"))
+}
@@ -0,0 +1,118 @@
+// Package logger provides the structured logger of the server with the redaction policy
+// that keeps user secrets out of every log sink.
+package logger
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"reflect"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Redacted - value written instead of a secret
+const Redacted = "[REDACTED]"
+
+// secretKeys - attribute keys whose values are never logged
+var secretKeys = map[string]bool{
+	"data":      true,
+	"data_info": true,
+	"metainfo":  true,
+	"meta_info": true,
+	"metadata":  true,
+	"password":  true,
+	"secret":    true,
+	"token":     true,
+}
+
+// IsSecretKey reports whether values of the attribute key must be redacted.
+func IsSecretKey(key string) bool {
+	return secretKeys[strings.ToLower(key)]
+}
+
+// New creates a logger writing to w, format is text or json.
+func New(w io.Writer, level string, format string) *slog.Logger {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		lvl = slog.LevelInfo
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+	var h slog.Handler
+	if format == "json" {
+		h = slog.NewJSONHandler(w, opts)
+	} else {
+		h = slog.NewTextHandler(w, opts)
+	}
+	return slog.New(NewRedactHandler(h))
+}
+
+// redactHandler - slog.Handler that redacts secrets before passing records to the next handler
+type redactHandler struct {
+	next slog.Handler
+}
+
+// NewRedactHandler wraps next with the redaction policy.
+func NewRedactHandler(next slog.Handler) slog.Handler {
+	return &redactHandler{next: next}
+}
+
+// Enabled reports whether the next handler handles records at level.
+func (h *redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle redacts the attributes of r and passes it to the next handler.
+func (h *redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	out := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(redact(a))
+		return true
+	})
+	return h.next.Handle(ctx, out)
+}
+
+// WithAttrs returns a handler with redacted attrs.
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		redacted = append(redacted, redact(a))
+	}
+	return &redactHandler{next: h.next.WithAttrs(redacted)}
+}
+
+// WithGroup returns a handler that puts attrs into the group.
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{next: h.next.WithGroup(name)}
+}
+
+// redact - replaces secret values of a and its nested groups
+func redact(a slog.Attr) slog.Attr {
+	if IsSecretKey(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindGroup:
+		group := v.Group()
+		attrs := make([]any, 0, len(group))
+		for _, g := range group {
+			attrs = append(attrs, redact(g))
+		}
+		return slog.Group(a.Key, attrs...)
+	case slog.KindAny:
+		// composite values may carry user data, only types with LogValue are printed as a whole
+		switch x := v.Any().(type) {
+		case error:
+			return slog.String(a.Key, x.Error())
+		case proto.Message:
+			return slog.String(a.Key, string(x.ProtoReflect().Descriptor().FullName()))
+		}
+		switch reflect.Indirect(reflect.ValueOf(v.Any())).Kind() {
+		case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array, reflect.Interface:
+			return slog.String(a.Key, reflect.TypeOf(v.Any()).String())
+		}
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
@@ -0,0 +1,30 @@
+package logger
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"gophkeeper/internal/datamodels"
+	pb "gophkeeper/proto"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactHandler(t *testing.T) {
+	var buf bytes.Buffer
+	log := New(&buf, "debug", "json")
+
+	log.Info("add", slog.String("Data", "secret-1"), slog.Group("req", slog.String("meta_info", "secret-2")))
+	log.Info("sync", slog.Any("record", struct{ Card, Cvc string }{"secret-3", "secret-4"}), slog.Any("ids", []string{"card"}))
+	log.Info("sync", slog.Any("record", &pb.Data{DataId: "card", Data: "secret-5"}))
+	log.Info("get", slog.Any("record", datamodels.Data{DataID: "card", Data: "secret-6", Metadata: "secret-7"}))
+	log.With(slog.String("password", "secret-8")).Info("login")
+
+	out := buf.String()
+	for _, secret := range []string{"secret-1", "secret-2", "secret-3", "secret-4", "secret-5", "secret-6", "secret-7", "secret-8"} {
+		assert.NotContains(t, out, secret)
+	}
+	assert.Contains(t, out, "card")
+	assert.Contains(t, out, Redacted)
+}
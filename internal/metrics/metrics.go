@@ -0,0 +1,175 @@
+// Package metrics provides Prometheus metrics of the gRPC server.
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	pb "gophkeeper/proto"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const namespace = "gophkeeper"
+
+// Metrics - collectors of the server, updated by the interceptors
+type Metrics struct {
+	registry     *prometheus.Registry
+	requests     *prometheus.CounterVec
+	latency      *prometheus.HistogramVec
+	payload      *prometheus.HistogramVec
+	failedLogins prometheus.Counter
+	rateLimited  *prometheus.CounterVec
+}
+
+// New creates and registers the server metrics.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "grpc_requests_total",
+			Help:      "Number of finished RPCs by method and status code.",
+		}, []string{"method", "code"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "grpc_request_duration_seconds",
+			Help:      "Latency of RPCs by method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method"}),
+		payload: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "grpc_payload_bytes",
+			Help:      "Size of request and response messages by method, includes sync payloads.",
+			Buckets:   prometheus.ExponentialBuckets(64, 4, 10),
+		}, []string{"method", "direction"}),
+		failedLogins: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "failed_logins_total",
+			Help:      "Number of rejected Login and Auth requests.",
+		}),
+		rateLimited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rate_limited_total",
+			Help:      "Number of requests rejected by the rate limiter by method.",
+		}, []string{"method"}),
+	}
+	m.registry.MustRegister(m.requests, m.latency, m.payload, m.failedLogins, m.rateLimited)
+	return m
+}
+
+// RegisterSessions exposes the number of active sessions returned by count.
+func (m *Metrics) RegisterSessions(count func() int) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_sessions",
+		Help:      "Number of active user sessions.",
+	}, func() float64 { return float64(count()) }))
+}
+
+// RegisterDBStats exposes the connection pool statistics returned by stats.
+func (m *Metrics) RegisterDBStats(stats func() sql.DBStats) {
+	gauge := func(name string, help string, value func(s sql.DBStats) float64) prometheus.Collector {
+		return prometheus.NewGaugeFunc(prometheus.GaugeOpts{Namespace: namespace, Subsystem: "db", Name: name, Help: help},
+			func() float64 { return value(stats()) })
+	}
+	counter := func(name string, help string, value func(s sql.DBStats) float64) prometheus.Collector {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{Namespace: namespace, Subsystem: "db", Name: name, Help: help},
+			func() float64 { return value(stats()) })
+	}
+	m.registry.MustRegister(
+		gauge("open_connections", "Number of established connections.", func(s sql.DBStats) float64 { return float64(s.OpenConnections) }),
+		gauge("in_use_connections", "Number of connections in use.", func(s sql.DBStats) float64 { return float64(s.InUse) }),
+		gauge("idle_connections", "Number of idle connections.", func(s sql.DBStats) float64 { return float64(s.Idle) }),
+		counter("wait_count_total", "Number of connections waited for.", func(s sql.DBStats) float64 { return float64(s.WaitCount) }),
+		counter("wait_duration_seconds_total", "Time blocked waiting for a connection.", func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }),
+	)
+}
+
+// RateLimited counts a request of the method rejected by the rate limiter.
+func (m *Metrics) RateLimited(method string) {
+	m.rateLimited.WithLabelValues(method).Inc()
+}
+
+// Register adds a collector that is not covered by the interceptors.
+func (m *Metrics) Register(c prometheus.Collector) {
+	m.registry.MustRegister(c)
+}
+
+// Handler returns the http handler of the /metrics endpoint.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// UnaryInterceptor - counts every unary RPC, its latency and message sizes
+func (m *Metrics) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		m.observePayload(info.FullMethod, "request", req)
+		resp, err := handler(ctx, req)
+		if err == nil {
+			m.observePayload(info.FullMethod, "response", resp)
+		}
+		m.observe(info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// StreamInterceptor - counts every stream, its latency and message sizes
+func (m *Metrics) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, &sizeStream{ServerStream: ss, metrics: m, method: info.FullMethod})
+		m.observe(info.FullMethod, start, err)
+		return err
+	}
+}
+
+// observe - records the result of a finished RPC
+func (m *Metrics) observe(method string, start time.Time, err error) {
+	code := status.Code(err)
+	m.requests.WithLabelValues(method, code.String()).Inc()
+	m.latency.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	if method != pb.Gophkeeper_Login_FullMethodName && method != pb.Gophkeeper_Auth_FullMethodName {
+		return
+	}
+	if code == codes.Unauthenticated || code == codes.NotFound {
+		m.failedLogins.Inc()
+	}
+}
+
+// observePayload - records the size of a message
+func (m *Metrics) observePayload(method string, direction string, msg interface{}) {
+	if p, ok := msg.(proto.Message); ok {
+		m.payload.WithLabelValues(method, direction).Observe(float64(proto.Size(p)))
+	}
+}
+
+// sizeStream - grpc.ServerStream that records the size of every message
+type sizeStream struct {
+	grpc.ServerStream
+	metrics *Metrics
+	method  string
+}
+
+// SendMsg records the size of a response message.
+func (s *sizeStream) SendMsg(msg interface{}) error {
+	s.metrics.observePayload(s.method, "response", msg)
+	return s.ServerStream.SendMsg(msg)
+}
+
+// RecvMsg records the size of a request message.
+func (s *sizeStream) RecvMsg(msg interface{}) error {
+	err := s.ServerStream.RecvMsg(msg)
+	if err == nil {
+		s.metrics.observePayload(s.method, "request", msg)
+	}
+	return err
+}
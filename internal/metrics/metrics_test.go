@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+
+	pb "gophkeeper/proto"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryInterceptor(t *testing.T) {
+	m := New()
+	interceptor := m.UnaryInterceptor()
+	login := &grpc.UnaryServerInfo{FullMethod: pb.Gophkeeper_Login_FullMethodName}
+	failed := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.Unauthenticated, "invalid password")
+	}
+	ok := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return &pb.AuthLoginResponse{Id: 1}, nil
+	}
+
+	_, err := interceptor(context.Background(), &pb.AuthLoginRequest{Login: "user"}, login, failed)
+	assert.Error(t, err)
+	_, err = interceptor(context.Background(), &pb.AuthLoginRequest{Login: "user"}, login, ok)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.failedLogins))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.requests.WithLabelValues(pb.Gophkeeper_Login_FullMethodName, "OK")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.requests.WithLabelValues(pb.Gophkeeper_Login_FullMethodName, "Unauthenticated")))
+	assert.Equal(t, 2, testutil.CollectAndCount(m.payload))
+}
+
+func TestRateLimited(t *testing.T) {
+	m := New()
+	m.RateLimited(pb.Gophkeeper_ClientSync_FullMethodName)
+	m.RateLimited(pb.Gophkeeper_ClientSync_FullMethodName)
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.rateLimited.WithLabelValues(pb.Gophkeeper_ClientSync_FullMethodName)))
+}
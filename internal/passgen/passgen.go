@@ -0,0 +1,247 @@
+// Package passgen generates passwords and diceware passphrases from policies using crypto/rand.
+package passgen
+
+import (
+	"bufio"
+	"crypto/rand"
+	_ "embed"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Character classes of passwords
+const (
+	LowerChars  = "abcdefghijklmnopqrstuvwxyz"
+	UpperChars  = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	DigitChars  = "0123456789"
+	SymbolChars = "!#$%&()*+,-./:;<=>?@[]^_{}~"
+	// SimilarChars - characters that are easy to confuse when read
+	SimilarChars = "Il1|O0o"
+)
+
+// Limits of policies
+const (
+	MaxLength = 1024
+	MaxWords  = 64
+)
+
+// ErrPolicy - the policy can not produce a password
+var ErrPolicy = errors.New("invalid password policy")
+
+// eff_large_wordlist.txt - the EFF large wordlist for five dice, https://www.eff.org/dice
+//
+//go:embed eff_large_wordlist.txt
+var wordlist string
+
+// Words - the embedded diceware wordlist
+var Words = parseWordlist(wordlist)
+
+// parseWordlist - words of "rolls<TAB>word" lines
+func parseWordlist(list string) []string {
+	var words []string
+	scanner := bufio.NewScanner(strings.NewReader(list))
+	for scanner.Scan() {
+		if _, word, ok := strings.Cut(scanner.Text(), "\t"); ok {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// Policy - rules of generated passwords, a passphrase of Words words is generated instead when Words is set
+type Policy struct {
+	Length         int    `json:"length,omitempty"`
+	Lower          bool   `json:"lower,omitempty"`
+	Upper          bool   `json:"upper,omitempty"`
+	Digits         bool   `json:"digits,omitempty"`
+	Symbols        bool   `json:"symbols,omitempty"`
+	MinLower       int    `json:"min_lower,omitempty"`
+	MinUpper       int    `json:"min_upper,omitempty"`
+	MinDigits      int    `json:"min_digits,omitempty"`
+	MinSymbols     int    `json:"min_symbols,omitempty"`
+	ExcludeSimilar bool   `json:"exclude_similar,omitempty"`
+	Words          int    `json:"words,omitempty"`
+	Separator      string `json:"separator,omitempty"`
+}
+
+// DefaultPolicy returns the policy used when neither the folder nor the vault has one:
+// 20 characters with at least one character of every class.
+func DefaultPolicy() Policy {
+	return Policy{
+		Length: 20, Lower: true, Upper: true, Digits: true, Symbols: true,
+		MinLower: 1, MinUpper: 1, MinDigits: 1, MinSymbols: 1,
+		Separator: "-",
+	}
+}
+
+// class - characters of a class and how many of them a password needs
+type class struct {
+	name  string
+	chars string
+	on    bool
+	min   int
+}
+
+func (p Policy) classes() []class {
+	return []class{
+		{"lower", LowerChars, p.Lower, p.MinLower},
+		{"upper", UpperChars, p.Upper, p.MinUpper},
+		{"digits", DigitChars, p.Digits, p.MinDigits},
+		{"symbols", SymbolChars, p.Symbols, p.MinSymbols},
+	}
+}
+
+// Validate checks that passwords of the policy can be generated.
+func (p Policy) Validate() error {
+	if p.Words != 0 {
+		if p.Words < 1 || p.Words > MaxWords {
+			return fmt.Errorf("%w: words must be between 1 and %d", ErrPolicy, MaxWords)
+		}
+		return nil
+	}
+	if p.Length < 1 || p.Length > MaxLength {
+		return fmt.Errorf("%w: length must be between 1 and %d", ErrPolicy, MaxLength)
+	}
+	required, enabled := 0, false
+	for _, c := range p.classes() {
+		switch {
+		case c.min < 0:
+			return fmt.Errorf("%w: negative count of %s", ErrPolicy, c.name)
+		case c.min > 0 && !c.on:
+			return fmt.Errorf("%w: %s are required but not allowed", ErrPolicy, c.name)
+		}
+		required += c.min
+		enabled = enabled || c.on
+	}
+	if !enabled {
+		return fmt.Errorf("%w: no character class allowed", ErrPolicy)
+	}
+	if required > p.Length {
+		return fmt.Errorf("%w: %d required characters do not fit in length %d", ErrPolicy, required, p.Length)
+	}
+	return nil
+}
+
+// String describes the policy.
+func (p Policy) String() string {
+	if p.Words > 0 {
+		return fmt.Sprintf("%d words separated by %q", p.Words, p.Separator)
+	}
+	var parts []string
+	for _, c := range p.classes() {
+		if c.on {
+			parts = append(parts, fmt.Sprintf("%s>=%d", c.name, c.min))
+		}
+	}
+	s := fmt.Sprintf("%d characters: %s", p.Length, strings.Join(parts, " "))
+	if p.ExcludeSimilar {
+		s += ", no look-alikes"
+	}
+	return s
+}
+
+// randInt - uniform random number in [0, n)
+func randInt(n int) (int, error) {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(v.Int64()), nil
+}
+
+// pick - random character of the set
+func pick(chars string) (byte, error) {
+	i, err := randInt(len(chars))
+	if err != nil {
+		return 0, err
+	}
+	return chars[i], nil
+}
+
+// without - chars without the characters of exclude
+func without(chars, exclude string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(exclude, r) {
+			return -1
+		}
+		return r
+	}, chars)
+}
+
+// Generate returns a password or a passphrase of the policy.
+func Generate(p Policy) (string, error) {
+	if err := p.Validate(); err != nil {
+		return "", err
+	}
+	if p.Words > 0 {
+		return passphrase(p.Words, p.Separator)
+	}
+	password := make([]byte, 0, p.Length)
+	all := ""
+	for _, c := range p.classes() {
+		if !c.on {
+			continue
+		}
+		chars := c.chars
+		if p.ExcludeSimilar {
+			chars = without(chars, SimilarChars)
+		}
+		all += chars
+		for i := 0; i < c.min; i++ {
+			ch, err := pick(chars)
+			if err != nil {
+				return "", err
+			}
+			password = append(password, ch)
+		}
+	}
+	for len(password) < p.Length {
+		ch, err := pick(all)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, ch)
+	}
+	// required characters must not stay at the start
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := randInt(i + 1)
+		if err != nil {
+			return "", err
+		}
+		password[i], password[j] = password[j], password[i]
+	}
+	return string(password), nil
+}
+
+// passphrase - n random words of the wordlist
+func passphrase(n int, separator string) (string, error) {
+	words := make([]string, n)
+	for i := range words {
+		j, err := randInt(len(Words))
+		if err != nil {
+			return "", err
+		}
+		words[i] = Words[j]
+	}
+	return strings.Join(words, separator), nil
+}
+
+// ForFolder returns the policy of the folder or of the nearest parent folder having one,
+// the policy of the vault stored for the empty folder, or DefaultPolicy.
+func ForFolder(policies map[string]Policy, folder string) Policy {
+	for {
+		if p, ok := policies[folder]; ok {
+			return p
+		}
+		if folder == "" {
+			return DefaultPolicy()
+		}
+		i := strings.LastIndex(folder, "/")
+		if i < 0 {
+			i = 0
+		}
+		folder = folder[:i]
+	}
+}
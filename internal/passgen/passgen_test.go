@@ -0,0 +1,84 @@
+package passgen
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// count - characters of s that are in chars
+func count(s, chars string) int {
+	n := 0
+	for _, r := range s {
+		if strings.ContainsRune(chars, r) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestGenerate(t *testing.T) {
+	p := Policy{Length: 12, Digits: true, Upper: true, MinDigits: 4, MinUpper: 8, ExcludeSimilar: true}
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		password, err := Generate(p)
+		require.NoError(t, err)
+		assert.Len(t, password, 12)
+		assert.Equal(t, 4, count(password, DigitChars))
+		assert.Equal(t, 8, count(password, UpperChars))
+		assert.Zero(t, count(password, SimilarChars), password)
+		seen[password] = true
+	}
+	assert.Len(t, seen, 100)
+
+	password, err := Generate(DefaultPolicy())
+	require.NoError(t, err)
+	assert.Len(t, password, 20)
+	for _, chars := range []string{LowerChars, UpperChars, DigitChars, SymbolChars} {
+		assert.Positive(t, count(password, chars), password)
+	}
+}
+
+func TestPassphrase(t *testing.T) {
+	require.Len(t, Words, 7776)
+	assert.Equal(t, "abacus", Words[0])
+	assert.Equal(t, "zoom", Words[len(Words)-1])
+
+	phrase, err := Generate(Policy{Words: 5, Separator: " "})
+	require.NoError(t, err)
+	words := strings.Split(phrase, " ")
+	require.Len(t, words, 5)
+	for _, w := range words {
+		assert.Contains(t, Words, w)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for name, p := range map[string]Policy{
+		"no classes":     {Length: 10},
+		"too short":      {Length: 2, Digits: true, Lower: true, MinDigits: 2, MinLower: 1},
+		"disabled class": {Length: 10, Lower: true, MinDigits: 1},
+		"negative count": {Length: 10, Lower: true, MinLower: -1},
+		"too long":       {Length: MaxLength + 1, Lower: true},
+		"too many words": {Words: MaxWords + 1},
+		"negative words": {Words: -1},
+	} {
+		_, err := Generate(p)
+		assert.ErrorIs(t, err, ErrPolicy, name)
+	}
+	assert.NoError(t, DefaultPolicy().Validate())
+}
+
+func TestForFolder(t *testing.T) {
+	vault := Policy{Length: 30, Lower: true}
+	work := Policy{Words: 6, Separator: "-"}
+	policies := map[string]Policy{"": vault, "work": work}
+
+	assert.Equal(t, work, ForFolder(policies, "work/servers/db"))
+	assert.Equal(t, work, ForFolder(policies, "work"))
+	assert.Equal(t, vault, ForFolder(policies, "workshop"))
+	assert.Equal(t, vault, ForFolder(policies, ""))
+	assert.Equal(t, DefaultPolicy(), ForFolder(nil, "work"))
+}
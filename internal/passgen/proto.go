@@ -0,0 +1,21 @@
+package passgen
+
+import pb "gophkeeper/proto"
+
+// ToPB converts the policy to its message.
+func ToPB(p Policy) *pb.PasswordPolicy {
+	return &pb.PasswordPolicy{
+		Length: uint32(p.Length), Lower: p.Lower, Upper: p.Upper, Digits: p.Digits, Symbols: p.Symbols,
+		MinLower: uint32(p.MinLower), MinUpper: uint32(p.MinUpper), MinDigits: uint32(p.MinDigits), MinSymbols: uint32(p.MinSymbols),
+		ExcludeSimilar: p.ExcludeSimilar, Words: uint32(p.Words), Separator: p.Separator,
+	}
+}
+
+// FromPB converts the message to a policy.
+func FromPB(m *pb.PasswordPolicy) Policy {
+	return Policy{
+		Length: int(m.GetLength()), Lower: m.GetLower(), Upper: m.GetUpper(), Digits: m.GetDigits(), Symbols: m.GetSymbols(),
+		MinLower: int(m.GetMinLower()), MinUpper: int(m.GetMinUpper()), MinDigits: int(m.GetMinDigits()), MinSymbols: int(m.GetMinSymbols()),
+		ExcludeSimilar: m.GetExcludeSimilar(), Words: int(m.GetWords()), Separator: m.GetSeparator(),
+	}
+}
@@ -0,0 +1,231 @@
+// Package ratelimit limits the rate of gRPC requests with token buckets per caller and per method.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gophkeeper/internal/apperrors"
+
+	"google.golang.org/grpc"
+)
+
+// ReasonLimited - reason of the error returned for rejected requests
+const ReasonLimited = "RATE_LIMITED"
+
+// idleTimeout - buckets unused for this long are removed
+const idleTimeout = 10 * time.Minute
+
+// healthService - prefix of the methods of the health service, probes are never limited
+const healthService = "/grpc.health.v1.Health/"
+
+// Module errors
+var (
+	ErrInvalidLimit = errors.New("invalid rate limit, expected rate:burst")
+)
+
+// Limit - rate of requests per second and the size of bursts, zero rate means no limit
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// ParseLimit parses a limit written as "rate:burst", for example "10:20".
+// A limited rate needs a burst of at least one request, otherwise every request would be rejected.
+func ParseLimit(s string) (Limit, error) {
+	rate, burst, ok := strings.Cut(s, ":")
+	if !ok {
+		return Limit{}, fmt.Errorf("%w: %q", ErrInvalidLimit, s)
+	}
+	r, err := strconv.ParseFloat(rate, 64)
+	if err != nil || r < 0 {
+		return Limit{}, fmt.Errorf("%w: %q", ErrInvalidLimit, s)
+	}
+	b, err := strconv.Atoi(burst)
+	if err != nil || b < 0 || (r > 0 && b < 1) {
+		return Limit{}, fmt.Errorf("%w: %q", ErrInvalidLimit, s)
+	}
+	return Limit{Rate: r, Burst: b}, nil
+}
+
+// String returns the limit in the form accepted by ParseLimit.
+func (l Limit) String() string {
+	return strconv.FormatFloat(l.Rate, 'f', -1, 64) + ":" + strconv.Itoa(l.Burst)
+}
+
+// Config - limits of the server
+type Config struct {
+	// Default - limit of every caller for methods without their own limit
+	Default Limit
+	// Methods - limits of every caller by method, the key is the full method name or the bare RPC name
+	Methods map[string]Limit
+	// Global - limit of all requests to the server together
+	Global Limit
+}
+
+// ParseMethods parses per-method limits written as "Method=rate:burst,Method=rate:burst".
+func ParseMethods(s string) (map[string]Limit, error) {
+	methods := make(map[string]Limit)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		method, limit, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidLimit, item)
+		}
+		l, err := ParseLimit(limit)
+		if err != nil {
+			return nil, err
+		}
+		methods[method] = l
+	}
+	return methods, nil
+}
+
+// limit - limit of the method
+func (c Config) limit(method string) Limit {
+	if l, ok := c.Methods[method]; ok {
+		return l
+	}
+	if l, ok := c.Methods[method[strings.LastIndex(method, "/")+1:]]; ok {
+		return l
+	}
+	return c.Default
+}
+
+// bucket - token bucket
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take - takes a token at now, returns the wait for the next token when the bucket is empty
+func (b *bucket) take(l Limit, now time.Time) (bool, time.Duration) {
+	b.tokens = math.Min(float64(l.Burst), b.tokens+now.Sub(b.last).Seconds()*l.Rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / l.Rate * float64(time.Second))
+}
+
+// bucketKey - bucket of a caller for a method
+type bucketKey struct {
+	method string
+	caller string
+}
+
+// KeyFunc - returns the caller of a request
+type KeyFunc func(ctx context.Context) string
+
+// Limiter - token buckets of the callers
+type Limiter struct {
+	config    Config
+	mutex     sync.Mutex
+	buckets   map[bucketKey]*bucket
+	global    *bucket
+	lastSweep time.Time
+	onLimit   func(method string)
+	now       func() time.Time
+}
+
+// NewLimiter creates a Limiter.
+func NewLimiter(config Config) *Limiter {
+	return &Limiter{
+		config:  config,
+		buckets: make(map[bucketKey]*bucket),
+		global:  &bucket{tokens: float64(config.Global.Burst)},
+		now:     time.Now,
+	}
+}
+
+// OnLimit sets a function called with the method of every rejected request.
+func (l *Limiter) OnLimit(f func(method string)) {
+	l.onLimit = f
+}
+
+// Allow takes a token of the caller for the method, it returns a QuotaExceeded error with the retry delay when none is left.
+// The global budget is only spent by requests the caller bucket lets through, methods of the health service are not limited.
+func (l *Limiter) Allow(method string, caller string) error {
+	if strings.HasPrefix(method, healthService) {
+		return nil
+	}
+	limit := l.config.limit(method)
+	now := l.now()
+	l.mutex.Lock()
+	l.sweep(now)
+	ok, retry := true, time.Duration(0)
+	var b *bucket
+	if limit.Rate > 0 {
+		key := bucketKey{method: method, caller: caller}
+		var found bool
+		b, found = l.buckets[key]
+		if !found {
+			b = &bucket{tokens: float64(limit.Burst), last: now}
+			l.buckets[key] = b
+		}
+		ok, retry = b.take(limit, now)
+	}
+	if ok && l.config.Global.Rate > 0 {
+		ok, retry = l.global.take(l.config.Global, now)
+		if !ok && b != nil {
+			// the request is rejected, the caller keeps its token
+			b.tokens++
+		}
+	}
+	l.mutex.Unlock()
+	if ok {
+		return nil
+	}
+	if l.onLimit != nil {
+		l.onLimit(method)
+	}
+	if retry < time.Millisecond {
+		retry = time.Millisecond
+	}
+	e := apperrors.QuotaExceeded(ReasonLimited, fmt.Sprintf("too many requests, retry in %s", retry.Round(time.Millisecond)))
+	e.RetryDelay = retry
+	return e
+}
+
+// sweep - removes idle buckets, called with the mutex held
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < idleTimeout {
+		return
+	}
+	l.lastSweep = now
+	for k, b := range l.buckets {
+		if now.Sub(b.last) > idleTimeout {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+// UnaryInterceptor - rejects unary requests over the limit of their caller
+func (l *Limiter) UnaryInterceptor(key KeyFunc) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := l.Allow(info.FullMethod, key(ctx)); err != nil {
+			return nil, apperrors.ToStatus(err).Err()
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor - rejects streams over the limit of their caller
+func (l *Limiter) StreamInterceptor(key KeyFunc) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := l.Allow(info.FullMethod, key(ss.Context())); err != nil {
+			return apperrors.ToStatus(err).Err()
+		}
+		return handler(srv, ss)
+	}
+}
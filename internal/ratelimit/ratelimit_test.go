@@ -0,0 +1,103 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gophkeeper/internal/apperrors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseLimit(t *testing.T) {
+	l, err := ParseLimit("2.5:10")
+	require.NoError(t, err)
+	assert.Equal(t, Limit{Rate: 2.5, Burst: 10}, l)
+	assert.Equal(t, "2.5:10", l.String())
+	_, err = ParseLimit("0:0")
+	assert.NoError(t, err, "zero rate is not limited")
+	for _, s := range []string{"", "10", "a:1", "1:b", "-1:1", "5:0"} {
+		_, err = ParseLimit(s)
+		assert.ErrorIs(t, err, ErrInvalidLimit, s)
+	}
+
+	methods, err := ParseMethods("ClientSync=1:5, /proto.Gophkeeper/Sync=2:10")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]Limit{"ClientSync": {Rate: 1, Burst: 5}, "/proto.Gophkeeper/Sync": {Rate: 2, Burst: 10}}, methods)
+	_, err = ParseMethods("ClientSync")
+	assert.ErrorIs(t, err, ErrInvalidLimit)
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	l := NewLimiter(Config{
+		Default: Limit{Rate: 1, Burst: 2},
+		Methods: map[string]Limit{"ClientSync": {Rate: 0.5, Burst: 1}, "/proto.Gophkeeper/Login": {}},
+	})
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+	var limited []string
+	l.OnLimit(func(method string) { limited = append(limited, method) })
+
+	assert.NoError(t, l.Allow("/proto.Gophkeeper/Sync", "user:1"))
+	assert.NoError(t, l.Allow("/proto.Gophkeeper/Sync", "user:1"))
+	err := l.Allow("/proto.Gophkeeper/Sync", "user:1")
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, apperrors.ErrQuotaExceeded))
+	assert.Equal(t, ReasonLimited, apperrors.Reason(err))
+	var e *apperrors.Error
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, time.Second, e.RetryDelay)
+	assert.NoError(t, l.Allow("/proto.Gophkeeper/Sync", "user:2"), "callers have their own buckets")
+
+	assert.NoError(t, l.Allow("/proto.Gophkeeper/ClientSync", "user:1"))
+	assert.Error(t, l.Allow("/proto.Gophkeeper/ClientSync", "user:1"))
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, l.Allow("/proto.Gophkeeper/Login", "peer:10.0.0.1"), "zero rate is not limited")
+	}
+
+	now = now.Add(time.Second)
+	assert.NoError(t, l.Allow("/proto.Gophkeeper/Sync", "user:1"))
+	assert.Error(t, l.Allow("/proto.Gophkeeper/ClientSync", "user:1"))
+	assert.Equal(t, []string{"/proto.Gophkeeper/Sync", "/proto.Gophkeeper/ClientSync", "/proto.Gophkeeper/ClientSync"}, limited)
+
+	now = now.Add(2 * idleTimeout)
+	assert.NoError(t, l.Allow("/proto.Gophkeeper/Sync", "user:3"))
+	assert.Len(t, l.buckets, 1)
+}
+
+func TestLimiter_Global(t *testing.T) {
+	l := NewLimiter(Config{Global: Limit{Rate: 1, Burst: 1}})
+	assert.NoError(t, l.Allow("/proto.Gophkeeper/Sync", "user:1"))
+	assert.Error(t, l.Allow("/proto.Gophkeeper/Sync", "user:2"))
+	assert.NoError(t, l.Allow("/grpc.health.v1.Health/Check", "peer:10.0.0.1"), "health checks are not limited")
+
+	l = NewLimiter(Config{Default: Limit{Rate: 1, Burst: 1}, Global: Limit{Rate: 1, Burst: 2}})
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+	assert.NoError(t, l.Allow("/proto.Gophkeeper/Sync", "user:1"))
+	for i := 0; i < 5; i++ {
+		assert.Error(t, l.Allow("/proto.Gophkeeper/Sync", "user:1"))
+	}
+	assert.NoError(t, l.Allow("/proto.Gophkeeper/Sync", "user:2"), "throttled callers do not spend the global budget")
+	assert.Error(t, l.Allow("/proto.Gophkeeper/Sync", "user:3"))
+	assert.Equal(t, 1.0, l.buckets[bucketKey{method: "/proto.Gophkeeper/Sync", caller: "user:3"}].tokens, "rejected by the global limit keeps the caller token")
+}
+
+func TestLimiter_UnaryInterceptor(t *testing.T) {
+	l := NewLimiter(Config{Default: Limit{Rate: 1, Burst: 1}})
+	interceptor := l.UnaryInterceptor(func(ctx context.Context) string { return "user:1" })
+	info := &grpc.UnaryServerInfo{FullMethod: "/proto.Gophkeeper/Sync"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return "ok", nil }
+
+	resp, err := interceptor(context.Background(), nil, info, handler)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", resp)
+	_, err = interceptor(context.Background(), nil, info, handler)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
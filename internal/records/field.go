@@ -0,0 +1,57 @@
+package records
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gophkeeper/internal/datamodels"
+)
+
+// ErrUnknownField - the record has no such field
+var ErrUnknownField = errors.New("unknown field")
+
+// Field returns a field of the decrypted record, the empty field is the main secret of its kind:
+// the text, the password of a login, the current code of an otp record or the private key of an ssh-key record.
+// Other fields are username, password, text, code, secret, private_key and public_key of the record and notes, folder, url
+// and custom fields of its metadata.
+func Field(d datamodels.Data, field string, now time.Time) (string, error) {
+	r := Decode(d.Data)
+	switch {
+	case (field == "" || field == "text") && r.Kind == KindText:
+		return r.Text, nil
+	case (field == "" || field == "password") && r.Kind == KindLogin && r.Login != nil:
+		return r.Login.Password, nil
+	case field == "username" && r.Kind == KindLogin && r.Login != nil:
+		return r.Login.Username, nil
+	case (field == "" || field == "code") && r.Kind == KindOTP && r.OTP != nil:
+		key, err := r.OTP.Key()
+		if err != nil {
+			return "", err
+		}
+		return key.Code(now), nil
+	case field == "secret" && r.Kind == KindOTP && r.OTP != nil:
+		return r.OTP.Secret, nil
+	case (field == "" || field == "private_key") && r.Kind == KindSSHKey && r.SSHKey != nil:
+		return r.SSHKey.PrivateKey, nil
+	case field == "public_key" && r.Kind == KindSSHKey && r.SSHKey != nil:
+		return r.SSHKey.PublicKey()
+	case field == "":
+		return d.Data, nil
+	}
+	m := DecodeMeta(d.Metadata)
+	if v, ok := m.Fields[field]; ok {
+		return v, nil
+	}
+	switch field {
+	case "notes":
+		return m.Notes, nil
+	case "folder":
+		return m.Folder, nil
+	case "url":
+		if len(m.URLs) > 0 {
+			return m.URLs[0], nil
+		}
+	}
+	return "", fmt.Errorf("%w %q of %s record %q", ErrUnknownField, field, r.Kind, d.DataID)
+}
@@ -0,0 +1,86 @@
+package records
+
+import (
+	"path"
+	"strings"
+	"time"
+
+	"gophkeeper/internal/datamodels"
+)
+
+// Masked - shown instead of secrets that were not asked to be revealed
+const Masked = "********"
+
+// Filter - conditions of the records listed by the client, zero fields match every record
+type Filter struct {
+	// Kinds - any of the kinds
+	Kinds []Kind
+	// Tags - every one of the tags
+	Tags []string
+	// Folder - in the folder or one of its subfolders
+	Folder string
+	// Fields - custom fields with the values
+	Fields map[string]string
+	// Favorite - favorites only
+	Favorite bool
+	// Name - substring of the data id, or a glob pattern when it has *, ? or [
+	Name string
+	// Since - changed at or after
+	Since time.Time
+}
+
+// Match reports whether the decrypted record satisfies the filter.
+func (f Filter) Match(d datamodels.Data) bool {
+	if !f.Since.IsZero() && d.ChangedAt.Before(f.Since) {
+		return false
+	}
+	if f.Name != "" && !matchName(f.Name, d.DataID) {
+		return false
+	}
+	if len(f.Kinds) > 0 {
+		kind := Decode(d.Data).Kind
+		found := false
+		for _, k := range f.Kinds {
+			found = found || k == kind
+		}
+		if !found {
+			return false
+		}
+	}
+	meta := DecodeMeta(d.Metadata)
+	if f.Favorite && !meta.Favorite || !meta.InFolder(f.Folder) {
+		return false
+	}
+	for _, tag := range f.Tags {
+		if !meta.HasTag(tag) {
+			return false
+		}
+	}
+	for k, v := range f.Fields {
+		if value, ok := meta.Fields[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
+
+// matchName - glob match of the whole data id or case-insensitive substring match
+func matchName(pattern, dataID string) bool {
+	if strings.ContainsAny(pattern, "*?[") {
+		ok, err := path.Match(pattern, dataID)
+		return err == nil && ok
+	}
+	return strings.Contains(strings.ToLower(dataID), strings.ToLower(pattern))
+}
+
+// Search reports whether the data id or any metadata field of the decrypted record contains every word of the query, ignoring case.
+// Secrets are never searched.
+func Search(d datamodels.Data, query string) bool {
+	text := strings.ToLower(d.DataID + "\n" + DecodeMeta(d.Metadata).text())
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		if !strings.Contains(text, word) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,136 @@
+package records
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// metaVersion - marks metadata encoded by EncodeMeta
+const metaVersion = 1
+
+// Meta - decoded Metadata field, encrypted together with it
+type Meta struct {
+	Tags     []string          `json:"tags,omitempty"`
+	Folder   string            `json:"folder,omitempty"`
+	URLs     []string          `json:"urls,omitempty"`
+	Notes    string            `json:"notes,omitempty"`
+	Fields   map[string]string `json:"fields,omitempty"`
+	Favorite bool              `json:"favorite,omitempty"`
+}
+
+// encodedMeta - Meta with the version telling it from notes that look like json
+type encodedMeta struct {
+	V int `json:"v"`
+	Meta
+}
+
+// EncodeMeta returns the Metadata field, metadata with notes only is kept as plain text.
+func EncodeMeta(m Meta) string {
+	m.Folder = CleanFolder(m.Folder)
+	if len(m.Tags) == 0 && m.Folder == "" && len(m.URLs) == 0 && len(m.Fields) == 0 && !m.Favorite && !strings.HasPrefix(m.Notes, "{") {
+		return m.Notes
+	}
+	b, err := json.Marshal(encodedMeta{V: metaVersion, Meta: m})
+	if err != nil {
+		return m.Notes
+	}
+	return string(b)
+}
+
+// DecodeMeta parses the Metadata field, anything that is not encoded metadata is notes.
+func DecodeMeta(metadata string) Meta {
+	if strings.HasPrefix(metadata, "{") {
+		var m encodedMeta
+		if err := json.Unmarshal([]byte(metadata), &m); err == nil && m.V == metaVersion {
+			return m.Meta
+		}
+	}
+	return Meta{Notes: metadata}
+}
+
+// CleanFolder returns the folder path without empty parts, like work/servers.
+func CleanFolder(folder string) string {
+	var parts []string
+	for _, p := range strings.Split(folder, "/") {
+		if p = strings.TrimSpace(p); p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// InFolder reports whether the folder is the given one or one of its subfolders.
+func (m Meta) InFolder(folder string) bool {
+	folder = CleanFolder(folder)
+	return folder == "" || m.Folder == folder || strings.HasPrefix(m.Folder, folder+"/")
+}
+
+// HasTag reports whether the metadata has the tag, ignoring case.
+func (m Meta) HasTag(tag string) bool {
+	for _, t := range m.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseFields parses key=value pairs of custom fields.
+func ParseFields(pairs []string) (map[string]string, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+	fields := make(map[string]string, len(pairs))
+	for _, p := range pairs {
+		k, v, ok := strings.Cut(p, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			return nil, fmt.Errorf("invalid field %q: use key=value", p)
+		}
+		fields[strings.TrimSpace(k)] = v
+	}
+	return fields, nil
+}
+
+// String returns the metadata in one line for listings.
+func (m Meta) String() string {
+	var parts []string
+	if m.Favorite {
+		parts = append(parts, "favorite")
+	}
+	if m.Folder != "" {
+		parts = append(parts, "folder="+m.Folder)
+	}
+	if len(m.Tags) > 0 {
+		parts = append(parts, "tags="+strings.Join(m.Tags, ","))
+	}
+	if len(m.URLs) > 0 {
+		parts = append(parts, "urls="+strings.Join(m.URLs, ","))
+	}
+	keys := make([]string, 0, len(m.Fields))
+	for k := range m.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		parts = append(parts, k+"="+m.Fields[k])
+	}
+	if m.Notes != "" {
+		parts = append(parts, m.Notes)
+	}
+	return strings.Join(parts, " ")
+}
+
+// text - every searchable value of the metadata
+func (m Meta) text() string {
+	var b strings.Builder
+	for _, s := range append(append([]string{m.Folder, m.Notes}, m.Tags...), m.URLs...) {
+		b.WriteString(s)
+		b.WriteByte('\n')
+	}
+	for k, v := range m.Fields {
+		b.WriteString(k + "=" + v + "\n")
+	}
+	return b.String()
+}
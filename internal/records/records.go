@@ -0,0 +1,206 @@
+// Package records defines the kinds of records kept in the Data field and their encoding.
+package records
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gophkeeper/internal/totp"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Kind - kind of a record
+type Kind string
+
+// Record kinds
+const (
+	// KindText - free text, the kind of records written before kinds existed
+	KindText Kind = "text"
+	// KindOTP - TOTP generator seed
+	KindOTP Kind = "otp"
+	// KindLogin - username and password of a site or service
+	KindLogin Kind = "login"
+	// KindSSHKey - OpenSSH private key served by the ssh agent
+	KindSSHKey Kind = "ssh-key"
+)
+
+// recordVersion - marks records encoded by Encode
+const recordVersion = 1
+
+// Module errors
+var (
+	ErrUnknownKind = errors.New("unknown record kind")
+	ErrWrongKind   = errors.New("record has another kind")
+)
+
+// OTP - seed of a TOTP generator
+type OTP struct {
+	Secret    string `json:"secret"`
+	Algorithm string `json:"algorithm"`
+	Digits    int    `json:"digits"`
+	Period    int    `json:"period"`
+	Issuer    string `json:"issuer,omitempty"`
+	Account   string `json:"account,omitempty"`
+}
+
+// NewOTP parses an otpauth:// URI, the text of authenticator QR codes.
+func NewOTP(uri string) (OTP, error) {
+	key, issuer, account, err := totp.ParseURI(uri)
+	if err != nil {
+		return OTP{}, err
+	}
+	return OTP{
+		Secret:    totp.EncodeSecret(key.Secret),
+		Algorithm: key.Algorithm,
+		Digits:    key.Digits,
+		Period:    int(key.Period / time.Second),
+		Issuer:    issuer,
+		Account:   account,
+	}, nil
+}
+
+// Key returns the TOTP generator of the seed.
+func (o OTP) Key() (totp.Key, error) {
+	secret, err := totp.DecodeSecret(o.Secret)
+	if err != nil {
+		return totp.Key{}, err
+	}
+	key := totp.Key{Secret: secret, Algorithm: o.Algorithm, Digits: o.Digits, Period: time.Duration(o.Period) * time.Second}
+	return key, key.Validate()
+}
+
+// Login - credentials of a site or service, its urls are kept in the metadata
+type Login struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// NewLogin parses username:password, the username can not contain a colon.
+func NewLogin(value string) (Login, error) {
+	username, password, ok := strings.Cut(value, ":")
+	if !ok {
+		return Login{}, fmt.Errorf("%w: login record is username:password", ErrWrongKind)
+	}
+	return Login{Username: username, Password: password}, nil
+}
+
+// SSHKey - private key in PEM format, keys encrypted with a passphrase are kept decrypted
+// The record itself is encrypted with the vault, a passphrase kept next to the key would not protect it.
+type SSHKey struct {
+	PrivateKey string `json:"private_key"`
+}
+
+// NewSSHKey decrypts the PEM key with the passphrase, empty for keys without one.
+// Keys without passphrase are kept as written by ssh-keygen, decrypted keys are kept in the PKCS#8 PEM format.
+// ssh.PassphraseMissingError is returned for encrypted keys without passphrase.
+func NewSSHKey(key string, passphrase string) (SSHKey, error) {
+	if passphrase == "" {
+		if _, err := ssh.ParseRawPrivateKey([]byte(key)); err != nil {
+			return SSHKey{}, err
+		}
+		return SSHKey{PrivateKey: key}, nil
+	}
+	raw, err := ssh.ParseRawPrivateKeyWithPassphrase([]byte(key), []byte(passphrase))
+	if err != nil {
+		return SSHKey{}, err
+	}
+	// x509 marshals ed25519 keys by value
+	if k, ok := raw.(*ed25519.PrivateKey); ok {
+		raw = *k
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(raw)
+	if err != nil {
+		return SSHKey{}, err
+	}
+	return SSHKey{PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))}, nil
+}
+
+// Raw returns the decrypted private key.
+func (k SSHKey) Raw() (any, error) {
+	return ssh.ParseRawPrivateKey([]byte(k.PrivateKey))
+}
+
+// PublicKey returns the public key in the authorized_keys format.
+func (k SSHKey) PublicKey() (string, error) {
+	raw, err := k.Raw()
+	if err != nil {
+		return "", err
+	}
+	signer, err := ssh.NewSignerFromKey(raw)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signer.PublicKey()))), nil
+}
+
+// Record - decoded Data field
+type Record struct {
+	Kind   Kind    `json:"kind"`
+	Text   string  `json:"text,omitempty"`
+	OTP    *OTP    `json:"otp,omitempty"`
+	Login  *Login  `json:"login,omitempty"`
+	SSHKey *SSHKey `json:"ssh_key,omitempty"`
+}
+
+// encodedRecord - Record with the version telling it from text that looks like json
+type encodedRecord struct {
+	V int `json:"v"`
+	Record
+}
+
+// Encode returns the Data field of the record, text records are kept as plain text unless they look like json.
+func Encode(r Record) (string, error) {
+	switch r.Kind {
+	case KindText, "":
+		if !strings.HasPrefix(r.Text, "{") {
+			return r.Text, nil
+		}
+		r = Record{Kind: KindText, Text: r.Text}
+	case KindOTP:
+		if r.OTP == nil {
+			return "", fmt.Errorf("%w: otp record without seed", ErrWrongKind)
+		}
+	case KindLogin:
+		if r.Login == nil {
+			return "", fmt.Errorf("%w: login record without credentials", ErrWrongKind)
+		}
+	case KindSSHKey:
+		if r.SSHKey == nil {
+			return "", fmt.Errorf("%w: ssh-key record without key", ErrWrongKind)
+		}
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownKind, r.Kind)
+	}
+	b, err := json.Marshal(encodedRecord{V: recordVersion, Record: r})
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// Decode parses the Data field, anything that is not an encoded record is a text record.
+func Decode(data string) Record {
+	if strings.HasPrefix(data, "{") {
+		var r encodedRecord
+		if err := json.Unmarshal([]byte(data), &r); err == nil && r.V == recordVersion && r.Kind != "" {
+			return r.Record
+		}
+	}
+	return Record{Kind: KindText, Text: data}
+}
+
+// ParseKind checks the name of a kind.
+func ParseKind(s string) (Kind, error) {
+	switch k := Kind(s); k {
+	case KindText, KindOTP, KindLogin, KindSSHKey:
+		return k, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownKind, s)
+}
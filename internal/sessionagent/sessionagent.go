@@ -0,0 +1,140 @@
+// Package sessionagent keeps the unlocked session of the client in the memory of the unlock command
+// and hands it to the commands run without credentials over a unix socket.
+package sessionagent
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"gophkeeper/internal/datamodels"
+)
+
+// Module errors
+var (
+	ErrNoAgent = errors.New("no session agent")
+	ErrRunning = errors.New("another session agent listens on the socket")
+)
+
+// requests of the protocol, one line per connection
+const (
+	requestGet  = "get"
+	requestLock = "lock"
+)
+
+// dialTimeout - time to connect to the agent and to read its answer
+const dialTimeout = time.Second
+
+// Listen creates the unix socket of the agent readable by the user only.
+// The socket is expected in a directory of the user only, so it is not reachable before its mode is set.
+func Listen(path string) (net.Listener, error) {
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.DialTimeout("unix", path, dialTimeout); err == nil {
+			conn.Close()
+			return nil, ErrRunning
+		}
+		// socket of an agent that was killed
+		if err = os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// Serve hands the session to the clients of the listener until ctx is done, the session expires or a client locks it.
+// Closing the listener removes the socket.
+func Serve(ctx context.Context, l net.Listener, s datamodels.Session) error {
+	ctx, cancel := context.WithDeadline(ctx, s.Expires)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(dialTimeout))
+			request, err := bufio.NewReader(conn).ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch request[:len(request)-1] {
+			case requestGet:
+				json.NewEncoder(conn).Encode(s)
+			case requestLock:
+				// the socket is gone before the client is answered by closing the connection
+				cancel()
+				l.Close()
+			}
+		}()
+	}
+}
+
+// call - sends the request to the agent listening on path, ErrNoAgent is returned when there is none
+func call(path string, request string) (net.Conn, error) {
+	conn, err := net.DialTimeout("unix", path, dialTimeout)
+	if err != nil {
+		return nil, ErrNoAgent
+	}
+	conn.SetDeadline(time.Now().Add(dialTimeout))
+	if _, err = conn.Write([]byte(request + "\n")); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Get returns the session kept by the agent listening on path, ErrNoAgent is returned when there is none.
+func Get(path string) (datamodels.Session, error) {
+	conn, err := call(path, requestGet)
+	if err != nil {
+		return datamodels.Session{}, err
+	}
+	defer conn.Close()
+	var s datamodels.Session
+	if err = json.NewDecoder(conn).Decode(&s); err != nil {
+		return datamodels.Session{}, errors.New("failed to decode session")
+	}
+	return s, nil
+}
+
+// Lock stops the agent listening on path, nothing is done when there is none.
+func Lock(path string) error {
+	conn, err := call(path, requestLock)
+	if errors.Is(err, ErrNoAgent) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// the agent closes the connection once it stopped listening
+	defer conn.Close()
+	if _, err = conn.Read(make([]byte, 1)); errors.Is(err, os.ErrDeadlineExceeded) {
+		return err
+	}
+	return nil
+}
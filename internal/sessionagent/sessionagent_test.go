@@ -0,0 +1,63 @@
+package sessionagent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gophkeeper/internal/datamodels"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serve - agent of the session listening in a temporary directory, the returned channel gets the result of Serve
+func serve(t *testing.T, ctx context.Context, s datamodels.Session) (string, chan error) {
+	path := filepath.Join(t.TempDir(), "session.sock")
+	l, err := Listen(path)
+	require.NoError(t, err)
+	done := make(chan error, 1)
+	go func() { done <- Serve(ctx, l, s) }()
+	return path, done
+}
+
+func TestAgent(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	session := datamodels.Session{Login: "u", ID: 1, Token: "token", Key: []byte("key"), Expires: time.Now().Add(time.Hour).Round(0)}
+	path, done := serve(t, ctx, session)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	_, err = Listen(path)
+	assert.ErrorIs(t, err, ErrRunning)
+
+	got, err := Get(path)
+	require.NoError(t, err)
+	assert.True(t, session.Expires.Equal(got.Expires))
+	got.Expires = session.Expires
+	assert.Equal(t, session, got)
+
+	require.NoError(t, Lock(path))
+	require.NoError(t, <-done)
+	_, err = Get(path)
+	assert.ErrorIs(t, err, ErrNoAgent, "the key is gone with the agent")
+	_, err = os.Stat(path)
+	assert.ErrorIs(t, err, os.ErrNotExist, "the socket is removed")
+	assert.NoError(t, Lock(path), "locking without an agent does nothing")
+}
+
+func TestAgent_Expires(t *testing.T) {
+	path, done := serve(t, context.Background(), datamodels.Session{Login: "u", Expires: time.Now().Add(50 * time.Millisecond)})
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the agent did not stop when the session expired")
+	}
+	_, err := Get(path)
+	assert.ErrorIs(t, err, ErrNoAgent)
+}
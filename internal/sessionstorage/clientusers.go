@@ -26,6 +26,21 @@ func (u *UserSession) AddUser(login string, password string, id uint32) error {
 	return nil
 }
 
+// SetUser adds the user or replaces the cached password and ID.
+func (u *UserSession) SetUser(login string, password string, id uint32) {
+	u.users[login] = datamodels.Login{Password: password, ID: id}
+}
+
+// PutUser adds the user or replaces the cached credentials and vault keys.
+func (u *UserSession) PutUser(login string, user datamodels.Login) {
+	u.users[login] = user
+}
+
+// DelUser removes the user from the session storage.
+func (u *UserSession) DelUser(login string) {
+	delete(u.users, login)
+}
+
 // GetUser retrieves a user from the session storage based on the login.
 // It returns the user and a boolean indicating if the user exists.
 func (u *UserSession) GetUser(login string) (datamodels.Login, bool) {
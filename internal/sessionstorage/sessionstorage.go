@@ -11,6 +11,10 @@ type SessionStorage interface {
 	AddUser(user string, id uint32) error
 
 	GetUser(user string) (uint32, error)
+
+	Len() int
+
+	RevokeUser(id uint32, keep string) int
 }
 
 // authUsersStorage is an implementation of SessionStorage that stores user session data in memory.
@@ -42,3 +46,24 @@ func (us *authUsersStorage) GetUser(user string) (uint32, error) {
 	}
 	return id, nil
 }
+
+// Len returns the number of active sessions.
+func (us *authUsersStorage) Len() int {
+	us.mutex.RLock()
+	defer us.mutex.RUnlock()
+	return len(us.authUsers)
+}
+
+// RevokeUser removes the sessions of the user except keep and returns the number of removed sessions.
+func (us *authUsersStorage) RevokeUser(id uint32, keep string) int {
+	us.mutex.Lock()
+	defer us.mutex.Unlock()
+	n := 0
+	for token, user := range us.authUsers {
+		if user == id && token != keep {
+			delete(us.authUsers, token)
+			n++
+		}
+	}
+	return n
+}
@@ -47,3 +47,23 @@ func ExampleAuthUsersStorage_AddUser_GetUser() {
 	//Output:
 	//0
 }
+func ExampleUserSession_SetUser() {
+	user := Init()
+	user.SetUser("login", "old", 1)
+	user.SetUser("login", "new", 1)
+	u, _ := user.GetUser("login")
+	fmt.Println(u.Password)
+	//Output:
+	//new
+}
+func Example_revokeUser() {
+	users := NewAuthUsersStorage()
+	users.AddUser("current", 1)
+	users.AddUser("other", 1)
+	users.AddUser("stranger", 2)
+	fmt.Println(users.RevokeUser(1, "current"))
+	fmt.Println(users.Len())
+	//Output:
+	//1
+	//2
+}
@@ -0,0 +1,208 @@
+// Package sshagent serves the ssh-key records of the vault over the ssh-agent protocol.
+package sshagent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gophkeeper/internal/datamodels"
+	"gophkeeper/internal/records"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// Module errors
+var (
+	ErrDenied   = errors.New("use of the key was denied")
+	ErrReadOnly = errors.New("keys are added to the vault, not to the agent")
+	ErrRunning  = errors.New("another agent listens on the socket")
+)
+
+// Confirm - asks the user whether the key with the comment may sign, nil signs without asking
+type Confirm func(comment string) bool
+
+// Options - settings of the agent
+type Options struct {
+	// Lifetime - keys are removed from the agent after it, zero keeps them until the agent stops
+	Lifetime time.Duration
+	Confirm  Confirm
+}
+
+// Agent - keyring of the vault keys, keys are only kept in memory
+type Agent struct {
+	agent.ExtendedAgent
+	confirm Confirm
+	// one question is asked at a time
+	mu sync.Mutex
+}
+
+// New decrypts the ssh-key records into a keyring, the data id is the comment of the key.
+func New(data []datamodels.Data, opts Options) (*Agent, error) {
+	keyring := agent.NewKeyring().(agent.ExtendedAgent)
+	for _, d := range data {
+		r := records.Decode(d.Data)
+		if r.Kind != records.KindSSHKey || r.SSHKey == nil {
+			continue
+		}
+		raw, err := r.SSHKey.Raw()
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", d.DataID, err)
+		}
+		key := agent.AddedKey{PrivateKey: raw, Comment: d.DataID, LifetimeSecs: uint32(opts.Lifetime / time.Second)}
+		if err = keyring.Add(key); err != nil {
+			return nil, fmt.Errorf("key %s: %w", d.DataID, err)
+		}
+	}
+	return &Agent{ExtendedAgent: keyring, confirm: opts.Confirm}, nil
+}
+
+// allowed - asks for confirmation of the signature with the key
+func (a *Agent) allowed(key ssh.PublicKey) error {
+	if a.confirm == nil {
+		return nil
+	}
+	keys, err := a.List()
+	if err != nil {
+		return err
+	}
+	wanted := key.Marshal()
+	for _, k := range keys {
+		if !bytes.Equal(k.Blob, wanted) {
+			continue
+		}
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if !a.confirm(k.Comment) {
+			return ErrDenied
+		}
+		return nil
+	}
+	// the keyring reports unknown keys
+	return nil
+}
+
+// Sign signs with the key after the confirmation.
+func (a *Agent) Sign(key ssh.PublicKey, data []byte) (*ssh.Signature, error) {
+	if err := a.allowed(key); err != nil {
+		return nil, err
+	}
+	return a.ExtendedAgent.Sign(key, data)
+}
+
+// SignWithFlags signs with the key and the algorithm of the flags after the confirmation.
+func (a *Agent) SignWithFlags(key ssh.PublicKey, data []byte, flags agent.SignatureFlags) (*ssh.Signature, error) {
+	if err := a.allowed(key); err != nil {
+		return nil, err
+	}
+	return a.ExtendedAgent.SignWithFlags(key, data, flags)
+}
+
+// Add refuses keys, ssh-add would keep them outside the vault.
+func (a *Agent) Add(key agent.AddedKey) error {
+	return ErrReadOnly
+}
+
+// socketListener - listener of a socket moved to path after it was created, closing it removes the socket
+type socketListener struct {
+	net.Listener
+	path string
+}
+
+// Close removes the socket and stops listening, Serve returns once Accept fails, the socket is gone then.
+func (l *socketListener) Close() error {
+	os.Remove(l.path)
+	return l.Listener.Close()
+}
+
+// Addr returns the address the clients connect to.
+func (l *socketListener) Addr() net.Addr {
+	return &net.UnixAddr{Name: l.path, Net: "unix"}
+}
+
+// Listen creates the unix socket readable by the user only,
+// the socket is placed in a private temporary directory when path is empty.
+// Otherwise it is created in a private directory next to path and moved there once only the user can reach it,
+// so other users of a shared directory can not connect in between.
+func Listen(path string) (net.Listener, error) {
+	// the system temporary directory
+	base := ""
+	if path != "" {
+		if _, err := os.Stat(path); err == nil {
+			if conn, err := net.Dial("unix", path); err == nil {
+				conn.Close()
+				return nil, ErrRunning
+			}
+			// socket of an agent that was killed
+			if err = os.Remove(path); err != nil {
+				return nil, err
+			}
+		}
+		base = filepath.Dir(path)
+	}
+	dir, err := os.MkdirTemp(base, "gophkeeper-agent-")
+	if err != nil {
+		return nil, err
+	}
+	socket := filepath.Join(dir, "agent.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		os.Remove(dir)
+		return nil, err
+	}
+	if err = os.Chmod(socket, 0600); err != nil {
+		l.Close()
+		os.Remove(dir)
+		return nil, err
+	}
+	if path == "" {
+		return l, nil
+	}
+	defer os.Remove(dir)
+	if err = os.Rename(socket, path); err != nil {
+		l.Close()
+		return nil, err
+	}
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	return &socketListener{Listener: l, path: path}, nil
+}
+
+// Serve answers the clients of the listener until ctx is done, closing the listener removes the socket.
+func Serve(ctx context.Context, l net.Listener, a agent.Agent) error {
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			done := make(chan struct{})
+			defer close(done)
+			go func() {
+				select {
+				case <-ctx.Done():
+				case <-done:
+				}
+				conn.Close()
+			}()
+			agent.ServeAgent(a, conn)
+		}()
+	}
+}
@@ -2,13 +2,19 @@
 package storage
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
 
+	"gophkeeper/internal/apperrors"
 	"gophkeeper/internal/datamodels"
-	"gophkeeper/internal/utils"
+	"gophkeeper/internal/passgen"
 	pb "gophkeeper/proto"
 
 	"github.com/golang-migrate/migrate/v4"
@@ -19,6 +25,9 @@ import (
 	_ "github.com/jackc/pgx/v5/stdlib"
 )
 
+// MaxHistoryRetention - maximum number of older revisions kept per record
+const MaxHistoryRetention = 100
+
 // dbSecret - secret key for cipher
 var dbSecret = []byte("alskdjfhgnbvcmrt")
 
@@ -27,8 +36,93 @@ type DBStorage struct {
 	db *sql.DB
 }
 
+// ServerStorage - Storage with the operations only the server side database supports
+type ServerStorage interface {
+	Storage
+	// Ping checks that the database is reachable.
+	Ping(ctx context.Context) error
+	// Close closes the database connections.
+	Close() error
+	// Stats returns the connection pool statistics.
+	Stats() sql.DBStats
+	// LoginFailures returns the failed logins in a row and the end of the block of the login.
+	LoginFailures(ctx context.Context, login string) (int, time.Time, error)
+	// RecordLoginFailure increments the failed logins of the login and returns the new count.
+	RecordLoginFailure(ctx context.Context, login string) (int, error)
+	// BlockLogin blocks login attempts until the given time.
+	BlockLogin(ctx context.Context, login string, until time.Time) error
+	// ResetLoginFailures clears the failed logins and the block of the login.
+	ResetLoginFailures(ctx context.Context, login string) error
+	// SetTOTPSecret stores a not yet confirmed TOTP secret of the user.
+	SetTOTPSecret(ctx context.Context, userID uint32, secret string) error
+	// TOTPSecret returns the TOTP secret of the user and whether two-factor authentication is enabled.
+	TOTPSecret(ctx context.Context, userID uint32) (string, bool, error)
+	// EnableTOTP enables two-factor authentication of the user and replaces the recovery codes.
+	EnableTOTP(ctx context.Context, userID uint32, recoveryHashes []string) error
+	// DisableTOTP removes the TOTP secret and the recovery codes of the user.
+	DisableTOTP(ctx context.Context, userID uint32) error
+	// UseRecoveryCode removes the recovery code of the user, ErrNotFound is returned for unknown codes.
+	UseRecoveryCode(ctx context.Context, userID uint32, recoveryHash string) error
+	// TOTPCounter returns the time step of the last TOTP code accepted from the user, 0 when there is none.
+	TOTPCounter(ctx context.Context, userID uint32) (uint64, error)
+	// UseTOTPCounter stores the time step of an accepted TOTP code, ErrNotFound is returned when it is not above the last one.
+	UseTOTPCounter(ctx context.Context, userID uint32, counter uint64) error
+	// ChangePassword replaces the password hash of the user if oldPassword matches, otherwise ErrWrongPassword is returned.
+	ChangePassword(ctx context.Context, userID uint32, oldPassword string, newPassword string) error
+	// UserLogin returns the login of the user.
+	UserLogin(ctx context.Context, userID uint32) (string, error)
+	// DeleteUser removes the user and every record of the user if password matches, otherwise ErrWrongPassword is returned.
+	DeleteUser(ctx context.Context, userID uint32, password string) error
+	// Export calls send for every record of the user including deleted ones.
+	Export(ctx context.Context, userID uint32, send func(datamodels.Data) error) error
+	// ListUsers returns the state and the storage usage of every user ordered by login.
+	ListUsers(ctx context.Context) ([]datamodels.UserStats, error)
+	// SetDisabled disables or enables the login, sessions of disabled users are revoked.
+	SetDisabled(ctx context.Context, login string, disabled bool) error
+	// RevokeSessions asks the servers to end every session of the login.
+	RevokeSessions(ctx context.Context, login string) error
+	// RevokedUsers returns users whose sessions must end: disabled users and users revoked after since.
+	// The returned time is the latest revocation seen, pass it as since to the next call.
+	RevokedUsers(ctx context.Context, since time.Time) ([]uint32, time.Time, error)
+	// PurgeTombstones removes records deleted more than olderThan ago, of every user when login is empty.
+	PurgeTombstones(ctx context.Context, login string, olderThan time.Duration) (int64, error)
+	// ListVersions returns the current revision of the record followed by the kept older revisions, newest first.
+	ListVersions(ctx context.Context, userID uint32, dataID string) ([]datamodels.Version, error)
+	// GetVersion returns the content of a revision of the record.
+	GetVersion(ctx context.Context, userID uint32, dataID string, revision uint32) (datamodels.Data, error)
+	// SetHistoryRetention sets how many older revisions of every record of the user are kept.
+	SetHistoryRetention(ctx context.Context, userID uint32, versions int) error
+	// UpdateData changes the fields of the record listed in mask (MaskDataID, MaskData, MaskMetaInfo).
+	// A revision other than 0 must be the current revision of the record, otherwise ErrRevision is returned.
+	UpdateData(ctx context.Context, userID uint32, dataID string, data datamodels.Data, mask []string, revision uint32) (datamodels.Data, error)
+	// Now returns the time of the database clock, deletions and revocations are stamped with it.
+	Now(ctx context.Context) (time.Time, error)
+	// AckDevice records that the device of the user received every change made before at, a time of the database clock.
+	AckDevice(ctx context.Context, userID uint32, deviceID string, at time.Time) error
+	// ListDevices returns the devices of the user and when they synchronized last, latest first.
+	ListDevices(ctx context.Context, userID uint32) ([]datamodels.Device, error)
+	// RemoveDevice stops waiting for the device of the user to synchronize deletions, ErrNotFound is returned for unknown devices.
+	RemoveDevice(ctx context.Context, userID uint32, deviceID string) error
+	// ExpireDevices removes the devices that did not synchronize for olderThan and returns their number.
+	ExpireDevices(ctx context.Context, olderThan time.Duration) (int64, error)
+	// ListTrash returns the deleted records of the user that are not being purged, ChangedAt holds the deletion time.
+	ListTrash(ctx context.Context, userID uint32) ([]datamodels.Data, error)
+	// RestoreData undeletes the record, ErrNotFound is returned when it is not in the trash.
+	RestoreData(ctx context.Context, userID uint32, dataID string) error
+	// EmptyTrash removes the deleted records acknowledged by every device of the user and marks the others
+	// to be removed by PurgeAcknowledged. It returns the number of removed and of marked records.
+	EmptyTrash(ctx context.Context, userID uint32) (int64, int64, error)
+	// PurgeAcknowledged removes deleted records that every device of their user acknowledged
+	// and that were deleted more than olderThan ago or marked by EmptyTrash.
+	PurgeAcknowledged(ctx context.Context, olderThan time.Duration) (int64, error)
+	// PasswordPolicies returns the password policies of the folders of the vault, the empty folder holds the vault default.
+	PasswordPolicies(ctx context.Context, userID uint32) (map[string]passgen.Policy, error)
+	// SetPasswordPolicy sets the password policy of the folder, a nil policy removes it.
+	SetPasswordPolicy(ctx context.Context, userID uint32, folder string, policy *passgen.Policy) error
+}
+
 // NewDBStorage creates a new DBStorage instance with the provided database path.
-func NewDBStorage(path string) (Storage, error) {
+func NewDBStorage(path string) (ServerStorage, error) {
 	if path == "" {
 		return nil, errors.New("invalid db address")
 	}
@@ -52,80 +146,776 @@ func NewDBStorage(path string) (Storage, error) {
 	return &DBStorage{db: db}, nil
 }
 
+// folderHash - key of the settings of the folder, the server does not store the folder in plaintext
+func folderHash(folder string) string {
+	mac := hmac.New(sha256.New, dbSecret)
+	mac.Write([]byte(folder))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Ping checks that the database is reachable.
+func (dbs *DBStorage) Ping(ctx context.Context) error {
+	if err := dbs.db.PingContext(ctx); err != nil {
+		return apperrors.Internal(err)
+	}
+	return nil
+}
+
+// Close closes the database connections.
+func (dbs *DBStorage) Close() error {
+	return dbs.db.Close()
+}
+
+// Stats returns the connection pool statistics.
+func (dbs *DBStorage) Stats() sql.DBStats {
+	return dbs.db.Stats()
+}
+
 // Auth adds a new user with the provided login and password to the storage.
-func (dbs *DBStorage) Auth(login string, password string) error {
-	_, err := dbs.db.Exec("insert into users (login, password) values ($1, $2);", login, password)
+func (dbs *DBStorage) Auth(ctx context.Context, login string, password string) error {
+	if login == "" {
+		return apperrors.Validation("invalid user", apperrors.FieldViolation{Field: "login", Description: "must not be empty"})
+	}
+	_, err := execContext(ctx, dbs.db, "insert into users (login, password) values ($1, $2);", login, password)
 	var pgErr *pgconn.PgError
 	if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
 		return ErrDuplicate
 	}
-	return err
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+	return nil
 }
 
 // Login verifies the login credentials of a user and returns the user ID if successful.
-func (dbs *DBStorage) Login(login string, password string) (uint32, error) {
-	rows := dbs.db.QueryRow("select id,password from users where login=$1 limit 1;", login)
+func (dbs *DBStorage) Login(ctx context.Context, login string, password string) (uint32, error) {
+	rows := queryRowContext(ctx, dbs.db, "select id,password,disabled from users where login=$1 limit 1;", login)
 	var v datamodels.Login
-	err := rows.Scan(&v.ID, &v.Password)
+	var disabled bool
+	err := rows.Scan(&v.ID, &v.Password, &disabled)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrUserNotFound
+	}
 	if err != nil {
-		return 0, ErrNotFound
+		return 0, apperrors.Internal(err)
 	}
 	if v.Password != password {
 		return 0, ErrWrongPassword
 	}
+	if disabled {
+		return 0, ErrDisabled
+	}
 	return v.ID, nil
 }
 
+// LoginFailures returns the failed logins in a row and the end of the block of the login.
+// Unknown logins have no failures.
+func (dbs *DBStorage) LoginFailures(ctx context.Context, login string) (int, time.Time, error) {
+	row := queryRowContext(ctx, dbs.db, "select failed_logins, locked_until from users where login=$1;", login)
+	var n int
+	var until sql.NullTime
+	err := row.Scan(&n, &until)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, time.Time{}, nil
+	}
+	if err != nil {
+		return 0, time.Time{}, apperrors.Internal(err)
+	}
+	return n, until.Time, nil
+}
+
+// RecordLoginFailure increments the failed logins of the login and returns the new count.
+func (dbs *DBStorage) RecordLoginFailure(ctx context.Context, login string) (int, error) {
+	row := queryRowContext(ctx, dbs.db, "update users set failed_logins=failed_logins+1 where login=$1 returning failed_logins;", login)
+	var n int
+	err := row.Scan(&n)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, apperrors.Internal(err)
+	}
+	return n, nil
+}
+
+// BlockLogin blocks login attempts until the given time.
+func (dbs *DBStorage) BlockLogin(ctx context.Context, login string, until time.Time) error {
+	_, err := execContext(ctx, dbs.db, "update users set locked_until=$2 where login=$1;", login, until)
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+	return nil
+}
+
+// ResetLoginFailures clears the failed logins and the block of the login.
+func (dbs *DBStorage) ResetLoginFailures(ctx context.Context, login string) error {
+	res, err := execContext(ctx, dbs.db, "update users set failed_logins=0, locked_until=null where login=$1;", login)
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// ChangePassword replaces the password hash of the user if oldPassword matches, otherwise ErrWrongPassword is returned.
+// The check and the update are a single statement, so concurrent changes cannot both succeed.
+func (dbs *DBStorage) ChangePassword(ctx context.Context, userID uint32, oldPassword string, newPassword string) error {
+	res, err := execContext(ctx, dbs.db, "update users set password=$3 where id=$1 and password=$2;", userID, oldPassword, newPassword)
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrWrongPassword
+	}
+	return nil
+}
+
+// UserLogin returns the login of the user.
+func (dbs *DBStorage) UserLogin(ctx context.Context, userID uint32) (string, error) {
+	row := queryRowContext(ctx, dbs.db, "select login from users where id=$1;", userID)
+	var login string
+	err := row.Scan(&login)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrUserNotFound
+	}
+	if err != nil {
+		return "", apperrors.Internal(err)
+	}
+	return login, nil
+}
+
+// DeleteUser removes the user and every record of the user if password matches, otherwise ErrWrongPassword is returned.
+func (dbs *DBStorage) DeleteUser(ctx context.Context, userID uint32, password string) error {
+	tx, err := dbs.db.BeginTx(ctx, nil)
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+	defer tx.Rollback()
+	row := queryRowContext(ctx, tx, "select password from users where id=$1 for update;", userID)
+	var stored string
+	err = row.Scan(&stored)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+	if stored != password {
+		return ErrWrongPassword
+	}
+	for _, query := range []string{
+		"delete from keeper where user_id=$1;",
+		"delete from recovery_codes where user_id=$1;",
+		"delete from users where id=$1;",
+	} {
+		if _, err = execContext(ctx, tx, query, userID); err != nil {
+			return apperrors.Internal(err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return apperrors.Internal(err)
+	}
+	return nil
+}
+
+// Export calls send for every record of the user including deleted ones.
+// Rows are sent while they are read, so the export does not hold the whole vault in memory.
+func (dbs *DBStorage) Export(ctx context.Context, userID uint32, send func(datamodels.Data) error) error {
+	rows, err := queryContext(ctx, dbs.db, "SELECT data_id,data_info,meta_info,deleted,changed_at from keeper where user_id=$1 order by data_id;", userID)
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		v := datamodels.Data{UserID: userID}
+		if err = rows.Scan(&v.DataID, &v.Data, &v.Metadata, &v.Deleted, &v.ChangedAt); err != nil {
+			return apperrors.Internal(err)
+		}
+		v.Data = decrypt(ctx, v.Data, dbSecret)
+		v.Metadata = decrypt(ctx, v.Metadata, dbSecret)
+		if err = send(v); err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return apperrors.Internal(err)
+	}
+	return nil
+}
+
+// ListUsers returns the state and the storage usage of every user ordered by login.
+// The usage is the size of the encrypted data and meta info.
+func (dbs *DBStorage) ListUsers(ctx context.Context) ([]datamodels.UserStats, error) {
+	query := `select u.id, u.login, u.disabled, u.totp_enabled, u.failed_logins, u.locked_until,
+       count(k.id), count(k.id) filter (where k.deleted),
+       coalesce(sum(length(k.data_info) + coalesce(length(k.meta_info), 0)), 0)
+from users u left join keeper k on k.user_id = u.id
+group by u.id order by u.login;`
+	rows, err := queryContext(ctx, dbs.db, query)
+	if err != nil {
+		return nil, apperrors.Internal(err)
+	}
+	defer rows.Close()
+	var resp []datamodels.UserStats
+	for rows.Next() {
+		var v datamodels.UserStats
+		var lockedUntil sql.NullTime
+		err = rows.Scan(&v.ID, &v.Login, &v.Disabled, &v.TOTP, &v.FailedLogins, &lockedUntil, &v.Records, &v.Deleted, &v.Bytes)
+		if err != nil {
+			return nil, apperrors.Internal(err)
+		}
+		v.LockedUntil = lockedUntil.Time
+		resp = append(resp, v)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, apperrors.Internal(err)
+	}
+	return resp, nil
+}
+
+// SetDisabled disables or enables the login, sessions of disabled users are revoked.
+func (dbs *DBStorage) SetDisabled(ctx context.Context, login string, disabled bool) error {
+	res, err := execContext(ctx, dbs.db, "update users set disabled=$2 where login=$1;", login, disabled)
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// RevokeSessions asks the servers to end every session of the login.
+func (dbs *DBStorage) RevokeSessions(ctx context.Context, login string) error {
+	res, err := execContext(ctx, dbs.db, "update users set sessions_revoked_at=now() where login=$1;", login)
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// RevokedUsers returns users whose sessions must end: disabled users and users revoked after since.
+// The returned time is the latest revocation seen, pass it as since to the next call.
+func (dbs *DBStorage) RevokedUsers(ctx context.Context, since time.Time) ([]uint32, time.Time, error) {
+	rows, err := queryContext(ctx, dbs.db, "select id, sessions_revoked_at from users where disabled or sessions_revoked_at > $1;", since)
+	if err != nil {
+		return nil, since, apperrors.Internal(err)
+	}
+	defer rows.Close()
+	var ids []uint32
+	latest := since
+	for rows.Next() {
+		var id uint32
+		var revokedAt sql.NullTime
+		if err = rows.Scan(&id, &revokedAt); err != nil {
+			return nil, since, apperrors.Internal(err)
+		}
+		if revokedAt.Time.After(latest) {
+			latest = revokedAt.Time
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, since, apperrors.Internal(err)
+	}
+	return ids, latest, nil
+}
+
+// PurgeTombstones removes records deleted more than olderThan ago, of every user when login is empty.
+// The age is measured by the database clock that stamps deletions, changed_at is set by the clients.
+func (dbs *DBStorage) PurgeTombstones(ctx context.Context, login string, olderThan time.Duration) (int64, error) {
+	query := `with purged as (
+    delete from keeper where deleted and deleted_at < now() - make_interval(secs => $1) and ($2 = '' or user_id = (select id from users where login=$2))
+    returning user_id, data_id
+), history as (
+    delete from keeper_history h using purged p where h.user_id = p.user_id and h.data_id = p.data_id
+)
+select count(*) from purged;`
+	var n int64
+	if err := queryRowContext(ctx, dbs.db, query, olderThan.Seconds(), login).Scan(&n); err != nil {
+		return 0, apperrors.Internal(err)
+	}
+	return n, nil
+}
+
+// ListVersions returns the current revision of the record followed by the kept older revisions, newest first.
+func (dbs *DBStorage) ListVersions(ctx context.Context, userID uint32, dataID string) ([]datamodels.Version, error) {
+	query := `select revision, changed_at, true from keeper where user_id=$1 and data_id=$2
+union all
+select revision, changed_at, false from keeper_history where user_id=$1 and data_id=$2
+order by 1 desc;`
+	rows, err := queryContext(ctx, dbs.db, query, userID, dataID)
+	if err != nil {
+		return nil, apperrors.Internal(err)
+	}
+	defer rows.Close()
+	var resp []datamodels.Version
+	for rows.Next() {
+		var v datamodels.Version
+		if err = rows.Scan(&v.Revision, &v.ChangedAt, &v.Current); err != nil {
+			return nil, apperrors.Internal(err)
+		}
+		resp = append(resp, v)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, apperrors.Internal(err)
+	}
+	if resp == nil {
+		return nil, ErrNotFound
+	}
+	return resp, nil
+}
+
+// GetVersion returns the content of a revision of the record.
+func (dbs *DBStorage) GetVersion(ctx context.Context, userID uint32, dataID string, revision uint32) (datamodels.Data, error) {
+	query := `select data_info, meta_info, changed_at from keeper where user_id=$1 and data_id=$2 and revision=$3
+union all
+select data_info, meta_info, changed_at from keeper_history where user_id=$1 and data_id=$2 and revision=$3
+limit 1;`
+	v := datamodels.Data{UserID: userID, DataID: dataID}
+	err := queryRowContext(ctx, dbs.db, query, userID, dataID, revision).Scan(&v.Data, &v.Metadata, &v.ChangedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return datamodels.Data{}, ErrVersionNotFound
+	}
+	if err != nil {
+		return datamodels.Data{}, apperrors.Internal(err)
+	}
+	v.Data = decrypt(ctx, v.Data, dbSecret)
+	v.Metadata = decrypt(ctx, v.Metadata, dbSecret)
+	return v, nil
+}
+
+// SetHistoryRetention sets how many older revisions of every record of the user are kept.
+// Revisions beyond the new retention are removed at once.
+func (dbs *DBStorage) SetHistoryRetention(ctx context.Context, userID uint32, versions int) error {
+	if versions < 0 || versions > MaxHistoryRetention {
+		return apperrors.Validation("invalid retention", apperrors.FieldViolation{Field: "versions", Description: fmt.Sprintf("must be between 0 and %d", MaxHistoryRetention)})
+	}
+	tx, err := dbs.db.BeginTx(ctx, nil)
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+	defer tx.Rollback()
+	if _, err = execContext(ctx, tx, "update users set history_retention=$2 where id=$1;", userID, versions); err != nil {
+		return apperrors.Internal(err)
+	}
+	trim := `delete from keeper_history h using keeper k
+where h.user_id=$1 and k.user_id=$1 and k.data_id=h.data_id and h.revision <= k.revision - 1 - $2;`
+	if _, err = execContext(ctx, tx, trim, userID, versions); err != nil {
+		return apperrors.Internal(err)
+	}
+	if err = tx.Commit(); err != nil {
+		return apperrors.Internal(err)
+	}
+	return nil
+}
+
+// Fields of records changed by UpdateData
+const (
+	MaskDataID   = "data_id"
+	MaskData     = "data"
+	MaskMetaInfo = "meta_info"
+)
+
+// UpdateData changes the fields of the record listed in mask (MaskDataID, MaskData, MaskMetaInfo).
+// A revision other than 0 must be the current revision of the record, otherwise ErrRevision is returned.
+// The history trigger keeps the replaced content, renamed records keep their history and leave a tombstone of the old id.
+func (dbs *DBStorage) UpdateData(ctx context.Context, userID uint32, dataID string, data datamodels.Data, mask []string, revision uint32) (datamodels.Data, error) {
+	var rename, setData, setMeta bool
+	for _, path := range mask {
+		rename = rename || path == MaskDataID
+		setData = setData || path == MaskData
+		setMeta = setMeta || path == MaskMetaInfo
+	}
+	newID := dataID
+	if rename {
+		newID = data.DataID
+	}
+	query := `update keeper set data_id=$3,
+    data_info=case when $4 then $5 else data_info end,
+    meta_info=case when $6 then $7 else meta_info end,
+    changed_at=$8
+where user_id=$1 and data_id=$2 and not deleted and ($9 = 0 or revision = $9)
+returning revision, changed_at;`
+	tx, err := dbs.db.BeginTx(ctx, nil)
+	if err != nil {
+		return datamodels.Data{}, apperrors.Internal(err)
+	}
+	defer tx.Rollback()
+	if newID != dataID {
+		// a deleted record of the new id is replaced by the renamed one
+		if _, err = execContext(ctx, tx, "delete from keeper where user_id=$1 and data_id=$2 and deleted;", userID, newID); err != nil {
+			return datamodels.Data{}, apperrors.Internal(err)
+		}
+	}
+	resp := datamodels.Data{UserID: userID, DataID: newID}
+	err = queryRowContext(ctx, tx, query, userID, dataID, newID,
+		setData, encrypt(ctx, data.Data, dbSecret), setMeta, encrypt(ctx, data.Metadata, dbSecret),
+		data.ChangedAt, revision).Scan(&resp.Revision, &resp.ChangedAt)
+	if err == nil && newID != dataID {
+		// other devices still keep the old id, the tombstone deletes it there instead of their next ClientSync adding it again
+		query = `insert into keeper (data_id, user_id, data_info, meta_info, changed_at, deleted, deleted_at)
+values ($2, $1, $3, $3, $4, true, now());`
+		if _, err = execContext(ctx, tx, query, userID, dataID, encrypt(ctx, "", dbSecret), data.ChangedAt); err != nil {
+			return datamodels.Data{}, apperrors.Internal(err)
+		}
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+		return datamodels.Data{}, ErrDataExists
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		var current uint32
+		err = queryRowContext(ctx, dbs.db, "select revision from keeper where user_id=$1 and data_id=$2 and not deleted;", userID, dataID).Scan(&current)
+		if errors.Is(err, sql.ErrNoRows) {
+			return datamodels.Data{}, ErrNotFound
+		}
+		if err != nil {
+			return datamodels.Data{}, apperrors.Internal(err)
+		}
+		return datamodels.Data{}, ErrRevision
+	}
+	if err != nil {
+		return datamodels.Data{}, apperrors.Internal(err)
+	}
+	return resp, nil
+}
+
+// acknowledged - condition of tombstones that every device of their user received, k is the keeper row
+// Both times come from the database clock, changed_at is set by the clients and can not be compared.
+const acknowledged = `k.deleted_at < coalesce((select min(d.acked_at) from devices d where d.user_id = k.user_id), 'infinity')`
+
+// Now returns the time of the database clock, deletions and revocations are stamped with it.
+func (dbs *DBStorage) Now(ctx context.Context) (time.Time, error) {
+	var now time.Time
+	if err := queryRowContext(ctx, dbs.db, "select now();").Scan(&now); err != nil {
+		return time.Time{}, apperrors.Internal(err)
+	}
+	return now, nil
+}
+
+// AckDevice records that the device of the user received every change made before at, a time of the database clock.
+func (dbs *DBStorage) AckDevice(ctx context.Context, userID uint32, deviceID string, at time.Time) error {
+	query := `insert into devices (user_id, device_id, acked_at) values ($1, $2, $3)
+ON CONFLICT (user_id, device_id) DO UPDATE SET acked_at=greatest(devices.acked_at, EXCLUDED.acked_at);`
+	if _, err := execContext(ctx, dbs.db, query, userID, deviceID, at); err != nil {
+		return apperrors.Internal(err)
+	}
+	return nil
+}
+
+// ListDevices returns the devices of the user and when they synchronized last, latest first.
+func (dbs *DBStorage) ListDevices(ctx context.Context, userID uint32) ([]datamodels.Device, error) {
+	rows, err := queryContext(ctx, dbs.db, "select device_id, acked_at from devices where user_id=$1 order by acked_at desc;", userID)
+	if err != nil {
+		return nil, apperrors.Internal(err)
+	}
+	defer rows.Close()
+	var resp []datamodels.Device
+	for rows.Next() {
+		var v datamodels.Device
+		if err = rows.Scan(&v.ID, &v.AckedAt); err != nil {
+			return nil, apperrors.Internal(err)
+		}
+		resp = append(resp, v)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, apperrors.Internal(err)
+	}
+	return resp, nil
+}
+
+// RemoveDevice stops waiting for the device of the user to synchronize deletions, ErrNotFound is returned for unknown devices.
+func (dbs *DBStorage) RemoveDevice(ctx context.Context, userID uint32, deviceID string) error {
+	res, err := execContext(ctx, dbs.db, "delete from devices where user_id=$1 and device_id=$2;", userID, deviceID)
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ExpireDevices removes the devices that did not synchronize for olderThan and returns their number.
+func (dbs *DBStorage) ExpireDevices(ctx context.Context, olderThan time.Duration) (int64, error) {
+	res, err := execContext(ctx, dbs.db, "delete from devices where acked_at < now() - make_interval(secs => $1);", olderThan.Seconds())
+	if err != nil {
+		return 0, apperrors.Internal(err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, apperrors.Internal(err)
+	}
+	return n, nil
+}
+
+// ListTrash returns the deleted records of the user that are not being purged, ChangedAt holds the deletion time.
+func (dbs *DBStorage) ListTrash(ctx context.Context, userID uint32) ([]datamodels.Data, error) {
+	rows, err := queryContext(ctx, dbs.db, "select data_id, data_info, meta_info, deleted_at from keeper where user_id=$1 and deleted and not purge_requested order by deleted_at desc;", userID)
+	if err != nil {
+		return nil, apperrors.Internal(err)
+	}
+	defer rows.Close()
+	var resp []datamodels.Data
+	for rows.Next() {
+		v := datamodels.Data{UserID: userID, Deleted: true}
+		if err = rows.Scan(&v.DataID, &v.Data, &v.Metadata, &v.ChangedAt); err != nil {
+			return nil, apperrors.Internal(err)
+		}
+		v.Data = decrypt(ctx, v.Data, dbSecret)
+		v.Metadata = decrypt(ctx, v.Metadata, dbSecret)
+		resp = append(resp, v)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, apperrors.Internal(err)
+	}
+	return resp, nil
+}
+
+// RestoreData undeletes the record, ErrNotFound is returned when it is not in the trash.
+func (dbs *DBStorage) RestoreData(ctx context.Context, userID uint32, dataID string) error {
+	res, err := execContext(ctx, dbs.db, "update keeper set deleted=false, deleted_at=null, purge_requested=false, changed_at=now() where user_id=$1 and data_id=$2 and deleted and not purge_requested;", userID, dataID)
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// EmptyTrash removes the deleted records acknowledged by every device of the user and marks the others
+// to be removed by PurgeAcknowledged. It returns the number of removed and of marked records.
+func (dbs *DBStorage) EmptyTrash(ctx context.Context, userID uint32) (int64, int64, error) {
+	tx, err := dbs.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, apperrors.Internal(err)
+	}
+	defer tx.Rollback()
+	purge := `with purged as (
+    delete from keeper k where k.user_id=$1 and k.deleted and ` + acknowledged + `
+    returning k.user_id, k.data_id
+), history as (
+    delete from keeper_history h using purged p where h.user_id = p.user_id and h.data_id = p.data_id
+)
+select count(*) from purged;`
+	var purged int64
+	if err = queryRowContext(ctx, tx, purge, userID).Scan(&purged); err != nil {
+		return 0, 0, apperrors.Internal(err)
+	}
+	res, err := execContext(ctx, tx, "update keeper set purge_requested=true where user_id=$1 and deleted and not purge_requested;", userID)
+	if err != nil {
+		return 0, 0, apperrors.Internal(err)
+	}
+	pending, err := res.RowsAffected()
+	if err != nil {
+		return 0, 0, apperrors.Internal(err)
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, 0, apperrors.Internal(err)
+	}
+	return purged, pending, nil
+}
+
+// PurgeAcknowledged removes deleted records that every device of their user acknowledged
+// and that were deleted more than olderThan ago or marked by EmptyTrash.
+func (dbs *DBStorage) PurgeAcknowledged(ctx context.Context, olderThan time.Duration) (int64, error) {
+	query := `with purged as (
+    delete from keeper k where k.deleted and (k.deleted_at < now() - make_interval(secs => $1) or k.purge_requested) and ` + acknowledged + `
+    returning k.user_id, k.data_id
+), history as (
+    delete from keeper_history h using purged p where h.user_id = p.user_id and h.data_id = p.data_id
+)
+select count(*) from purged;`
+	var n int64
+	if err := queryRowContext(ctx, dbs.db, query, olderThan.Seconds()).Scan(&n); err != nil {
+		return 0, apperrors.Internal(err)
+	}
+	return n, nil
+}
+
+// SetTOTPSecret stores a not yet confirmed TOTP secret of the user.
+func (dbs *DBStorage) SetTOTPSecret(ctx context.Context, userID uint32, secret string) error {
+	_, err := execContext(ctx, dbs.db, "update users set totp_secret=$2, totp_enabled=false, totp_last_counter=0 where id=$1;", userID, encrypt(ctx, secret, dbSecret))
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+	return nil
+}
+
+// TOTPSecret returns the TOTP secret of the user and whether two-factor authentication is enabled.
+func (dbs *DBStorage) TOTPSecret(ctx context.Context, userID uint32) (string, bool, error) {
+	row := queryRowContext(ctx, dbs.db, "select totp_secret, totp_enabled from users where id=$1;", userID)
+	var secret sql.NullString
+	var enabled bool
+	err := row.Scan(&secret, &enabled)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, ErrUserNotFound
+	}
+	if err != nil {
+		return "", false, apperrors.Internal(err)
+	}
+	if !secret.Valid {
+		return "", false, nil
+	}
+	return decrypt(ctx, secret.String, dbSecret), enabled, nil
+}
+
+// EnableTOTP enables two-factor authentication of the user and replaces the recovery codes.
+func (dbs *DBStorage) EnableTOTP(ctx context.Context, userID uint32, recoveryHashes []string) error {
+	tx, err := dbs.db.BeginTx(ctx, nil)
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+	defer tx.Rollback()
+	if _, err = execContext(ctx, tx, "update users set totp_enabled=true where id=$1;", userID); err != nil {
+		return apperrors.Internal(err)
+	}
+	if _, err = execContext(ctx, tx, "delete from recovery_codes where user_id=$1;", userID); err != nil {
+		return apperrors.Internal(err)
+	}
+	for _, h := range recoveryHashes {
+		if _, err = execContext(ctx, tx, "insert into recovery_codes (user_id, code_hash) values ($1, $2);", userID, h); err != nil {
+			return apperrors.Internal(err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return apperrors.Internal(err)
+	}
+	return nil
+}
+
+// DisableTOTP removes the TOTP secret and the recovery codes of the user.
+func (dbs *DBStorage) DisableTOTP(ctx context.Context, userID uint32) error {
+	tx, err := dbs.db.BeginTx(ctx, nil)
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+	defer tx.Rollback()
+	if _, err = execContext(ctx, tx, "update users set totp_secret=null, totp_enabled=false, totp_last_counter=0 where id=$1;", userID); err != nil {
+		return apperrors.Internal(err)
+	}
+	if _, err = execContext(ctx, tx, "delete from recovery_codes where user_id=$1;", userID); err != nil {
+		return apperrors.Internal(err)
+	}
+	if err = tx.Commit(); err != nil {
+		return apperrors.Internal(err)
+	}
+	return nil
+}
+
+// UseRecoveryCode removes the recovery code of the user, ErrNotFound is returned for unknown codes.
+func (dbs *DBStorage) UseRecoveryCode(ctx context.Context, userID uint32, recoveryHash string) error {
+	res, err := execContext(ctx, dbs.db, "delete from recovery_codes where user_id=$1 and code_hash=$2;", userID, recoveryHash)
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// TOTPCounter returns the time step of the last TOTP code accepted from the user, 0 when there is none.
+func (dbs *DBStorage) TOTPCounter(ctx context.Context, userID uint32) (uint64, error) {
+	var counter int64
+	err := queryRowContext(ctx, dbs.db, "select totp_last_counter from users where id=$1;", userID).Scan(&counter)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrUserNotFound
+	}
+	if err != nil {
+		return 0, apperrors.Internal(err)
+	}
+	return uint64(counter), nil
+}
+
+// UseTOTPCounter stores the time step of an accepted TOTP code, ErrNotFound is returned when it is not above the last one.
+// The check and the update are one statement, so a code sent twice at once is accepted once.
+func (dbs *DBStorage) UseTOTPCounter(ctx context.Context, userID uint32, counter uint64) error {
+	res, err := execContext(ctx, dbs.db, "update users set totp_last_counter=$2 where id=$1 and totp_last_counter<$2;", userID, int64(counter))
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
 // AddData adds new data to the storage.
-func (dbs *DBStorage) AddData(data datamodels.Data) error {
-	query := `insert into keeper (data_id,user_id, data_info,meta_info, changed_at) values ($1, $2,$3,$4,$5) ON CONFLICT (user_id, data_id) DO UPDATE SET data_info=EXCLUDED.data_info, meta_info=EXCLUDED.meta_info, changed_at=EXCLUDED.changed_at where keeper.changed_at < $5;`
-	data.Data = utils.Encrypt(data.Data, dbSecret)
-	data.Metadata = utils.Encrypt(data.Metadata, dbSecret)
-	_, err := dbs.db.Exec(query, data.DataID, data.UserID, data.Data, data.Metadata, data.ChangedAt.Format(time.RFC3339))
+func (dbs *DBStorage) AddData(ctx context.Context, data datamodels.Data) error {
+	if data.DataID == "" {
+		return apperrors.Validation("invalid data", apperrors.FieldViolation{Field: "data.data_id", Description: "must not be empty"})
+	}
+	query := `insert into keeper (data_id,user_id, data_info,meta_info, changed_at) values ($1, $2,$3,$4,$5) ON CONFLICT (user_id, data_id) DO UPDATE SET data_info=EXCLUDED.data_info, meta_info=EXCLUDED.meta_info, changed_at=EXCLUDED.changed_at, deleted=false, deleted_at=null, purge_requested=false where keeper.changed_at < $5;`
+	data.Data = encrypt(ctx, data.Data, dbSecret)
+	data.Metadata = encrypt(ctx, data.Metadata, dbSecret)
+	_, err := execContext(ctx, dbs.db, query, data.DataID, data.UserID, data.Data, data.Metadata, data.ChangedAt.Format(time.RFC3339))
 	if err != nil {
-		return ErrInternal
+		return apperrors.Internal(err)
 	}
 	return nil
 }
 
 // GetData retrieves data from the storage based on the data ID and user ID.
-func (dbs *DBStorage) GetData(dataID string, userID uint32) (datamodels.Data, error) {
-	rows := dbs.db.QueryRow("select data_info,meta_info, changed_at from keeper where data_id=$1 and user_id=$2 and deleted=false limit 1;", dataID, userID)
+func (dbs *DBStorage) GetData(ctx context.Context, dataID string, userID uint32) (datamodels.Data, error) {
+	rows := queryRowContext(ctx, dbs.db, "select data_info,meta_info, changed_at, revision from keeper where data_id=$1 and user_id=$2 and deleted=false limit 1;", dataID, userID)
 	var v datamodels.Data
-	err := rows.Scan(&v.Data, &v.Metadata, &v.ChangedAt)
-	v.Data = utils.Decrypt(v.Data, dbSecret)
-	v.Metadata = utils.Decrypt(v.Metadata, dbSecret)
-	if err != nil {
+	err := rows.Scan(&v.Data, &v.Metadata, &v.ChangedAt, &v.Revision)
+	if errors.Is(err, sql.ErrNoRows) {
 		return datamodels.Data{}, ErrNotFound
 	}
+	if err != nil {
+		return datamodels.Data{}, apperrors.Internal(err)
+	}
+	v.Data = decrypt(ctx, v.Data, dbSecret)
+	v.Metadata = decrypt(ctx, v.Metadata, dbSecret)
 	return v, nil
 }
 
 // DelData marks data as deleted in the storage based on the data ID and user ID.
-func (dbs *DBStorage) DelData(dataID string, userID uint32) error {
-	_, err := dbs.db.Exec("UPDATE  keeper set deleted=true where data_id=$1 and user_id=$2;", dataID, userID)
+// The deletion time is taken from the database clock, deleting again keeps it.
+func (dbs *DBStorage) DelData(ctx context.Context, dataID string, userID uint32) error {
+	res, err := execContext(ctx, dbs.db, "UPDATE  keeper set deleted=true, deleted_at=coalesce(deleted_at, now()) where data_id=$1 and user_id=$2;", dataID, userID)
 	if err != nil {
-		return ErrInternal
+		return apperrors.Internal(err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
 	}
 	return nil
 }
 
 // Sync retrieves all data associated with a user from the storage.
-func (dbs *DBStorage) Sync(userID uint32) ([]datamodels.Data, error) {
-	rows, err := dbs.db.Query("SELECT(data_id,data_info,meta_info,deleted,changed_at) from keeper where  user_id=$1;", userID)
+func (dbs *DBStorage) Sync(ctx context.Context, userID uint32) ([]datamodels.Data, error) {
+	rows, err := queryContext(ctx, dbs.db, "SELECT data_id,data_info,meta_info,deleted,changed_at from keeper where  user_id=$1;", userID)
 	if err != nil {
-		return nil, ErrInternal
+		return nil, apperrors.Internal(err)
 	}
+	defer rows.Close()
 	var resp []datamodels.Data
 	var tmp datamodels.Data
 
 	for rows.Next() {
 		err = rows.Scan(&tmp.DataID, &tmp.Data, &tmp.Metadata, &tmp.Deleted, &tmp.ChangedAt)
-		tmp.Data = utils.Decrypt(tmp.Data, dbSecret)
-		tmp.Metadata = utils.Decrypt(tmp.Metadata, dbSecret)
-		if err == nil {
-			resp = append(resp, tmp)
+		if err != nil {
+			return nil, apperrors.Internal(err)
 		}
+		tmp.Data = decrypt(ctx, tmp.Data, dbSecret)
+		tmp.Metadata = decrypt(ctx, tmp.Metadata, dbSecret)
+		resp = append(resp, tmp)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, apperrors.Internal(err)
 	}
 	if resp != nil {
 		return resp, nil
@@ -134,20 +924,88 @@ func (dbs *DBStorage) Sync(userID uint32) ([]datamodels.Data, error) {
 }
 
 // ClientSync synchronizes client data with the server in the storage.
-func (dbs *DBStorage) ClientSync(userID uint32, data []*pb.Data) error {
-	query := `insert into keeper (data_id,user_id, data_info,meta_info, changed_at,deleted) values ($1, $2,$3,$4,$5,$6) ON CONFLICT (user_id, data_id) DO UPDATE SET data_info=EXCLUDED.data_info, meta_info=EXCLUDED.meta_info, changed_at=EXCLUDED.changed_at where keeper.changed_at < $5;`
+// Deletions of records the server does not keep are skipped, so records purged from the trash do not come back,
+// deletion times are taken from the database clock.
+func (dbs *DBStorage) ClientSync(ctx context.Context, userID uint32, data []*pb.Data) error {
+	query := `insert into keeper (data_id,user_id, data_info,meta_info, changed_at,deleted,deleted_at)
+select $1::varchar, $2::int, $3::text, $4::text, $5::timestamptz, $6::bool, case when $6::bool then now() end
+where not $6::bool or exists (select 1 from keeper where user_id=$2::int and data_id=$1::varchar)
+ON CONFLICT (user_id, data_id) DO UPDATE SET data_info=EXCLUDED.data_info, meta_info=EXCLUDED.meta_info, changed_at=EXCLUDED.changed_at, deleted=EXCLUDED.deleted,
+    deleted_at=case when EXCLUDED.deleted then coalesce(keeper.deleted_at, now()) end, purge_requested=keeper.purge_requested and EXCLUDED.deleted where keeper.changed_at < $5;`
+	var violations []apperrors.FieldViolation
 	for i := range data {
-
-		fmt.Println(i, data[i].Data)
-		data[i].Data = utils.Encrypt(data[i].Data, dbSecret)
-		fmt.Println(i, data[i].Data)
-		fmt.Println(i, data[i].MetaInfo)
-		data[i].MetaInfo = utils.Encrypt(data[i].MetaInfo, dbSecret)
-		fmt.Println(i, data[i].MetaInfo)
-		_, err := dbs.db.Exec(query, data[i].DataId, userID, data[i].Data, data[i].MetaInfo, data[i].ChangedAt.AsTime().Format(time.RFC3339), data[i].Deleted)
+		if data[i].DataId == "" {
+			violations = append(violations, apperrors.FieldViolation{Field: fmt.Sprintf("data[%d].data_id", i), Description: "must not be empty"})
+		}
+	}
+	if violations != nil {
+		return apperrors.Validation("invalid data", violations...)
+	}
+	tx, err := dbs.db.BeginTx(ctx, nil)
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+	defer tx.Rollback()
+	for i := range data {
+		data[i].Data = encrypt(ctx, data[i].Data, dbSecret)
+		data[i].MetaInfo = encrypt(ctx, data[i].MetaInfo, dbSecret)
+		_, err = execContext(ctx, tx, query, data[i].DataId, userID, data[i].Data, data[i].MetaInfo, data[i].ChangedAt.AsTime().Format(time.RFC3339), data[i].Deleted)
 		if err != nil {
-			return ErrInternal
+			return apperrors.Internal(err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return apperrors.Internal(err)
+	}
+	return nil
+}
+
+// PasswordPolicies returns the password policies of the folders of the vault, the empty folder holds the vault default.
+func (dbs *DBStorage) PasswordPolicies(ctx context.Context, userID uint32) (map[string]passgen.Policy, error) {
+	rows, err := queryContext(ctx, dbs.db, "select folder, password_policy from vault_settings where user_id=$1;", userID)
+	if err != nil {
+		return nil, apperrors.Internal(err)
+	}
+	defer rows.Close()
+	policies := map[string]passgen.Policy{}
+	for rows.Next() {
+		var folder string
+		var raw []byte
+		if err = rows.Scan(&folder, &raw); err != nil {
+			return nil, apperrors.Internal(err)
+		}
+		var p passgen.Policy
+		if err = json.Unmarshal(raw, &p); err != nil {
+			return nil, apperrors.Internal(err)
 		}
+		policies[decrypt(ctx, folder, dbSecret)] = p
+	}
+	if err = rows.Err(); err != nil {
+		return nil, apperrors.Internal(err)
+	}
+	return policies, nil
+}
+
+// SetPasswordPolicy sets the password policy of the folder, a nil policy removes it.
+// Policies that can not produce a password are rejected.
+func (dbs *DBStorage) SetPasswordPolicy(ctx context.Context, userID uint32, folder string, policy *passgen.Policy) error {
+	if policy == nil {
+		if _, err := execContext(ctx, dbs.db, "delete from vault_settings where user_id=$1 and folder_hash=$2;", userID, folderHash(folder)); err != nil {
+			return apperrors.Internal(err)
+		}
+		return nil
+	}
+	if err := policy.Validate(); err != nil {
+		return apperrors.Validation("invalid password policy", apperrors.FieldViolation{Field: "policy", Description: err.Error()})
+	}
+	raw, err := json.Marshal(policy)
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+	query := `insert into vault_settings (user_id, folder_hash, folder, password_policy) values ($1, $2, $3, $4)
+on conflict (user_id, folder_hash) do update set password_policy=EXCLUDED.password_policy;`
+	if _, err = execContext(ctx, dbs.db, query, userID, folderHash(folder), encrypt(ctx, folder, dbSecret), string(raw)); err != nil {
+		return apperrors.Internal(err)
 	}
 	return nil
 }
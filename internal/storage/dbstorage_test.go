@@ -1 +1,23 @@
 package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFolderHash(t *testing.T) {
+	hash := folderHash("work/servers")
+	assert.Len(t, hash, 64)
+	assert.Equal(t, hash, folderHash("work/servers"), "the same folder gets the same settings")
+	assert.NotEqual(t, hash, folderHash("work"))
+	assert.NotEqual(t, hash, folderHash(""))
+	assert.NotContains(t, hash, "work")
+
+	ctx := context.Background()
+	encrypted := encrypt(ctx, "work/servers", dbSecret)
+	assert.False(t, strings.Contains(encrypted, "work"), "the folder is not stored in plaintext")
+	assert.Equal(t, "work/servers", decrypt(ctx, encrypted, dbSecret))
+}
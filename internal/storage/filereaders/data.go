@@ -3,6 +3,7 @@ package filereaders
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
 	"errors"
 	"os"
@@ -10,9 +11,13 @@ import (
 	"gophkeeper/internal/datamodels"
 )
 
-// ReadData reads data from a JSON file and returns a map of datamodels.UniqueData to datamodels.Data.
+// ReadData reads data.json of Dir and returns a map of datamodels.UniqueData to datamodels.Data.
 func ReadData() (map[datamodels.UniqueData]datamodels.Data, error) {
-	file, err := os.OpenFile("data.json", os.O_RDONLY|os.O_CREATE, 0777)
+	name, err := filePath("data.json")
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(name, os.O_RDONLY|os.O_CREATE, 0600)
 	if err != nil {
 		return nil, errors.New("failed to open file")
 	}
@@ -20,18 +25,23 @@ func ReadData() (map[datamodels.UniqueData]datamodels.Data, error) {
 
 	store := make(map[datamodels.UniqueData]datamodels.Data)
 	var data []datamodels.Data
-	var tmp datamodels.Data
 
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		// omitted fields of the line are zero
+		var tmp datamodels.Data
 		err = json.Unmarshal(scanner.Bytes(), &tmp)
 		if err != nil {
-			if err.Error() != "EOF" {
-				return nil, errors.New("failed to decode data")
-			}
+			return nil, errors.New("failed to decode data")
 		}
 		data = append(data, tmp)
 	}
+	if err = scanner.Err(); err != nil {
+		return nil, errors.New("failed to read file")
+	}
 
 	for _, v := range data {
 		store[datamodels.UniqueData{DataID: v.DataID, UserID: v.UserID}] = datamodels.Data{
@@ -47,9 +57,13 @@ func ReadData() (map[datamodels.UniqueData]datamodels.Data, error) {
 	return store, nil
 }
 
-// WriteData writes the provided data to a JSON file.
+// WriteData appends the provided data to data.json of Dir.
 func WriteData(data datamodels.Data) error {
-	file, err := os.OpenFile("data.json", os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0777)
+	name, err := filePath("data.json")
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
 	if err != nil {
 		return errors.New("failed to open file")
 	}
@@ -63,3 +77,48 @@ func WriteData(data datamodels.Data) error {
 
 	return nil
 }
+
+// RemoveUserData removes every record of the user from data.json of Dir.
+func RemoveUserData(userID uint32) error {
+	return rewrite("data.json", func(line []byte) (bool, error) {
+		var tmp datamodels.Data
+		if err := json.Unmarshal(line, &tmp); err != nil {
+			return false, errors.New("failed to decode data")
+		}
+		return tmp.UserID != userID, nil
+	})
+}
+
+// RemoveData removes every line of the records of the user from data.json of Dir.
+func RemoveData(userID uint32, dataIDs ...string) error {
+	remove := make(map[string]bool, len(dataIDs))
+	for _, id := range dataIDs {
+		remove[id] = true
+	}
+	return rewrite("data.json", func(line []byte) (bool, error) {
+		var tmp datamodels.Data
+		if err := json.Unmarshal(line, &tmp); err != nil {
+			return false, errors.New("failed to decode data")
+		}
+		return tmp.UserID != userID || !remove[tmp.DataID], nil
+	})
+}
+
+// ReplaceUserData replaces every record of the user in data.json of Dir with data in one atomic rewrite.
+func ReplaceUserData(userID uint32, data []datamodels.Data) error {
+	add := make([][]byte, 0, len(data))
+	for _, v := range data {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return errors.New("failed to encode data")
+		}
+		add = append(add, b)
+	}
+	return rewrite("data.json", func(line []byte) (bool, error) {
+		var tmp datamodels.Data
+		if err := json.Unmarshal(line, &tmp); err != nil {
+			return false, errors.New("failed to decode data")
+		}
+		return tmp.UserID != userID, nil
+	}, add...)
+}
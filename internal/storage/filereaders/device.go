@@ -0,0 +1,35 @@
+package filereaders
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strings"
+)
+
+// DeviceID returns the id of this client installation stored in device.id of Dir, the id is generated on the first call.
+// The server uses it to know which devices received the deletions of records.
+func DeviceID() (string, error) {
+	name, err := filePath("device.id")
+	if err != nil {
+		return "", err
+	}
+	b, err := os.ReadFile(name)
+	if err == nil {
+		if id := strings.TrimSpace(string(b)); id != "" {
+			return id, nil
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", errors.New("failed to read file")
+	}
+	raw := make([]byte, 16)
+	if _, err = rand.Read(raw); err != nil {
+		return "", errors.New("failed to generate device id")
+	}
+	id := hex.EncodeToString(raw)
+	if err = os.WriteFile(name, []byte(id+"\n"), 0600); err != nil {
+		return "", errors.New("failed to write file")
+	}
+	return id, nil
+}
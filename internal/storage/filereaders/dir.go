@@ -0,0 +1,43 @@
+package filereaders
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// appDir - directory of the client files in the user configuration directory
+const appDir = "gophkeeper"
+
+// Dir returns the directory of the client files, gophkeeper in the user configuration directory
+// ($XDG_CONFIG_HOME or ~/.config on Linux), so commands run from any working directory share them.
+// The directory is kept readable by the owner only, the socket of the session agent relies on it.
+func Dir() (string, error) {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", errors.New("failed to find config directory")
+	}
+	dir := filepath.Join(base, appDir)
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return "", errors.New("failed to create config directory")
+	}
+	// MkdirAll keeps the permissions of an existing directory
+	if err = os.Chmod(dir, 0700); err != nil {
+		return "", errors.New("failed to create config directory")
+	}
+	return dir, nil
+}
+
+// filePath - path of the client file in Dir
+func filePath(name string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// SessionSocket returns the path of the socket of the session agent in Dir, only the owner can reach it.
+func SessionSocket() (string, error) {
+	return filePath("session.sock")
+}
@@ -0,0 +1,48 @@
+package filereaders
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gophkeeper/internal/datamodels"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDir(t *testing.T) {
+	config := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", config)
+	t.Setenv("HOME", config)
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(t.TempDir()))
+	defer os.Chdir(wd)
+
+	require.NoError(t, WriteData(datamodels.Data{UserID: 1, DataID: "a"}))
+	id, err := DeviceID()
+	require.NoError(t, err)
+
+	dir, err := Dir()
+	require.NoError(t, err)
+	info, err := os.Stat(dir)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), info.Mode().Perm(), "the directory is readable by the owner only")
+	for _, name := range []string{"data.json", "device.id"} {
+		info, err = os.Stat(filepath.Join(dir, name))
+		require.NoError(t, err, name)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), name)
+	}
+	entries, err := os.ReadDir(".")
+	require.NoError(t, err)
+	assert.Empty(t, entries, "nothing is written to the working directory")
+
+	socket, err := SessionSocket()
+	require.NoError(t, err)
+	assert.Equal(t, dir, filepath.Dir(socket), "the socket is reachable by the owner only")
+
+	again, err := DeviceID()
+	require.NoError(t, err)
+	assert.Equal(t, id, again)
+}
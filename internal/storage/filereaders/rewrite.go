@@ -0,0 +1,66 @@
+package filereaders
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// rewrite - keeps the lines of the file of Dir accepted by keep and appends add
+// The new content is written to a temporary file that replaces the file, so a crash leaves either the old or the new file.
+func rewrite(base string, keep func(line []byte) (bool, error), add ...[]byte) error {
+	name, err := filePath(base)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(name, os.O_RDONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return errors.New("failed to open file")
+	}
+	defer file.Close()
+	var out bytes.Buffer
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		ok, err := keep(line)
+		if err != nil {
+			return err
+		}
+		if ok {
+			out.Write(line)
+			out.WriteByte('\n')
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return errors.New("failed to read file")
+	}
+	for _, line := range add {
+		out.Write(line)
+		out.WriteByte('\n')
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return errors.New("failed to create file")
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(out.Bytes()); err != nil {
+		tmp.Close()
+		return errors.New("failed to write file")
+	}
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return errors.New("failed to write file")
+	}
+	if err = tmp.Close(); err != nil {
+		return errors.New("failed to write file")
+	}
+	if err = os.Rename(tmp.Name(), name); err != nil {
+		return errors.New("failed to replace file")
+	}
+	return nil
+}
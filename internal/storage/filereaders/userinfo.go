@@ -3,6 +3,7 @@ package filereaders
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
 	"errors"
 	"gophkeeper/internal/datamodels"
@@ -11,43 +12,65 @@ import (
 	"os"
 )
 
-// ReadUsers reads data from a JSON file and returns sessionstorage.UserSession.
+// ReadUsers reads users.json of Dir and returns sessionstorage.UserSession.
 func ReadUsers() (sessionstorage.UserSession, error) {
-	file, err := os.OpenFile("users.json", os.O_RDONLY|os.O_CREATE, 0777)
+	name, err := filePath("users.json")
+	if err != nil {
+		return sessionstorage.UserSession{}, err
+	}
+	file, err := os.OpenFile(name, os.O_RDONLY|os.O_CREATE, 0600)
 	if err != nil {
 		return sessionstorage.UserSession{}, errors.New("failed to open file")
 	}
 	defer file.Close()
 	user := sessionstorage.Init()
 	var data []datamodels.Auth
-	var tmp datamodels.Auth
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		// a new value for every line, fields omitted from a line must not keep the values of the previous one
+		var tmp datamodels.Auth
 		err = json.Unmarshal(scanner.Bytes(), &tmp)
 		if err != nil {
-			if err.Error() != "EOF" {
-				return sessionstorage.UserSession{}, errors.New("failed to decode data")
-			}
+			return sessionstorage.UserSession{}, errors.New("failed to decode data")
 		}
 		data = append(data, tmp)
 	}
+	if err = scanner.Err(); err != nil {
+		return sessionstorage.UserSession{}, errors.New("failed to read file")
+	}
+	// the file is append only, the last record of a login is the current one
 	for _, v := range data {
-		user.AddUser(v.Login, v.Password, v.ID)
+		user.PutUser(v.Login, datamodels.Login{ID: v.ID, Password: v.Password, VaultKey: v.VaultKey, PendingKey: v.PendingKey})
 	}
 	return user, nil
 }
 
-// WriteUser writes the provided data to a JSON file.
-func WriteUser(auth datamodels.Auth) error {
-	file, err := os.OpenFile("users.json", os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0777)
-	if err != nil {
-		return errors.New("failed to open file")
-	}
-	defer file.Close()
-	encoder := json.NewEncoder(file)
-	err = encoder.Encode(auth)
+// RemoveUser removes the cached credentials of the login from users.json of Dir.
+func RemoveUser(login string) error {
+	return rewrite("users.json", func(line []byte) (bool, error) {
+		var tmp datamodels.Auth
+		if err := json.Unmarshal(line, &tmp); err != nil {
+			return false, errors.New("failed to decode data")
+		}
+		return tmp.Login != login, nil
+	})
+}
+
+// ReplaceUser replaces the cached credentials of the login in users.json of Dir with auth.
+// Former records of the login, with their password hashes, are removed in the same atomic rewrite.
+func ReplaceUser(auth datamodels.Auth) error {
+	b, err := json.Marshal(auth)
 	if err != nil {
 		return errors.New("failed to encode data")
 	}
-	return nil
+	return rewrite("users.json", func(line []byte) (bool, error) {
+		var tmp datamodels.Auth
+		if err := json.Unmarshal(line, &tmp); err != nil {
+			return false, errors.New("failed to decode data")
+		}
+		return tmp.Login != auth.Login, nil
+	}, b)
 }
@@ -4,20 +4,28 @@ package storage
 import (
 	"context"
 	"errors"
+	"io"
 	"log"
+	"sort"
 	"time"
 
+	"gophkeeper/internal/apperrors"
+	"gophkeeper/internal/config"
 	"gophkeeper/internal/datamodels"
+	"gophkeeper/internal/passgen"
+	"gophkeeper/internal/sessionagent"
 	"gophkeeper/internal/sessionstorage"
 	files "gophkeeper/internal/storage/filereaders"
 	"gophkeeper/internal/utils"
 	pb "gophkeeper/proto"
 
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/metadata"
-	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -25,54 +33,192 @@ import (
 var Client pb.GophkeeperClient
 
 // clientSecret - secret key for cipher
+// The local records of users cached before vault keys are encrypted with it until their next login.
 var clientSecret = []byte("qpwoeritkvndgahz")
 
 // Module errors
 var (
-	ErrNotFound      = errors.New("not found")
-	ErrWrongPassword = errors.New("invalid password")
-	ErrInternal      = errors.New("server error")
-	ErrDuplicate     = errors.New("login already exists")
+	ErrNotFound        = apperrors.NotFound("DATA_NOT_FOUND", "not found")
+	ErrUserNotFound    = apperrors.NotFound("USER_NOT_FOUND", "user not found")
+	ErrWrongPassword   = apperrors.Unauthenticated("WRONG_PASSWORD", "invalid password")
+	ErrInternal        = apperrors.ErrInternal
+	ErrDuplicate       = apperrors.Conflict("LOGIN_EXISTS", "login already exists")
+	ErrDisabled        = apperrors.PermissionDenied("ACCOUNT_DISABLED", "account is disabled")
+	ErrVersionNotFound = apperrors.NotFound("VERSION_NOT_FOUND", "version not found")
+	ErrRevision        = apperrors.Conflict("REVISION_MISMATCH", "record was changed since it was read")
+	ErrDataExists      = apperrors.Conflict("DATA_EXISTS", "record with the data id already exists")
+	ErrLocked          = apperrors.Unauthenticated("VAULT_LOCKED", "vault is locked")
 )
 
 // Storage an interface that defines the following methods:
 type Storage interface {
 	//Auth - adds new user
-	Auth(login string, password string) error
+	Auth(ctx context.Context, login string, password string) error
 	// Login verifies the login credentials.
-	Login(login string, password string) (uint32, error)
+	Login(ctx context.Context, login string, password string) (uint32, error)
 	// AddData adds data to the storage.
-	AddData(data datamodels.Data) error
+	AddData(ctx context.Context, data datamodels.Data) error
 	// GetData retrieves data from the storage.
-	GetData(dataID string, userID uint32) (datamodels.Data, error)
+	GetData(ctx context.Context, dataID string, userID uint32) (datamodels.Data, error)
 	// DelData deletes data from the storage.
-	DelData(dataID string, userID uint32) error
+	DelData(ctx context.Context, dataID string, userID uint32) error
 	// Sync synchronizes data from server for a specific user.
-	Sync(userId uint32) ([]datamodels.Data, error)
+	Sync(ctx context.Context, userId uint32) ([]datamodels.Data, error)
 	//ClientSync - synchronize client data with server
-	ClientSync(userID uint32, data []*pb.Data) error
+	ClientSync(ctx context.Context, userID uint32, data []*pb.Data) error
 }
 
+// ClientStorage - Storage with the account operations of the client
+type ClientStorage interface {
+	Storage
+	// EnrollTOTP returns a new TOTP secret of the logged in user.
+	EnrollTOTP(ctx context.Context) (string, error)
+	// ConfirmTOTP enables two-factor authentication and returns the recovery codes.
+	ConfirmTOTP(ctx context.Context, code string) ([]string, error)
+	// DisableTOTP turns two-factor authentication off.
+	DisableTOTP(ctx context.Context, code string) error
+	// RegenerateRecoveryCodes replaces the recovery codes.
+	RegenerateRecoveryCodes(ctx context.Context, code string) ([]string, error)
+	// ChangePassword replaces the password of the logged in user.
+	ChangePassword(ctx context.Context, login string, oldPassword string, newPassword string) error
+	// DeleteAccount removes the account on the server and its local records and credentials.
+	DeleteAccount(ctx context.Context, login string, password string, otpCode string) error
+	// ExportAccount calls write for every record of the logged in user kept by the server, including deleted ones.
+	ExportAccount(ctx context.Context, write func(datamodels.Data) error) error
+	// ListVersions returns the revisions of the record kept by the server, newest first.
+	ListVersions(ctx context.Context, dataID string) ([]datamodels.Version, error)
+	// GetVersion returns the content of a revision of the record.
+	GetVersion(ctx context.Context, dataID string, revision uint32) (datamodels.Data, error)
+	// SetHistoryRetention sets how many older revisions of every record are kept.
+	SetHistoryRetention(ctx context.Context, versions uint32) error
+	// Unlock returns the session of the logged in user kept for ttl, the session agent serving it lets commands
+	// without credentials Resume it.
+	Unlock(ctx context.Context, login string, userID uint32, ttl time.Duration) (datamodels.Session, error)
+	// Resume continues the unlocked session and returns the user id, ErrLocked is returned when there is none.
+	Resume(ctx context.Context) (uint32, error)
+	// Lock ends the unlocked session.
+	Lock(ctx context.Context) error
+	// ListData returns the decrypted records of the user kept in the local vault, sorted by data id.
+	ListData(ctx context.Context, userID uint32) ([]datamodels.Data, error)
+	// UpdateData changes the fields of the record listed in mask (MaskDataID, MaskData, MaskMetaInfo)
+	// on the server and in the local storage. A revision other than 0 must be the current revision of the record.
+	UpdateData(ctx context.Context, dataID string, data datamodels.Data, mask []string, revision uint32) (datamodels.Data, error)
+	// ListTrash returns the deleted records kept by the server.
+	ListTrash(ctx context.Context) ([]datamodels.Data, error)
+	// RestoreData takes the record of the user out of the trash.
+	RestoreData(ctx context.Context, dataID string, userID uint32) error
+	// EmptyTrash removes the deleted records, it returns the number of records removed at once
+	// and of records removed after every device of the user synchronizes.
+	EmptyTrash(ctx context.Context) (int64, int64, error)
+	// ListDevices returns the devices of the logged in user known to the server, latest synchronized first.
+	ListDevices(ctx context.Context) ([]datamodels.Device, error)
+	// RemoveDevice makes the server stop waiting for the device to synchronize deleted records.
+	RemoveDevice(ctx context.Context, deviceID string) error
+	// PasswordPolicies returns the password policies of the folders of the vault, the empty folder holds the vault default.
+	PasswordPolicies(ctx context.Context) (map[string]passgen.Policy, error)
+	// SetPasswordPolicy sets the password policy of the folder, a nil policy removes it.
+	SetPasswordPolicy(ctx context.Context, folder string, policy *passgen.Policy) error
+}
+
+// SecondFactor returns the one-time code when the server asks for it, Login fails with the server error when nil.
+var SecondFactor func(ctx context.Context) (string, error)
+
 // Users represents user sessions.
 var Users sessionstorage.UserSession
 var md metadata.MD
 
-// Init initializes the storage package by establishing a gRPC connection.
-func Init() {
-	conn, err := grpc.Dial(":3200", grpc.WithTransportCredentials(insecure.NewCredentials()))
+// Init initializes the storage package by establishing a gRPC connection to the profile address.
+func Init(profile config.Profile) {
+	conn, err := grpc.Dial(profile.Address,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(
+			otelgrpc.UnaryClientInterceptor(),
+			deadlineInterceptor(time.Duration(profile.RequestTimeout)),
+		),
+		grpc.WithChainStreamInterceptor(
+			otelgrpc.StreamClientInterceptor(),
+			streamDeadlineInterceptor(time.Duration(profile.RequestTimeout)),
+		))
 	if err != nil {
 		log.Fatal(err)
 	}
 	Client = pb.NewGophkeeperClient(conn)
+	health = healthpb.NewHealthClient(conn)
+	current = profile
+}
+
+// health - health client of the profile server
+var health healthpb.HealthClient
+
+// current - profile of the connection
+var current config.Profile
+
+// CurrentProfile returns the profile the storage is connected with.
+func CurrentProfile() config.Profile {
+	return current
+}
+
+// ServerStatus returns the health of the profile server: SERVING, NOT_SERVING or UNAVAILABLE when it can not be reached.
+func ServerStatus(ctx context.Context) string {
+	resp, err := health.Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		return "UNAVAILABLE"
+	}
+	return resp.Status.String()
+}
+
+// deadlineInterceptor - sets the per-RPC deadline unless the caller already set a shorter one
+func deadlineInterceptor(timeout time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// deadlineStream - grpc.ClientStream that releases the context of its deadline once the stream ends
+type deadlineStream struct {
+	grpc.ClientStream
+	cancel context.CancelFunc
+}
+
+// RecvMsg receives the next message, the context is released on the end of the stream or an error.
+func (s *deadlineStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil {
+		s.cancel()
+	}
+	return err
+}
+
+// streamDeadlineInterceptor - sets the deadline of the whole stream like deadlineInterceptor does for unary calls
+func streamDeadlineInterceptor(timeout time.Duration) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if timeout <= 0 {
+			return streamer(ctx, desc, cc, method, opts...)
+		}
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		return &deadlineStream{ClientStream: stream, cancel: cancel}, nil
+	}
 }
 
 // MemoryStorage a struct that implements the Storage interface and stores data in the computer's memory.
 type MemoryStorage struct {
 	localMem map[datamodels.UniqueData]datamodels.Data
+	// key - vault key of the logged in user the local records are encrypted with
+	key []byte
 }
 
 // NewMemoryStorage creates a new MemoryStorage instance.
-func NewMemoryStorage() Storage {
+func NewMemoryStorage() ClientStorage {
 	Users = sessionstorage.Init()
 	var err error
 	Users, err = files.ReadUsers()
@@ -83,79 +229,208 @@ func NewMemoryStorage() Storage {
 	if err != nil {
 		log.Fatalf("error reading data: %v", err)
 	}
-	return &MemoryStorage{localMem: localMem}
+	// the key is replaced by the vault key of the user on login
+	return &MemoryStorage{localMem: localMem, key: clientSecret}
 }
 
 // Auth adds a new user.
 // If the user already exists, it returns an error.
-func (ms *MemoryStorage) Auth(login string, password string) error {
+func (ms *MemoryStorage) Auth(ctx context.Context, login string, password string) error {
 	var header metadata.MD
-	ctx := metadata.NewOutgoingContext(context.Background(), md)
-	_, err := Client.Auth(context.Background(), &pb.AuthLoginRequest{Login: login, Password: password}, grpc.Header(&header))
+	_, err := Client.Auth(ctx, &pb.AuthLoginRequest{Login: login, Password: password}, grpc.Header(&header))
 	md = header
-	st := status.Convert(err)
-	if st.Err() == nil {
+	if err == nil {
 
-		ctx = metadata.NewOutgoingContext(context.Background(), md)
-		id, errClient := Client.Login(ctx, &pb.AuthLoginRequest{Login: login, Password: password}, grpc.Header(&header))
+		id, errClient := Client.Login(metadata.NewOutgoingContext(ctx, md), &pb.AuthLoginRequest{Login: login, Password: password}, grpc.Header(&header))
 		md = header
 
-		st = status.Convert(errClient)
-		if st.Err() != nil {
-			return st.Err()
+		if errClient != nil {
+			return apperrors.FromStatus(errClient)
 		}
-		passHash := utils.GetMD5Hash(password)
-		err = Users.AddUser(login, passHash, id.Id)
-		if err != nil {
-			return errors.New("user already exists")
+		if _, ok := Users.GetUser(login); ok {
+			return ErrDuplicate
+		}
+		key, kErr := newVaultKey()
+		if kErr != nil {
+			return kErr
 		}
-		wErr := files.WriteUser(datamodels.Auth{ID: id.Id, Login: login, Password: passHash})
-		if wErr != nil {
-			return errors.New("error writing to user file")
+		if kErr = cacheUser(login, password, id.Id, key); kErr != nil {
+			return kErr
 		}
+		ms.key = key
 		return nil
 	}
-	return st.Err()
+	return apperrors.FromStatus(err)
 }
 
 // Login verifies the login credentials.
-func (ms *MemoryStorage) Login(login string, password string) (uint32, error) {
+// The cached credentials are checked only when the server can not be reached, ErrUnavailable is returned then
+// for users not cached on this device.
+func (ms *MemoryStorage) Login(ctx context.Context, login string, password string) (uint32, error) {
 	var header metadata.MD
-	ctx := metadata.NewOutgoingContext(context.Background(), md)
-	id, err := Client.Login(ctx, &pb.AuthLoginRequest{Login: login, Password: password}, grpc.Header(&header))
+	req := &pb.AuthLoginRequest{Login: login, Password: password}
+	id, err := Client.Login(metadata.NewOutgoingContext(ctx, md), req, grpc.Header(&header))
+	if apperrors.Reason(apperrors.FromStatus(err)) == "OTP_REQUIRED" && SecondFactor != nil {
+		req.OtpCode, err = SecondFactor(ctx)
+		if err != nil {
+			return 0, err
+		}
+		id, err = Client.Login(metadata.NewOutgoingContext(ctx, md), req, grpc.Header(&header))
+	}
 	md = header
+	appErr := apperrors.FromStatus(err)
+	// the cached credentials are used only when the server can not be reached, its answers are final
+	if err != nil && !errors.Is(appErr, apperrors.ErrUnavailable) {
+		return 0, appErr
+	}
 	if err == nil {
-		_, ok := Users.GetUser(login)
-		if !ok {
-			Users.AddUser(login, utils.GetMD5Hash(password), id.Id)
-			wErr := files.WriteUser(datamodels.Auth{ID: id.Id, Login: login, Password: utils.GetMD5Hash(password)})
-			if wErr != nil {
-				return 0, errors.New("error writing to user file")
-			}
+		key, kErr := ms.onlineKey(login, password, id.Id)
+		if kErr != nil {
+			return 0, kErr
 		}
+		ms.key = key
 		return id.Id, nil
 	}
 	user, ok := Users.GetUser(login)
 	if !ok {
-		return 0, errors.New("user not found")
+		return 0, appErr
+	}
+	var key []byte
+	if user.VaultKey == nil {
+		if user.Password != utils.GetMD5Hash(password) {
+			return 0, ErrWrongPassword
+		}
+		key, err = ms.upgradeKey(login, password, user)
+	} else {
+		key, err = userKey(user, password)
 	}
-	passHash := utils.GetMD5Hash(password)
-	if user.Password != passHash {
-		return 0, errors.New("wrong password")
+	if err != nil {
+		return 0, err
 	}
+	ms.key = key
 	return user.ID, nil
 }
 
+// onlineKey - vault key of the user the server accepted, the cached credentials are updated when they are outdated
+// The cached hash is outdated after a password change made from another device or interrupted before users.json
+// was written, the key wrapped with the new password of an interrupted change is unwrapped then.
+// When the key is wrapped with a password changed on another device it cannot be unwrapped anymore, the local
+// records of the user are dropped then and read from the server by the next sync.
+func (ms *MemoryStorage) onlineKey(login string, password string, id uint32) ([]byte, error) {
+	user, ok := Users.GetUser(login)
+	var key []byte
+	var err error = ErrUserNotFound
+	if ok && user.ID == id && user.VaultKey == nil {
+		return ms.upgradeKey(login, password, user)
+	}
+	if ok && user.ID == id {
+		key, err = userKey(user, password)
+	}
+	if err == nil && user.VaultKey != nil && user.PendingKey == nil && user.Password == utils.GetMD5Hash(password) {
+		return key, nil
+	}
+	if err != nil {
+		if key, err = newVaultKey(); err != nil {
+			return nil, err
+		}
+		if err = ms.dropUserData(id); err != nil {
+			return nil, err
+		}
+	}
+	if err = cacheUser(login, password, id, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// dropUserData - removes the local records of the user
+func (ms *MemoryStorage) dropUserData(userID uint32) error {
+	if err := files.RemoveUserData(userID); err != nil {
+		return err
+	}
+	for k := range ms.localMem {
+		if k.UserID == userID {
+			delete(ms.localMem, k)
+		}
+	}
+	return nil
+}
+
+// upgradeKey - moves the local records of a user cached before vault keys from the client key to a new vault key
+// The new key is saved as pending before the records are rewritten, so an interrupted upgrade is continued
+// with the same key by the next login. Records already encrypted with the new key are kept as they are.
+func (ms *MemoryStorage) upgradeKey(login string, password string, user datamodels.Login) ([]byte, error) {
+	key, err := unwrapKey(user.PendingKey, password)
+	if err != nil {
+		if key, err = newVaultKey(); err != nil {
+			return nil, err
+		}
+		if user.PendingKey, err = wrapKey(key, password); err != nil {
+			return nil, err
+		}
+		if err = putUser(login, user); err != nil {
+			return nil, err
+		}
+	}
+	var records []datamodels.Data
+	for k, v := range ms.localMem {
+		if k.UserID != user.ID {
+			continue
+		}
+		if data, dErr := utils.Open(v.Data, clientSecret); dErr == nil {
+			v.Data = utils.Encrypt(data, key)
+		}
+		if meta, dErr := utils.Open(v.Metadata, clientSecret); dErr == nil {
+			v.Metadata = utils.Encrypt(meta, key)
+		}
+		records = append(records, v)
+	}
+	if err = files.ReplaceUserData(user.ID, records); err != nil {
+		return nil, err
+	}
+	for _, v := range records {
+		ms.localMem[datamodels.UniqueData{DataID: v.DataID, UserID: v.UserID}] = v
+	}
+	if err = cacheUser(login, password, user.ID, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// userKey - the vault key of the cached user unwrapped with the password, ErrWrongPassword is returned for another password
+// The key wrapped with the new password of an interrupted password change is used when the password is the new one.
+func userKey(user datamodels.Login, password string) ([]byte, error) {
+	key, err := unwrapKey(user.VaultKey, password)
+	if errors.Is(err, ErrWrongPassword) && user.PendingKey != nil {
+		return unwrapKey(user.PendingKey, password)
+	}
+	return key, err
+}
+
 // AddData adds data to the storage.
-func (ms *MemoryStorage) AddData(data datamodels.Data) error {
-	ctx := metadata.NewOutgoingContext(context.Background(), md)
-	Client.AddData(ctx, &pb.AddDataRequest{Data: &pb.Data{DataId: data.DataID, Data: data.Data, MetaInfo: data.Metadata}})
+// Metadata of an existing record is kept when data has none, ChangedAt is set to now when data has none.
+// A record the server rejects is not stored, without the server it is stored locally and uploaded by the next sync.
+func (ms *MemoryStorage) AddData(ctx context.Context, data datamodels.Data) error {
+	key := datamodels.UniqueData{DataID: data.DataID, UserID: data.UserID}
+	if old, ok := ms.localMem[key]; ok && data.Metadata == "" && !old.Deleted {
+		data.Metadata = decrypt(ctx, old.Metadata, ms.key)
+	}
+	if data.ChangedAt.IsZero() {
+		data.ChangedAt = time.Now()
+	}
+	ctx = metadata.NewOutgoingContext(ctx, md)
+	if _, err := Client.AddData(ctx, &pb.AddDataRequest{Data: &pb.Data{DataId: data.DataID, Data: data.Data, MetaInfo: data.Metadata}}); err != nil {
+		if appErr := apperrors.FromStatus(err); !errors.Is(appErr, apperrors.ErrUnavailable) {
+			return appErr
+		}
+	}
 
-	data.Data = utils.Encrypt(data.Data, clientSecret)
-	data.Metadata = utils.Encrypt(data.Metadata, clientSecret)
+	data.Data = encrypt(ctx, data.Data, ms.key)
+	data.Metadata = encrypt(ctx, data.Metadata, ms.key)
 
-	ms.localMem[datamodels.UniqueData{DataID: data.DataID, UserID: data.UserID}] = datamodels.Data{UserID: data.UserID, Data: data.Data, Metadata: data.Metadata, Deleted: false, ChangedAt: time.Now()}
-	err := files.WriteData(datamodels.Data{UserID: data.UserID, DataID: data.DataID, Data: data.Data, Metadata: data.Metadata, Deleted: false, ChangedAt: time.Now()})
+	stored := datamodels.Data{UserID: data.UserID, DataID: data.DataID, Data: data.Data, Metadata: data.Metadata, Deleted: false, ChangedAt: data.ChangedAt}
+	ms.localMem[key] = stored
+	err := files.WriteData(stored)
 	if err != nil {
 		return errors.New("err writing data to file")
 	}
@@ -163,99 +438,479 @@ func (ms *MemoryStorage) AddData(data datamodels.Data) error {
 }
 
 // DelData deletes data from the storage.
-func (ms *MemoryStorage) DelData(dataID string, userID uint32) error {
-	ctx := metadata.NewOutgoingContext(context.Background(), md)
-	Client.DelData(ctx, &pb.GetDataRequest{DataId: dataID})
-	user, _ := ms.localMem[datamodels.UniqueData{DataID: dataID, UserID: userID}]
-	if user.UserID == userID {
-		user.Deleted = true
-		ms.localMem[datamodels.UniqueData{DataID: dataID, UserID: userID}] = user
+// A record the server does not delete is kept, without the server the local record is deleted and the deletion
+// is uploaded by the next sync. ErrUnavailable is returned for records that are not local then.
+func (ms *MemoryStorage) DelData(ctx context.Context, dataID string, userID uint32) error {
+	ctx = metadata.NewOutgoingContext(ctx, md)
+	_, err := Client.DelData(ctx, &pb.GetDataRequest{DataId: dataID})
+	appErr := apperrors.FromStatus(err)
+	if err != nil && !errors.Is(appErr, apperrors.ErrUnavailable) {
+		return appErr
 	}
-	err := files.WriteData(datamodels.Data{UserID: user.UserID, DataID: user.DataID, Data: user.Data, Metadata: user.Metadata, Deleted: true, ChangedAt: time.Now()})
-	if err != nil {
+	key := datamodels.UniqueData{DataID: dataID, UserID: userID}
+	local, ok := ms.localMem[key]
+	if !ok {
+		if err != nil {
+			return appErr
+		}
+		return nil
+	}
+	local.Deleted, local.ChangedAt = true, time.Now()
+	if err = files.WriteData(local); err != nil {
 		return errors.New("err writing data to file")
 	}
+	ms.localMem[key] = local
 	return nil
 }
 
 // GetData retrieves data from the storage.
-func (ms *MemoryStorage) GetData(dataID string, userID uint32) (datamodels.Data, error) {
-	ctx := metadata.NewOutgoingContext(context.Background(), md)
+// Without the server only local records are read, ErrUnavailable is returned for the others.
+func (ms *MemoryStorage) GetData(ctx context.Context, dataID string, userID uint32) (datamodels.Data, error) {
+	ctx = metadata.NewOutgoingContext(ctx, md)
 	resp, err := Client.GetData(ctx, &pb.GetDataRequest{DataId: dataID})
 	var response datamodels.Data
 	if err == nil {
-		response = datamodels.Data{DataID: resp.Data.DataId, Data: resp.Data.Data, UserID: userID, Metadata: resp.Data.MetaInfo}
+		response = datamodels.Data{DataID: resp.Data.DataId, Data: resp.Data.Data, UserID: userID, Metadata: resp.Data.MetaInfo, ChangedAt: resp.Data.ChangedAt.AsTime(), Revision: resp.Data.Revision}
 	}
 
 	data, ok := ms.localMem[datamodels.UniqueData{DataID: dataID, UserID: userID}]
 	if !ok || data.Deleted {
 		if err == nil {
-			ms.localMem[datamodels.UniqueData{DataID: dataID, UserID: userID}] = response
-			errF := files.WriteData(response)
+			// the local copy is encrypted with the vault key like the other local records
+			stored := response
+			stored.Data, stored.Metadata = encrypt(ctx, response.Data, ms.key), encrypt(ctx, response.Metadata, ms.key)
+			ms.localMem[datamodels.UniqueData{DataID: dataID, UserID: userID}] = stored
+			errF := files.WriteData(stored)
 			if errF != nil {
 				return datamodels.Data{}, errors.New("err writing data to file")
 			}
 			return response, nil
 		}
-		return datamodels.Data{}, errors.New("no data found")
+		// ErrUnavailable tells that the record may exist when the server can not be reached
+		return datamodels.Data{}, apperrors.FromStatus(err)
 	}
 	if data.UserID == userID && !data.Deleted {
-		data.Data = utils.Decrypt(data.Data, clientSecret)
-		data.Metadata = utils.Decrypt(data.Metadata, clientSecret)
+		data.Data = decrypt(ctx, data.Data, ms.key)
+		data.Metadata = decrypt(ctx, data.Metadata, ms.key)
 	}
 	if err == nil && data.ChangedAt.Before(response.ChangedAt) {
 		return response, nil
 	}
+	data.DataID = dataID
+	if err == nil {
+		data.Revision = response.Revision
+	}
 	return data, nil
 }
 
 // Sync synchronizes data from server for a specific user.
-func (ms *MemoryStorage) Sync(userId uint32) ([]datamodels.Data, error) {
-	ctx := metadata.NewOutgoingContext(context.Background(), md)
+// Deleted records the server does not return anymore were purged from its trash, they are removed locally
+// so that ClientSync does not upload them again.
+func (ms *MemoryStorage) Sync(ctx context.Context, userId uint32) ([]datamodels.Data, error) {
+	ctx = metadata.NewOutgoingContext(ctx, md)
+	if device, err := files.DeviceID(); err == nil {
+		ctx = metadata.AppendToOutgoingContext(ctx, "deviceid", device)
+	}
 	resp, err := Client.Sync(ctx, &emptypb.Empty{})
 	if err != nil {
-		return nil, err
+		return nil, apperrors.FromStatus(err)
 	}
 	var response []datamodels.Data
+	onServer := make(map[string]bool, len(resp.Data))
 	for _, v := range resp.Data {
-		data, ok := ms.localMem[datamodels.UniqueData{DataID: v.DataId, UserID: userId}]
-		if !ok {
-			response = append(response, datamodels.Data{DataID: v.DataId, Data: v.Data, UserID: userId, Metadata: v.MetaInfo, Deleted: v.Deleted, ChangedAt: v.ChangedAt.AsTime()})
-			v.Data = utils.Encrypt(v.Data, clientSecret)
-			v.MetaInfo = utils.Encrypt(v.MetaInfo, clientSecret)
-			ms.localMem[datamodels.UniqueData{DataID: v.DataId, UserID: userId}] = datamodels.Data{DataID: v.DataId, Data: v.Data, UserID: userId, Metadata: v.MetaInfo, Deleted: v.Deleted, ChangedAt: v.ChangedAt.AsTime()}
-			err = files.WriteData(datamodels.Data{UserID: data.UserID, DataID: data.DataID, Data: data.Data, Metadata: data.Metadata, Deleted: false, ChangedAt: v.ChangedAt.AsTime()})
-			if err != nil {
-				return nil, errors.New("err writing data to file")
-			}
-		} else if data.ChangedAt.Before(v.ChangedAt.AsTime()) {
-			response = append(response, datamodels.Data{DataID: v.DataId, Data: v.Data, UserID: userId, Metadata: v.MetaInfo, Deleted: v.Deleted, ChangedAt: v.ChangedAt.AsTime()})
-			v.Data = utils.Encrypt(v.Data, clientSecret)
-			v.MetaInfo = utils.Encrypt(v.MetaInfo, clientSecret)
-			ms.localMem[datamodels.UniqueData{DataID: v.DataId, UserID: userId}] = datamodels.Data{DataID: v.DataId, Data: v.Data, UserID: userId, Metadata: v.MetaInfo, Deleted: v.Deleted, ChangedAt: v.ChangedAt.AsTime()}
-			err = files.WriteData(datamodels.Data{UserID: data.UserID, DataID: data.DataID, Data: data.Data, Metadata: data.Metadata, Deleted: false, ChangedAt: v.ChangedAt.AsTime()})
-			if err != nil {
-				return nil, errors.New("err writing data to file")
-			}
+		onServer[v.DataId] = true
+		key := datamodels.UniqueData{DataID: v.DataId, UserID: userId}
+		if data, ok := ms.localMem[key]; ok && !data.ChangedAt.Before(v.ChangedAt.AsTime()) {
+			continue
+		}
+		response = append(response, datamodels.Data{DataID: v.DataId, Data: v.Data, UserID: userId, Metadata: v.MetaInfo, Deleted: v.Deleted, ChangedAt: v.ChangedAt.AsTime()})
+		stored := datamodels.Data{DataID: v.DataId, Data: encrypt(ctx, v.Data, ms.key), UserID: userId, Metadata: encrypt(ctx, v.MetaInfo, ms.key), Deleted: v.Deleted, ChangedAt: v.ChangedAt.AsTime()}
+		ms.localMem[key] = stored
+		if err = files.WriteData(stored); err != nil {
+			return nil, errors.New("err writing data to file")
+		}
+	}
+	var purged []string
+	for k, v := range ms.localMem {
+		if k.UserID == userId && v.Deleted && !onServer[k.DataID] {
+			purged = append(purged, k.DataID)
+			delete(ms.localMem, k)
+		}
+	}
+	if len(purged) > 0 {
+		if err = files.RemoveData(userId, purged...); err != nil {
+			return nil, err
 		}
 	}
 	return response, nil
 }
 
 // ClientSync - synchronize client data with server
-func (ms *MemoryStorage) ClientSync(userID uint32, data []*pb.Data) error {
+func (ms *MemoryStorage) ClientSync(ctx context.Context, userID uint32, data []*pb.Data) error {
 	var req []*pb.Data
 	for k, v := range ms.localMem {
 		if k.UserID == userID {
-			v.Data = utils.Decrypt(v.Data, clientSecret)
-			v.Metadata = utils.Decrypt(v.Metadata, clientSecret)
+			v.Data = decrypt(ctx, v.Data, ms.key)
+			v.Metadata = decrypt(ctx, v.Metadata, ms.key)
 			req = append(req, &pb.Data{Data: v.Data, DataId: v.DataID, MetaInfo: v.Metadata, Deleted: v.Deleted, ChangedAt: timestamppb.New(v.ChangedAt)})
 		}
 	}
-	ctx := metadata.NewOutgoingContext(context.Background(), md)
+	ctx = metadata.NewOutgoingContext(ctx, md)
 	_, err := Client.ClientSync(ctx, &pb.ClientSyncRequest{Data: req})
+	if err != nil {
+		return apperrors.FromStatus(err)
+	}
+	return nil
+}
+
+// EnrollTOTP returns a new TOTP secret of the logged in user.
+func (ms *MemoryStorage) EnrollTOTP(ctx context.Context) (string, error) {
+	resp, err := Client.EnrollTOTP(metadata.NewOutgoingContext(ctx, md), &emptypb.Empty{})
+	if err != nil {
+		return "", apperrors.FromStatus(err)
+	}
+	return resp.Secret, nil
+}
+
+// ConfirmTOTP enables two-factor authentication and returns the recovery codes.
+func (ms *MemoryStorage) ConfirmTOTP(ctx context.Context, code string) ([]string, error) {
+	resp, err := Client.ConfirmTOTP(metadata.NewOutgoingContext(ctx, md), &pb.TOTPCodeRequest{Code: code})
+	if err != nil {
+		return nil, apperrors.FromStatus(err)
+	}
+	return resp.RecoveryCodes, nil
+}
+
+// DisableTOTP turns two-factor authentication off.
+func (ms *MemoryStorage) DisableTOTP(ctx context.Context, code string) error {
+	_, err := Client.DisableTOTP(metadata.NewOutgoingContext(ctx, md), &pb.TOTPCodeRequest{Code: code})
+	if err != nil {
+		return apperrors.FromStatus(err)
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes.
+func (ms *MemoryStorage) RegenerateRecoveryCodes(ctx context.Context, code string) ([]string, error) {
+	resp, err := Client.RegenerateRecoveryCodes(metadata.NewOutgoingContext(ctx, md), &pb.TOTPCodeRequest{Code: code})
+	if err != nil {
+		return nil, apperrors.FromStatus(err)
+	}
+	return resp.RecoveryCodes, nil
+}
+
+// cacheUser - saves the credentials used when the server is unreachable and the vault key wrapped with the password
+func cacheUser(login string, password string, id uint32, key []byte) error {
+	wrapped, err := wrapKey(key, password)
 	if err != nil {
 		return err
 	}
+	return putUser(login, datamodels.Login{ID: id, Password: utils.GetMD5Hash(password), VaultKey: wrapped})
+}
+
+// putUser - replaces the cached user in users.json and in Users
+// users.json is replaced by a rename, so a crash leaves either the old or the new record and no former hash stays.
+func putUser(login string, user datamodels.Login) error {
+	err := files.ReplaceUser(datamodels.Auth{ID: user.ID, Login: login, Password: user.Password, VaultKey: user.VaultKey, PendingKey: user.PendingKey})
+	if err != nil {
+		return errors.New("error writing to user file")
+	}
+	Users.PutUser(login, user)
 	return nil
 }
+
+// ChangePassword replaces the password of the logged in user and wraps the vault key with the new password.
+// The key wrapped with the new password is saved before the server is asked, so a change interrupted after the
+// server accepted it is completed by the next login with the new password, online or offline.
+func (ms *MemoryStorage) ChangePassword(ctx context.Context, login string, oldPassword string, newPassword string) error {
+	user, ok := Users.GetUser(login)
+	if ok {
+		pending, err := wrapKey(ms.key, newPassword)
+		if err != nil {
+			return err
+		}
+		user.PendingKey = pending
+		if err = putUser(login, user); err != nil {
+			return err
+		}
+	}
+	_, err := Client.ChangePassword(metadata.NewOutgoingContext(ctx, md), &pb.ChangePasswordRequest{OldPassword: oldPassword, NewPassword: newPassword})
+	if err != nil {
+		appErr := apperrors.FromStatus(err)
+		// without an answer the server may have changed the password, the pending key is kept for the next login
+		if ok && !errors.Is(appErr, apperrors.ErrUnavailable) {
+			user.PendingKey = nil
+			if wErr := putUser(login, user); wErr != nil {
+				return wErr
+			}
+		}
+		return appErr
+	}
+	if !ok {
+		return nil
+	}
+	user.Password, user.VaultKey, user.PendingKey = utils.GetMD5Hash(newPassword), user.PendingKey, nil
+	return putUser(login, user)
+}
+
+// DeleteAccount removes the account on the server and its local records and credentials.
+// The server asks for a second factor code other than the one the session was opened with.
+func (ms *MemoryStorage) DeleteAccount(ctx context.Context, login string, password string, otpCode string) error {
+	_, err := Client.DeleteAccount(metadata.NewOutgoingContext(ctx, md), &pb.DeleteAccountRequest{Password: password, OtpCode: otpCode})
+	if err != nil {
+		return apperrors.FromStatus(err)
+	}
+	user, ok := Users.GetUser(login)
+	if !ok {
+		return nil
+	}
+	if err = ms.dropUserData(user.ID); err != nil {
+		return err
+	}
+	if err = files.RemoveUser(login); err != nil {
+		return err
+	}
+	Users.DelUser(login)
+	return nil
+}
+
+// ExportAccount calls write for every record of the logged in user kept by the server, including deleted ones.
+func (ms *MemoryStorage) ExportAccount(ctx context.Context, write func(datamodels.Data) error) error {
+	stream, err := Client.ExportAccount(metadata.NewOutgoingContext(ctx, md), &emptypb.Empty{})
+	if err != nil {
+		return apperrors.FromStatus(err)
+	}
+	for {
+		v, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return apperrors.FromStatus(err)
+		}
+		err = write(datamodels.Data{DataID: v.DataId, Data: v.Data, Metadata: v.MetaInfo, Deleted: v.Deleted, ChangedAt: v.ChangedAt.AsTime()})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// ListVersions returns the revisions of the record kept by the server, newest first.
+func (ms *MemoryStorage) ListVersions(ctx context.Context, dataID string) ([]datamodels.Version, error) {
+	resp, err := Client.ListVersions(metadata.NewOutgoingContext(ctx, md), &pb.GetDataRequest{DataId: dataID})
+	if err != nil {
+		return nil, apperrors.FromStatus(err)
+	}
+	versions := make([]datamodels.Version, 0, len(resp.Versions))
+	for _, v := range resp.Versions {
+		versions = append(versions, datamodels.Version{Revision: v.Revision, ChangedAt: v.ChangedAt.AsTime(), Current: v.Current})
+	}
+	return versions, nil
+}
+
+// GetVersion returns the content of a revision of the record.
+func (ms *MemoryStorage) GetVersion(ctx context.Context, dataID string, revision uint32) (datamodels.Data, error) {
+	resp, err := Client.GetVersion(metadata.NewOutgoingContext(ctx, md), &pb.GetVersionRequest{DataId: dataID, Revision: revision})
+	if err != nil {
+		return datamodels.Data{}, apperrors.FromStatus(err)
+	}
+	return datamodels.Data{DataID: dataID, Data: resp.Data.Data, Metadata: resp.Data.MetaInfo, ChangedAt: resp.Data.ChangedAt.AsTime()}, nil
+}
+
+// SetHistoryRetention sets how many older revisions of every record are kept.
+func (ms *MemoryStorage) SetHistoryRetention(ctx context.Context, versions uint32) error {
+	_, err := Client.SetHistoryRetention(metadata.NewOutgoingContext(ctx, md), &pb.HistoryRetentionRequest{Versions: versions})
+	if err != nil {
+		return apperrors.FromStatus(err)
+	}
+	return nil
+}
+
+// PasswordPolicies returns the password policies of the folders of the vault, the empty folder holds the vault default.
+func (ms *MemoryStorage) PasswordPolicies(ctx context.Context) (map[string]passgen.Policy, error) {
+	resp, err := Client.ListPasswordPolicies(metadata.NewOutgoingContext(ctx, md), new(emptypb.Empty))
+	if err != nil {
+		return nil, apperrors.FromStatus(err)
+	}
+	policies := map[string]passgen.Policy{}
+	for _, v := range resp.Policies {
+		policies[v.Folder] = passgen.FromPB(v.Policy)
+	}
+	return policies, nil
+}
+
+// SetPasswordPolicy sets the password policy of the folder, a nil policy removes it.
+func (ms *MemoryStorage) SetPasswordPolicy(ctx context.Context, folder string, policy *passgen.Policy) error {
+	in := &pb.FolderPasswordPolicy{Folder: folder}
+	if policy != nil {
+		in.Policy = passgen.ToPB(*policy)
+	}
+	if _, err := Client.SetPasswordPolicy(metadata.NewOutgoingContext(ctx, md), in); err != nil {
+		return apperrors.FromStatus(err)
+	}
+	return nil
+}
+
+// Unlock returns the session of the logged in user kept for ttl, the session agent serving it lets commands
+// without credentials Resume it. The server session token and the vault key are kept in the memory of the agent only.
+func (ms *MemoryStorage) Unlock(ctx context.Context, login string, userID uint32, ttl time.Duration) (datamodels.Session, error) {
+	session := datamodels.Session{Login: login, ID: userID, Key: ms.key, Expires: time.Now().Add(ttl)}
+	if token := md.Get("userid"); len(token) > 0 {
+		session.Token = token[0]
+	}
+	return session, nil
+}
+
+// Resume continues the unlocked session and returns the user id, ErrLocked is returned when there is none.
+// Without a server token, for sessions unlocked offline, only the local vault is available.
+func (ms *MemoryStorage) Resume(ctx context.Context) (uint32, error) {
+	socket, err := files.SessionSocket()
+	if err != nil {
+		return 0, err
+	}
+	session, err := sessionagent.Get(socket)
+	if errors.Is(err, sessionagent.ErrNoAgent) {
+		return 0, ErrLocked
+	}
+	if err != nil {
+		return 0, err
+	}
+	if time.Now().After(session.Expires) {
+		return 0, ErrLocked
+	}
+	ms.key = session.Key
+	if session.Token != "" {
+		md = metadata.Pairs("userid", session.Token)
+	}
+	return session.ID, nil
+}
+
+// Lock ends the unlocked session by stopping the session agent.
+func (ms *MemoryStorage) Lock(ctx context.Context) error {
+	socket, err := files.SessionSocket()
+	if err != nil {
+		return err
+	}
+	return sessionagent.Lock(socket)
+}
+
+// ListData returns the decrypted records of the user kept in the local vault, sorted by data id.
+// It works without the server, run sync first to see records added on other devices.
+func (ms *MemoryStorage) ListData(ctx context.Context, userID uint32) ([]datamodels.Data, error) {
+	var resp []datamodels.Data
+	for k, v := range ms.localMem {
+		if k.UserID != userID || v.Deleted {
+			continue
+		}
+		v.DataID, v.UserID = k.DataID, k.UserID
+		v.Data = decrypt(ctx, v.Data, ms.key)
+		v.Metadata = decrypt(ctx, v.Metadata, ms.key)
+		resp = append(resp, v)
+	}
+	sort.Slice(resp, func(i, j int) bool { return resp[i].DataID < resp[j].DataID })
+	return resp, nil
+}
+
+// UpdateData changes the fields of the record listed in mask (MaskDataID, MaskData, MaskMetaInfo)
+// on the server and in the local storage. A revision other than 0 must be the current revision of the record.
+func (ms *MemoryStorage) UpdateData(ctx context.Context, dataID string, data datamodels.Data, mask []string, revision uint32) (datamodels.Data, error) {
+	req := &pb.UpdateDataRequest{
+		DataId:     dataID,
+		Data:       &pb.Data{DataId: data.DataID, Data: data.Data, MetaInfo: data.Metadata},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: mask},
+		Revision:   revision,
+	}
+	resp, err := Client.UpdateData(metadata.NewOutgoingContext(ctx, md), req)
+	if err != nil {
+		return datamodels.Data{}, apperrors.FromStatus(err)
+	}
+	old := datamodels.UniqueData{DataID: dataID, UserID: data.UserID}
+	local, ok := ms.localMem[old]
+	if !ok {
+		// the record is read from the server by the next get or sync
+		return datamodels.Data{DataID: resp.Data.DataId, ChangedAt: resp.Data.ChangedAt.AsTime(), Revision: resp.Data.Revision}, nil
+	}
+	for _, path := range mask {
+		switch path {
+		case MaskData:
+			local.Data = encrypt(ctx, data.Data, ms.key)
+		case MaskMetaInfo:
+			local.Metadata = encrypt(ctx, data.Metadata, ms.key)
+		}
+	}
+	local.UserID, local.DataID, local.Deleted = data.UserID, resp.Data.DataId, false
+	local.ChangedAt, local.Revision = resp.Data.ChangedAt.AsTime(), resp.Data.Revision
+	if local.DataID != dataID {
+		delete(ms.localMem, old)
+		if err = files.RemoveData(data.UserID, dataID); err != nil {
+			return datamodels.Data{}, err
+		}
+	}
+	ms.localMem[datamodels.UniqueData{DataID: local.DataID, UserID: data.UserID}] = local
+	if err = files.WriteData(local); err != nil {
+		return datamodels.Data{}, errors.New("err writing data to file")
+	}
+	return datamodels.Data{DataID: local.DataID, ChangedAt: local.ChangedAt, Revision: local.Revision}, nil
+}
+
+// ListTrash returns the deleted records kept by the server.
+func (ms *MemoryStorage) ListTrash(ctx context.Context) ([]datamodels.Data, error) {
+	resp, err := Client.ListTrash(metadata.NewOutgoingContext(ctx, md), &emptypb.Empty{})
+	if err != nil {
+		return nil, apperrors.FromStatus(err)
+	}
+	data := make([]datamodels.Data, 0, len(resp.Data))
+	for _, v := range resp.Data {
+		data = append(data, datamodels.Data{DataID: v.DataId, Data: v.Data, Metadata: v.MetaInfo, Deleted: true, ChangedAt: v.ChangedAt.AsTime()})
+	}
+	return data, nil
+}
+
+// RestoreData takes the record of the user out of the trash on the server and in the local storage.
+func (ms *MemoryStorage) RestoreData(ctx context.Context, dataID string, userID uint32) error {
+	_, err := Client.RestoreData(metadata.NewOutgoingContext(ctx, md), &pb.GetDataRequest{DataId: dataID})
+	if err != nil {
+		return apperrors.FromStatus(err)
+	}
+	data, ok := ms.localMem[datamodels.UniqueData{DataID: dataID, UserID: userID}]
+	if !ok || !data.Deleted {
+		return nil
+	}
+	data.DataID, data.Deleted, data.ChangedAt = dataID, false, time.Now()
+	ms.localMem[datamodels.UniqueData{DataID: dataID, UserID: userID}] = data
+	if err = files.WriteData(data); err != nil {
+		return errors.New("err writing data to file")
+	}
+	return nil
+}
+
+// ListDevices returns the devices of the logged in user known to the server, latest synchronized first.
+func (ms *MemoryStorage) ListDevices(ctx context.Context) ([]datamodels.Device, error) {
+	resp, err := Client.ListDevices(metadata.NewOutgoingContext(ctx, md), &emptypb.Empty{})
+	if err != nil {
+		return nil, apperrors.FromStatus(err)
+	}
+	devices := make([]datamodels.Device, 0, len(resp.Devices))
+	for _, v := range resp.Devices {
+		devices = append(devices, datamodels.Device{ID: v.DeviceId, AckedAt: v.AckedAt.AsTime()})
+	}
+	return devices, nil
+}
+
+// RemoveDevice makes the server stop waiting for the device to synchronize deleted records.
+func (ms *MemoryStorage) RemoveDevice(ctx context.Context, deviceID string) error {
+	if _, err := Client.RemoveDevice(metadata.NewOutgoingContext(ctx, md), &pb.DeviceRequest{DeviceId: deviceID}); err != nil {
+		return apperrors.FromStatus(err)
+	}
+	return nil
+}
+
+// EmptyTrash removes the deleted records, it returns the number of records removed at once
+// and of records removed after every device of the user synchronizes.
+func (ms *MemoryStorage) EmptyTrash(ctx context.Context) (int64, int64, error) {
+	resp, err := Client.EmptyTrash(metadata.NewOutgoingContext(ctx, md), &emptypb.Empty{})
+	if err != nil {
+		return 0, 0, apperrors.FromStatus(err)
+	}
+	return resp.Purged, resp.Pending, nil
+}
@@ -1,38 +1,310 @@
 package storage
 
 import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gophkeeper/internal/apperrors"
+	"gophkeeper/internal/config"
 	"gophkeeper/internal/datamodels"
+	files "gophkeeper/internal/storage/filereaders"
+	"gophkeeper/internal/utils"
+	pb "gophkeeper/proto"
 )
 
+// TestMain - keeps the client files of the tests out of the configuration directory of the user
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "gophkeeper")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("XDG_CONFIG_HOME", dir)
+	os.Setenv("HOME", dir)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
 func TestMemoryStorage_Login(t *testing.T) {
 	s := NewMemoryStorage()
-	Init()
-	id, err := s.Login("final", "1")
+	Init(config.NewProfile())
+	id, err := s.Login(context.Background(), "final", "1")
 	assert.NoError(t, err)
 	assert.NotNil(t, id)
 }
 func TestMemoryStorage_AddData(t *testing.T) {
 	s := NewMemoryStorage()
-	Init()
-	err := s.AddData(datamodels.Data{DataID: "new", Data: "test", Metadata: "test"})
+	Init(config.NewProfile())
+	err := s.AddData(context.Background(), datamodels.Data{DataID: "new", Data: "test", Metadata: "test"})
 	assert.NoError(t, err)
 
 }
 func TestMemoryStorage_DelData(t *testing.T) {
 	s := NewMemoryStorage()
-	Init()
-	err := s.DelData("new", 0)
+	Init(config.NewProfile())
+	err := s.DelData(context.Background(), "new", 0)
 	assert.NoError(t, err)
 }
 func TestMemoryStorage_Get(t *testing.T) {
 	s := NewMemoryStorage()
-	Init()
-	err := s.AddData(datamodels.Data{DataID: "new", Data: "test", Metadata: "test"})
+	Init(config.NewProfile())
+	err := s.AddData(context.Background(), datamodels.Data{DataID: "new", Data: "test", Metadata: "test"})
 	assert.NoError(t, err)
-	data, err := s.GetData("new", 0)
+	data, err := s.GetData(context.Background(), "new", 0)
 	assert.NoError(t, err)
 	assert.NotNil(t, data)
 }
+
+// syncClient - server returning the records of data from Sync
+type syncClient struct {
+	pb.GophkeeperClient
+	data []*pb.Data
+}
+
+func (c *syncClient) Sync(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*pb.SynchronizationResponse, error) {
+	return &pb.SynchronizationResponse{Data: c.data}, nil
+}
+
+func TestMemoryStorage_SyncDropsPurgedTombstones(t *testing.T) {
+	saved := Client
+	defer func() { Client = saved }()
+	at := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	Client = &syncClient{data: []*pb.Data{{DataId: "kept", Deleted: true, ChangedAt: timestamppb.New(at)}}}
+	s := &MemoryStorage{key: clientSecret, localMem: map[datamodels.UniqueData]datamodels.Data{
+		{DataID: "purged", UserID: 7}: {DataID: "purged", UserID: 7, Deleted: true, ChangedAt: at},
+		{DataID: "kept", UserID: 7}:   {DataID: "kept", UserID: 7, Deleted: true, ChangedAt: at},
+		{DataID: "local", UserID: 7}:  {DataID: "local", UserID: 7, ChangedAt: at},
+	}}
+
+	_, err := s.Sync(context.Background(), 7)
+	require.NoError(t, err)
+	assert.NotContains(t, s.localMem, datamodels.UniqueData{DataID: "purged", UserID: 7}, "tombstones purged by the server are not uploaded again")
+	assert.Contains(t, s.localMem, datamodels.UniqueData{DataID: "kept", UserID: 7})
+	assert.Contains(t, s.localMem, datamodels.UniqueData{DataID: "local", UserID: 7}, "records not uploaded yet stay")
+}
+
+// addClient - server answering AddData and DelData with err
+type addClient struct {
+	pb.GophkeeperClient
+	err error
+}
+
+func (c *addClient) AddData(ctx context.Context, in *pb.AddDataRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	return new(emptypb.Empty), c.err
+}
+
+func (c *addClient) DelData(ctx context.Context, in *pb.GetDataRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	return new(emptypb.Empty), c.err
+}
+
+func TestMemoryStorage_AddDataServerError(t *testing.T) {
+	saved := Client
+	defer func() { Client = saved }()
+	s := &MemoryStorage{key: clientSecret, localMem: map[datamodels.UniqueData]datamodels.Data{}}
+
+	Client = &addClient{err: status.Error(codes.InvalidArgument, "data too long")}
+	err := s.AddData(context.Background(), datamodels.Data{DataID: "rejected", UserID: 7, Data: "x"})
+	assert.ErrorIs(t, err, apperrors.ErrValidation)
+	assert.NotContains(t, s.localMem, datamodels.UniqueData{DataID: "rejected", UserID: 7}, "rejected records are not stored")
+
+	Client = &addClient{err: status.Error(codes.Unavailable, "connection refused")}
+	require.NoError(t, s.AddData(context.Background(), datamodels.Data{DataID: "offline", UserID: 7, Data: "x"}))
+	assert.Contains(t, s.localMem, datamodels.UniqueData{DataID: "offline", UserID: 7}, "records added offline are uploaded by sync")
+}
+
+func TestMemoryStorage_DelDataServerError(t *testing.T) {
+	saved := Client
+	defer func() { Client = saved }()
+	local := datamodels.UniqueData{DataID: "local", UserID: 7}
+	s := &MemoryStorage{key: clientSecret, localMem: map[datamodels.UniqueData]datamodels.Data{
+		local: {DataID: "local", UserID: 7, Data: encrypt(context.Background(), "x", clientSecret)},
+	}}
+
+	Client = &addClient{err: status.Error(codes.PermissionDenied, "account is disabled")}
+	assert.ErrorIs(t, s.DelData(context.Background(), "local", 7), apperrors.ErrPermission)
+	assert.False(t, s.localMem[local].Deleted, "records the server did not delete are kept")
+
+	Client = &addClient{err: status.Error(codes.NotFound, "not found")}
+	assert.ErrorIs(t, s.DelData(context.Background(), "unknown", 7), apperrors.ErrNotFound)
+	Client = &addClient{err: status.Error(codes.Unavailable, "connection refused")}
+	assert.ErrorIs(t, s.DelData(context.Background(), "unknown", 7), apperrors.ErrUnavailable)
+	assert.NotContains(t, s.localMem, datamodels.UniqueData{DataID: "unknown", UserID: 7}, "no tombstone is written for unknown records")
+
+	require.NoError(t, s.DelData(context.Background(), "local", 7))
+	assert.True(t, s.localMem[local].Deleted, "records deleted offline are deleted by sync")
+	assert.Equal(t, "x", decrypt(context.Background(), s.localMem[local].Data, clientSecret))
+}
+
+// passwdClient - server of a single user that changes its password, down answers every call with Unavailable
+type passwdClient struct {
+	pb.GophkeeperClient
+	password string
+	// lost - the password is changed but the answer is lost
+	lost bool
+	down bool
+}
+
+func (c *passwdClient) Login(ctx context.Context, in *pb.AuthLoginRequest, opts ...grpc.CallOption) (*pb.AuthLoginResponse, error) {
+	if c.down {
+		return nil, status.Error(codes.Unavailable, "connection refused")
+	}
+	if in.Password != c.password {
+		return nil, status.Error(codes.Unauthenticated, "invalid password")
+	}
+	return &pb.AuthLoginResponse{Id: 42}, nil
+}
+
+func (c *passwdClient) ChangePassword(ctx context.Context, in *pb.ChangePasswordRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	if c.down {
+		return nil, status.Error(codes.Unavailable, "connection refused")
+	}
+	if in.OldPassword != c.password {
+		return nil, status.Error(codes.Unauthenticated, "invalid password")
+	}
+	c.password = in.NewPassword
+	if c.lost {
+		return nil, status.Error(codes.Unavailable, "connection reset")
+	}
+	return new(emptypb.Empty), nil
+}
+
+func (c *passwdClient) AddData(ctx context.Context, in *pb.AddDataRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	return new(emptypb.Empty), nil
+}
+
+func (c *passwdClient) GetData(ctx context.Context, in *pb.GetDataRequest, opts ...grpc.CallOption) (*pb.GetDataResponse, error) {
+	return nil, status.Error(codes.Unavailable, "connection refused")
+}
+
+// cachedUsers - records of the login in users.json
+func cachedUsers(t *testing.T, login string) []datamodels.Auth {
+	dir, err := files.Dir()
+	require.NoError(t, err)
+	b, err := os.ReadFile(filepath.Join(dir, "users.json"))
+	require.NoError(t, err)
+	var users []datamodels.Auth
+	for _, line := range strings.Split(string(b), "\n") {
+		var u datamodels.Auth
+		if line == "" || json.Unmarshal([]byte(line), &u) != nil || u.Login != login {
+			continue
+		}
+		users = append(users, u)
+	}
+	return users
+}
+
+func TestMemoryStorage_ChangePassword(t *testing.T) {
+	saved := Client
+	defer func() { Client = saved }()
+	server := &passwdClient{password: "old"}
+	Client = server
+	ctx := context.Background()
+	s := NewMemoryStorage()
+
+	id, err := s.Login(ctx, "passwd", "old")
+	require.NoError(t, err)
+	require.NoError(t, s.AddData(ctx, datamodels.Data{DataID: "a", UserID: id, Data: "secret"}))
+	require.NoError(t, s.ChangePassword(ctx, "passwd", "old", "new"))
+	users := cachedUsers(t, "passwd")
+	require.Len(t, users, 1, "the former record is removed")
+	assert.Equal(t, utils.GetMD5Hash("new"), users[0].Password)
+	assert.Nil(t, users[0].PendingKey)
+
+	server.down = true
+	offline := NewMemoryStorage()
+	_, err = offline.Login(ctx, "passwd", "old")
+	assert.ErrorIs(t, err, ErrWrongPassword)
+	_, err = offline.Login(ctx, "passwd", "new")
+	require.NoError(t, err)
+	data, err := offline.GetData(ctx, "a", id)
+	require.NoError(t, err)
+	assert.Equal(t, "secret", data.Data, "the records are read with the key wrapped with the new password")
+
+	server.down, server.lost = false, true
+	assert.ErrorIs(t, offline.ChangePassword(ctx, "passwd", "new", "newest"), apperrors.ErrUnavailable)
+	server.lost = false
+	resumed := NewMemoryStorage()
+	_, err = resumed.Login(ctx, "passwd", "newest")
+	require.NoError(t, err)
+	users = cachedUsers(t, "passwd")
+	require.Len(t, users, 1)
+	assert.Equal(t, utils.GetMD5Hash("newest"), users[0].Password, "the interrupted change is completed by the login")
+	assert.Nil(t, users[0].PendingKey)
+	data, err = resumed.GetData(ctx, "a", id)
+	require.NoError(t, err)
+	assert.Equal(t, "secret", data.Data)
+
+	server.password = "elsewhere"
+	again := NewMemoryStorage()
+	_, err = again.Login(ctx, "passwd", "elsewhere")
+	require.NoError(t, err, "a password changed on another device replaces the key")
+	assert.NotContains(t, again.(*MemoryStorage).localMem, datamodels.UniqueData{DataID: "a", UserID: id}, "records of the lost key are read from the server again")
+}
+
+func TestMemoryStorage_UpgradeKey(t *testing.T) {
+	saved := Client
+	defer func() { Client = saved }()
+	server := &passwdClient{password: "legacy"}
+	Client = server
+	ctx := context.Background()
+	require.NoError(t, files.ReplaceUser(datamodels.Auth{ID: 42, Login: "legacy", Password: utils.GetMD5Hash("legacy")}))
+	record := datamodels.Data{DataID: "legacy", UserID: 42, Data: utils.Encrypt("secret", clientSecret), Metadata: utils.Encrypt("", clientSecret)}
+	require.NoError(t, files.WriteData(record))
+
+	s := NewMemoryStorage()
+	_, err := s.Login(ctx, "legacy", "legacy")
+	require.NoError(t, err)
+	users := cachedUsers(t, "legacy")
+	require.Len(t, users, 1)
+	require.NotNil(t, users[0].VaultKey, "the user gets a vault key of its own")
+	assert.Nil(t, users[0].PendingKey)
+	stored, err := files.ReadData()
+	require.NoError(t, err)
+	_, err = utils.Open(stored[datamodels.UniqueData{DataID: "legacy", UserID: 42}].Data, clientSecret)
+	assert.ErrorIs(t, err, utils.ErrDecrypt, "the record is not encrypted with the client key anymore")
+
+	server.down = true
+	offline := NewMemoryStorage()
+	_, err = offline.Login(ctx, "legacy", "legacy")
+	require.NoError(t, err)
+	data, err := offline.GetData(ctx, "legacy", 42)
+	require.NoError(t, err)
+	assert.Equal(t, "secret", data.Data)
+}
+
+func TestMemoryStorage_Unavailable(t *testing.T) {
+	saved := Client
+	defer func() { Client = saved }()
+	server := &passwdClient{password: "secret"}
+	Client = server
+	ctx := context.Background()
+	s := NewMemoryStorage()
+
+	_, err := s.Login(ctx, "unavailable", "wrong")
+	assert.ErrorIs(t, err, apperrors.ErrUnauthenticated, "the answer of the server is final")
+	id, err := s.Login(ctx, "unavailable", "secret")
+	require.NoError(t, err)
+	server.password = "changed"
+	_, err = s.Login(ctx, "unavailable", "secret")
+	assert.ErrorIs(t, err, apperrors.ErrUnauthenticated, "cached credentials do not override the server")
+
+	server.down = true
+	_, err = s.Login(ctx, "unknown", "secret")
+	assert.ErrorIs(t, err, apperrors.ErrUnavailable)
+	_, err = s.Login(ctx, "unavailable", "secret")
+	require.NoError(t, err, "cached users work without the server")
+	_, err = s.GetData(ctx, "missing", id)
+	assert.ErrorIs(t, err, apperrors.ErrUnavailable, "the record may exist on the server")
+}
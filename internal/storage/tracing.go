@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"gophkeeper/internal/tracing"
+	"gophkeeper/internal/utils"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// execer - *sql.DB or *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// startQuery - starts the span of a single SQL statement
+// Only the statement is recorded, never its arguments.
+func startQuery(ctx context.Context, query string) (context.Context, func(err error)) {
+	op := strings.ToLower(strings.Fields(strings.TrimSpace(query) + " sql")[0])
+	ctx, span := tracing.Start(ctx, "sql "+op,
+		attribute.String("db.system", "postgresql"),
+		attribute.String("db.operation", op),
+		attribute.String("db.statement", query))
+	return ctx, func(err error) { tracing.End(span, err) }
+}
+
+// execContext - ExecContext within a span
+func execContext(ctx context.Context, e execer, query string, args ...any) (sql.Result, error) {
+	ctx, end := startQuery(ctx, query)
+	res, err := e.ExecContext(ctx, query, args...)
+	end(err)
+	return res, err
+}
+
+// queryContext - QueryContext within a span
+func queryContext(ctx context.Context, e execer, query string, args ...any) (*sql.Rows, error) {
+	ctx, end := startQuery(ctx, query)
+	rows, err := e.QueryContext(ctx, query, args...)
+	end(err)
+	return rows, err
+}
+
+// queryRowContext - QueryRowContext within a span
+func queryRowContext(ctx context.Context, e execer, query string, args ...any) *sql.Row {
+	ctx, end := startQuery(ctx, query)
+	row := e.QueryRowContext(ctx, query, args...)
+	end(row.Err())
+	return row
+}
+
+// encrypt - utils.Encrypt within a span
+func encrypt(ctx context.Context, text string, key []byte) string {
+	_, span := tracing.Start(ctx, "crypto encrypt", attribute.Int("crypto.size", len(text)))
+	defer span.End()
+	return utils.Encrypt(text, key)
+}
+
+// decrypt - utils.Decrypt within a span
+func decrypt(ctx context.Context, text string, key []byte) string {
+	_, span := tracing.Start(ctx, "crypto decrypt", attribute.Int("crypto.size", len(text)))
+	defer span.End()
+	return utils.Decrypt(text, key)
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+
+	"gophkeeper/internal/datamodels"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// vaultKeySize - size of the key of the local records and of the key derived from the password
+const vaultKeySize = 32
+
+// newVaultKey - random key of the local records of a user new to the client
+func newVaultKey() ([]byte, error) {
+	key := make([]byte, vaultKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, errors.New("error generating vault key")
+	}
+	return key, nil
+}
+
+// passwordCipher - AES-GCM with the key derived from the password and the salt
+func passwordCipher(password string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(password), salt, 1<<15, 8, 1, vaultKeySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// wrapKey - the vault key encrypted with a key derived from the password and a new salt
+func wrapKey(key []byte, password string) (*datamodels.VaultKey, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, errors.New("error generating salt")
+	}
+	aead, err := passwordCipher(password, salt)
+	if err != nil {
+		return nil, errors.New("error wrapping vault key")
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, errors.New("error generating nonce")
+	}
+	return &datamodels.VaultKey{
+		Wrapped: base64.RawStdEncoding.EncodeToString(aead.Seal(nonce, nonce, key, nil)),
+		Salt:    base64.RawStdEncoding.EncodeToString(salt),
+	}, nil
+}
+
+// unwrapKey - the vault key of wrapKey, ErrWrongPassword is returned for another password
+func unwrapKey(v *datamodels.VaultKey, password string) ([]byte, error) {
+	if v == nil {
+		return nil, ErrWrongPassword
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(v.Salt)
+	if err != nil {
+		return nil, errors.New("error decoding vault key")
+	}
+	wrapped, err := base64.RawStdEncoding.DecodeString(v.Wrapped)
+	if err != nil {
+		return nil, errors.New("error decoding vault key")
+	}
+	aead, err := passwordCipher(password, salt)
+	if err != nil {
+		return nil, errors.New("error unwrapping vault key")
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, errors.New("error decoding vault key")
+	}
+	key, err := aead.Open(nil, wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrWrongPassword
+	}
+	return key, nil
+}
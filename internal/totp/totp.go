@@ -0,0 +1,249 @@
+// Package totp implements RFC 4226 HOTP and RFC 6238 TOTP one-time passwords and recovery codes.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Supported hash algorithms
+const (
+	SHA1   = "SHA1"
+	SHA256 = "SHA256"
+	SHA512 = "SHA512"
+)
+
+// Module errors
+var (
+	ErrInvalidSecret    = errors.New("invalid base32 secret")
+	ErrInvalidAlgorithm = errors.New("unsupported algorithm, expected SHA1, SHA256 or SHA512")
+	ErrInvalidDigits    = errors.New("unsupported number of digits, expected 6 or 8")
+)
+
+// secretSize - size of generated secrets, 160 bits as recommended by RFC 4226
+const secretSize = 20
+
+// encoding - base32 without padding used by authenticator apps
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Key - parameters of a TOTP generator
+type Key struct {
+	Secret    []byte
+	Algorithm string
+	Digits    int
+	Period    time.Duration
+}
+
+// NewKey returns a key with the default parameters: SHA1, 6 digits and a 30 seconds period.
+func NewKey(secret []byte) Key {
+	return Key{Secret: secret, Algorithm: SHA1, Digits: 6, Period: 30 * time.Second}
+}
+
+// GenerateKey returns a key with a random secret and the default parameters.
+func GenerateKey() (Key, error) {
+	secret := make([]byte, secretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return Key{}, err
+	}
+	return NewKey(secret), nil
+}
+
+// EncodeSecret returns the secret in base32 without padding.
+func EncodeSecret(secret []byte) string {
+	return encoding.EncodeToString(secret)
+}
+
+// DecodeSecret parses a base32 secret, spaces, padding and lower case are accepted.
+func DecodeSecret(s string) ([]byte, error) {
+	s = strings.ToUpper(strings.ReplaceAll(s, " ", ""))
+	secret, err := encoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil || len(secret) == 0 {
+		return nil, ErrInvalidSecret
+	}
+	return secret, nil
+}
+
+// Validate checks the parameters of the key.
+func (k Key) Validate() error {
+	if len(k.Secret) == 0 {
+		return ErrInvalidSecret
+	}
+	if _, err := newHash(k.Algorithm); err != nil {
+		return err
+	}
+	if k.Digits != 6 && k.Digits != 8 {
+		return ErrInvalidDigits
+	}
+	if k.Period <= 0 {
+		return errors.New("period must be positive")
+	}
+	return nil
+}
+
+// newHash - hash constructor of the algorithm
+func newHash(algorithm string) (func() hash.Hash, error) {
+	switch strings.ToUpper(algorithm) {
+	case SHA1, "":
+		return sha1.New, nil
+	case SHA256:
+		return sha256.New, nil
+	case SHA512:
+		return sha512.New, nil
+	}
+	return nil, ErrInvalidAlgorithm
+}
+
+// HOTP returns the RFC 4226 code of the counter.
+func (k Key) HOTP(counter uint64) string {
+	h, err := newHash(k.Algorithm)
+	if err != nil {
+		h = sha1.New
+	}
+	mac := hmac.New(h, k.Secret)
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	digits := k.Digits
+	if digits == 0 {
+		digits = 6
+	}
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%mod)
+}
+
+// counter - time step of t
+func (k Key) counter(t time.Time) uint64 {
+	return uint64(t.Unix() / int64(k.Period/time.Second))
+}
+
+// Code returns the code valid at t.
+func (k Key) Code(t time.Time) string {
+	return k.HOTP(k.counter(t))
+}
+
+// Remaining returns how long the code of t stays valid.
+func (k Key) Remaining(t time.Time) time.Duration {
+	period := int64(k.Period / time.Second)
+	return time.Duration(period-t.Unix()%period) * time.Second
+}
+
+// Verify checks the code at t, allowing one time step of clock skew in both directions,
+// and returns the time step of the code. A code must be accepted once only (RFC 6238 §5.2):
+// codes of steps at or below last, the step of the last accepted code, are rejected.
+func (k Key) Verify(code string, t time.Time, last uint64) (uint64, bool) {
+	code = strings.ReplaceAll(code, " ", "")
+	c := k.counter(t)
+	for _, step := range []uint64{c, c - 1, c + 1} {
+		if step <= last {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(k.HOTP(step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// URI returns the otpauth:// URI of the key, authenticator apps import it from a QR code.
+func (k Key) URI(issuer string, account string) string {
+	v := url.Values{}
+	v.Set("secret", EncodeSecret(k.Secret))
+	v.Set("issuer", issuer)
+	v.Set("algorithm", strings.ToUpper(k.Algorithm))
+	v.Set("digits", strconv.Itoa(k.Digits))
+	v.Set("period", strconv.Itoa(int(k.Period/time.Second)))
+	u := url.URL{Scheme: "otpauth", Host: "totp", Path: "/" + issuer + ":" + account, RawQuery: v.Encode()}
+	return u.String()
+}
+
+// GenerateRecoveryCodes returns n random one-time recovery codes in the form xxxxx-xxxxx.
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, n)
+	buf := make([]byte, 5)
+	for i := range codes {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		s := strings.ToLower(hex.EncodeToString(buf))
+		codes[i] = s[:5] + "-" + s[5:]
+	}
+	return codes, nil
+}
+
+// IsRecoveryCode reports whether code has the form of a recovery code.
+func IsRecoveryCode(code string) bool {
+	return strings.Contains(code, "-")
+}
+
+// HashRecoveryCode returns the hash under which a recovery code is stored.
+func HashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(code))))
+	return hex.EncodeToString(sum[:])
+}
+
+// ParseURI parses an otpauth://totp/ URI, as encoded in authenticator QR codes.
+// The label is returned as the issuer and the account of the key.
+func ParseURI(uri string) (Key, string, string, error) {
+	u, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil {
+		return Key{}, "", "", fmt.Errorf("invalid otpauth uri: %w", err)
+	}
+	if u.Scheme != "otpauth" {
+		return Key{}, "", "", errors.New("invalid otpauth uri: scheme must be otpauth")
+	}
+	if u.Host != "totp" {
+		return Key{}, "", "", fmt.Errorf("invalid otpauth uri: unsupported type %q, only totp is supported", u.Host)
+	}
+	q := u.Query()
+	secret, err := DecodeSecret(q.Get("secret"))
+	if err != nil {
+		return Key{}, "", "", err
+	}
+	key := NewKey(secret)
+	if a := q.Get("algorithm"); a != "" {
+		key.Algorithm = strings.ToUpper(a)
+	}
+	if d := q.Get("digits"); d != "" {
+		if key.Digits, err = strconv.Atoi(d); err != nil {
+			return Key{}, "", "", ErrInvalidDigits
+		}
+	}
+	if p := q.Get("period"); p != "" {
+		seconds, err := strconv.Atoi(p)
+		if err != nil || seconds <= 0 {
+			return Key{}, "", "", errors.New("invalid otpauth uri: period must be a positive number of seconds")
+		}
+		key.Period = time.Duration(seconds) * time.Second
+	}
+	if err = key.Validate(); err != nil {
+		return Key{}, "", "", err
+	}
+	issuer, account := "", strings.TrimPrefix(u.Path, "/")
+	if i := strings.Index(account, ":"); i >= 0 {
+		issuer, account = strings.TrimSpace(account[:i]), strings.TrimSpace(account[i+1:])
+	}
+	if q.Get("issuer") != "" {
+		issuer = q.Get("issuer")
+	}
+	return key, issuer, account, nil
+}
@@ -0,0 +1,143 @@
+package totp
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// RFC 6238 appendix B test vectors
+func TestKey_Code(t *testing.T) {
+	seeds := map[string][]byte{
+		SHA1:   []byte("12345678901234567890"),
+		SHA256: []byte("12345678901234567890123456789012"),
+		SHA512: []byte("1234567890123456789012345678901234567890123456789012345678901234"),
+	}
+	tests := []struct {
+		unix      int64
+		algorithm string
+		code      string
+	}{
+		{59, SHA1, "94287082"},
+		{59, SHA256, "46119246"},
+		{59, SHA512, "90693936"},
+		{1111111109, SHA1, "07081804"},
+		{1111111109, SHA256, "68084774"},
+		{1111111109, SHA512, "25091201"},
+		{20000000000, SHA1, "65353130"},
+		{20000000000, SHA256, "77737706"},
+		{20000000000, SHA512, "47863826"},
+	}
+	for _, tt := range tests {
+		k := Key{Secret: seeds[tt.algorithm], Algorithm: tt.algorithm, Digits: 8, Period: 30 * time.Second}
+		assert.Equal(t, tt.code, k.Code(time.Unix(tt.unix, 0)), "%s at %d", tt.algorithm, tt.unix)
+	}
+	k := NewKey(seeds[SHA1])
+	assert.Equal(t, "287082", k.Code(time.Unix(59, 0)))
+}
+
+func TestKey_Verify(t *testing.T) {
+	k, err := GenerateKey()
+	require.NoError(t, err)
+	require.NoError(t, k.Validate())
+	now := time.Date(2023, 1, 1, 12, 0, 10, 0, time.UTC)
+	code := k.Code(now)
+
+	valid := func(code string, t time.Time, last uint64) bool {
+		_, ok := k.Verify(code, t, last)
+		return ok
+	}
+	assert.True(t, valid(code, now, 0))
+	assert.True(t, valid(code, now.Add(30*time.Second), 0), "one step of skew")
+	assert.True(t, valid(code, now.Add(-30*time.Second), 0), "one step of skew")
+	assert.False(t, valid(code, now.Add(90*time.Second), 0))
+	assert.False(t, valid("", now, 0))
+
+	step, ok := k.Verify(code, now, 0)
+	require.True(t, ok)
+	assert.Equal(t, k.counter(now), step)
+	assert.False(t, valid(code, now, step), "codes are accepted once")
+	assert.False(t, valid(code, now.Add(30*time.Second), step), "codes are accepted once")
+	assert.True(t, valid(k.Code(now.Add(30*time.Second)), now, step), "code of the next step")
+	assert.Equal(t, 20*time.Second, k.Remaining(now))
+}
+
+func TestSecret(t *testing.T) {
+	secret, err := DecodeSecret("gezd gnbv gy3t qojq====")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("1234567890"), secret)
+	assert.Equal(t, "GEZDGNBVGY3TQOJQ", EncodeSecret(secret))
+	_, err = DecodeSecret("not base32!")
+	assert.ErrorIs(t, err, ErrInvalidSecret)
+
+	assert.ErrorIs(t, Key{Secret: secret, Algorithm: "MD5", Digits: 6, Period: time.Second}.Validate(), ErrInvalidAlgorithm)
+	assert.ErrorIs(t, Key{Secret: secret, Algorithm: SHA1, Digits: 7, Period: time.Second}.Validate(), ErrInvalidDigits)
+}
+
+func TestKey_URI(t *testing.T) {
+	k := NewKey([]byte("1234567890"))
+	u, err := url.Parse(k.URI("GophKeeper", "user"))
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", u.Scheme)
+	assert.Equal(t, "totp", u.Host)
+	assert.Equal(t, "/GophKeeper:user", u.Path)
+	assert.Equal(t, "GEZDGNBVGY3TQOJQ", u.Query().Get("secret"))
+	assert.Equal(t, "6", u.Query().Get("digits"))
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	require.NoError(t, err)
+	assert.Len(t, codes, 10)
+	for _, c := range codes {
+		assert.Len(t, c, 11)
+		assert.True(t, IsRecoveryCode(c))
+		assert.Equal(t, HashRecoveryCode(c), HashRecoveryCode(" "+c+" "))
+	}
+	assert.NotEqual(t, codes[0], codes[1])
+	assert.False(t, IsRecoveryCode("123456"))
+}
+
+func ExampleKey_Code() {
+	k := NewKey([]byte("12345678901234567890"))
+	fmt.Println(k.Code(time.Unix(59, 0)))
+	// Output:
+	// 287082
+}
+
+func TestParseURI(t *testing.T) {
+	key, issuer, account, err := ParseURI(" otpauth://totp/ACME%20Co:john@example.com?secret=GEZDGNBVGY3TQOJQ&algorithm=SHA256&digits=8&period=60\n")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("1234567890"), key.Secret)
+	assert.Equal(t, SHA256, key.Algorithm)
+	assert.Equal(t, 8, key.Digits)
+	assert.Equal(t, time.Minute, key.Period)
+	assert.Equal(t, "ACME Co", issuer)
+	assert.Equal(t, "john@example.com", account)
+
+	key, issuer, account, err = ParseURI("otpauth://totp/user?secret=GEZDGNBVGY3TQOJQ&issuer=GitHub")
+	require.NoError(t, err)
+	assert.Equal(t, NewKey([]byte("1234567890")), key)
+	assert.Equal(t, "GitHub", issuer)
+	assert.Equal(t, "user", account)
+
+	roundTrip, _, _, err := ParseURI(key.URI("GophKeeper", "user"))
+	require.NoError(t, err)
+	assert.Equal(t, key, roundTrip)
+
+	for _, uri := range []string{
+		"https://example.com",
+		"otpauth://hotp/user?secret=GEZDGNBVGY3TQOJQ&counter=1",
+		"otpauth://totp/user",
+		"otpauth://totp/user?secret=GEZDGNBVGY3TQOJQ&algorithm=MD5",
+		"otpauth://totp/user?secret=GEZDGNBVGY3TQOJQ&digits=7",
+		"otpauth://totp/user?secret=GEZDGNBVGY3TQOJQ&period=0",
+	} {
+		_, _, _, err = ParseURI(uri)
+		assert.Error(t, err, uri)
+	}
+}
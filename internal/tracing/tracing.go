@@ -0,0 +1,89 @@
+// Package tracing configures OpenTelemetry tracing for the client and the server.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Exporters
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+)
+
+// instrumentation - name of the tracer
+const instrumentation = "gophkeeper"
+
+// Module errors
+var (
+	ErrUnknownExporter = errors.New("unknown trace exporter")
+)
+
+// Setup installs the global tracer provider and the W3C trace context propagator.
+// The stdout exporter writes spans to stderr, so they do not mix with the output of commands.
+// The file exporter appends spans as json to path. The returned function flushes and stops the provider.
+func Setup(exporter string, path string, service string) (func(ctx context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	var w io.Writer
+	var file *os.File
+	switch exporter {
+	case "", ExporterNone:
+		return func(ctx context.Context) error { return nil }, nil
+	case ExporterStdout:
+		w = os.Stderr
+	case ExporterFile:
+		var err error
+		file, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			return nil, err
+		}
+		w = file
+	default:
+		return nil, ErrUnknownExporter
+	}
+	exp, err := stdouttrace.New(stdouttrace.WithWriter(w))
+	if err != nil {
+		return nil, err
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(service))),
+	)
+	otel.SetTracerProvider(tp)
+	return func(ctx context.Context) error {
+		err := tp.Shutdown(ctx)
+		if file != nil {
+			if cErr := file.Close(); err == nil {
+				err = cErr
+			}
+		}
+		return err
+	}, nil
+}
+
+// Start creates a span named name as a child of the span in ctx.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentation).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err on the span and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,38 @@
+package tracing
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+func TestSetup_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.json")
+	shutdown, err := Setup(ExporterFile, path, "test")
+	require.NoError(t, err)
+
+	ctx, parent := Start(context.Background(), "cli get")
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	_, child := Start(ctx, "sql select")
+	End(child, nil)
+	End(parent, nil)
+	require.NoError(t, shutdown(context.Background()))
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"Name":"cli get"`)
+	assert.Contains(t, string(b), `"Name":"sql select"`)
+	assert.Contains(t, carrier.Get("traceparent"), parent.SpanContext().TraceID().String())
+}
+
+func TestSetup_Unknown(t *testing.T) {
+	_, err := Setup("jaeger", "", "test")
+	assert.ErrorIs(t, err, ErrUnknownExporter)
+}
@@ -4,15 +4,21 @@ package utils
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/rand"
 	"encoding/base64"
+	"errors"
 
 	"log"
 )
 
-var nonce = []byte{156, 123, 210, 167, 214, 230, 92, 233, 232, 233, 172, 192}
+// legacyNonce - nonce of the texts encrypted before every text got its own nonce, they are still decrypted
+var legacyNonce = []byte{156, 123, 210, 167, 214, 230, 92, 233, 232, 233, 172, 192}
 
-// Encrypt - use the AES cipher in Galois/Counter Mode (GCM) to perform authenticated encryption.
-func Encrypt(text string, key []byte) string {
+// ErrDecrypt - the text is not encrypted with the key
+var ErrDecrypt = errors.New("message authentication failed")
+
+// newGCM - AES-GCM with the key
+func newGCM(key []byte) cipher.AEAD {
 	// Generate a new AES cipher block using the secret key
 	block, err := aes.NewCipher(key)
 	if err != nil {
@@ -24,32 +30,47 @@ func Encrypt(text string, key []byte) string {
 	if err != nil {
 		log.Fatalf("GCM %v", err)
 	}
+	return aesGCM
+}
+
+// Encrypt - use the AES cipher in Galois/Counter Mode (GCM) to perform authenticated encryption.
+// Every text is encrypted with a new random nonce stored before the ciphertext.
+func Encrypt(text string, key []byte) string {
+	aesGCM := newGCM(key)
+	nonce := make([]byte, aesGCM.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		log.Fatalf("nonce %v", err)
+	}
 	// Encrypt the plaintext using AES-GCM
-	ciphertext := aesGCM.Seal(nil, nonce, []byte(text), nil)
+	ciphertext := aesGCM.Seal(nonce, nonce, []byte(text), nil)
 	return base64.RawStdEncoding.EncodeToString(ciphertext)
 }
 
-// Decrypt - use the AES cipher in Galois/Counter Mode (GCM) to perform authenticated decryption.
-func Decrypt(text string, key []byte) string {
-	// Generate a new AES cipher block using the secret key
+// Open - authenticated decryption of Encrypt, ErrDecrypt is returned when the text is not encrypted with the key.
+func Open(text string, key []byte) (string, error) {
 	decodedCiphertext, err := base64.RawStdEncoding.DecodeString(text)
 	if err != nil {
-		log.Fatalf("decrypt 1 %v", err)
+		return "", err
 	}
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		log.Fatal(err)
+	aesGCM := newGCM(key)
+	if len(decodedCiphertext) >= aesGCM.NonceSize()+aesGCM.Overhead() {
+		nonce, ciphertext := decodedCiphertext[:aesGCM.NonceSize()], decodedCiphertext[aesGCM.NonceSize():]
+		if decrypted, err := aesGCM.Open(nil, nonce, ciphertext, nil); err == nil {
+			return string(decrypted), nil
+		}
 	}
-	// Create a new GCM (Galois/Counter Mode) cipher using the AES block
-	// GCM provides authenticated encryption
-	aesGCM, err := cipher.NewGCM(block)
+	decrypted, err := aesGCM.Open(nil, legacyNonce, decodedCiphertext, nil)
 	if err != nil {
-		log.Fatalf("decrypt 2 %v", err)
+		return "", ErrDecrypt
 	}
-	// Decrypt the ciphertext using AES-GCM
-	decrypted, err := aesGCM.Open(nil, nonce, decodedCiphertext, nil)
+	return string(decrypted), nil
+}
+
+// Decrypt - use the AES cipher in Galois/Counter Mode (GCM) to perform authenticated decryption.
+func Decrypt(text string, key []byte) string {
+	decrypted, err := Open(text, key)
 	if err != nil {
-		log.Printf("decrypt 3 %v", err)
+		log.Printf("decrypt %v", err)
 	}
-	return string(decrypted)
+	return decrypted
 }
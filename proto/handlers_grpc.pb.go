@@ -21,13 +21,31 @@ import (
 const _ = grpc.SupportPackageIsVersion7
 
 const (
-	Gophkeeper_Login_FullMethodName      = "/gophkeeper.Gophkeeper/Login"
-	Gophkeeper_Auth_FullMethodName       = "/gophkeeper.Gophkeeper/Auth"
-	Gophkeeper_AddData_FullMethodName    = "/gophkeeper.Gophkeeper/AddData"
-	Gophkeeper_GetData_FullMethodName    = "/gophkeeper.Gophkeeper/GetData"
-	Gophkeeper_Sync_FullMethodName       = "/gophkeeper.Gophkeeper/Sync"
-	Gophkeeper_ClientSync_FullMethodName = "/gophkeeper.Gophkeeper/ClientSync"
-	Gophkeeper_DelData_FullMethodName    = "/gophkeeper.Gophkeeper/DelData"
+	Gophkeeper_Login_FullMethodName                   = "/gophkeeper.Gophkeeper/Login"
+	Gophkeeper_Auth_FullMethodName                    = "/gophkeeper.Gophkeeper/Auth"
+	Gophkeeper_AddData_FullMethodName                 = "/gophkeeper.Gophkeeper/AddData"
+	Gophkeeper_GetData_FullMethodName                 = "/gophkeeper.Gophkeeper/GetData"
+	Gophkeeper_Sync_FullMethodName                    = "/gophkeeper.Gophkeeper/Sync"
+	Gophkeeper_ClientSync_FullMethodName              = "/gophkeeper.Gophkeeper/ClientSync"
+	Gophkeeper_DelData_FullMethodName                 = "/gophkeeper.Gophkeeper/DelData"
+	Gophkeeper_EnrollTOTP_FullMethodName              = "/gophkeeper.Gophkeeper/EnrollTOTP"
+	Gophkeeper_ConfirmTOTP_FullMethodName             = "/gophkeeper.Gophkeeper/ConfirmTOTP"
+	Gophkeeper_DisableTOTP_FullMethodName             = "/gophkeeper.Gophkeeper/DisableTOTP"
+	Gophkeeper_RegenerateRecoveryCodes_FullMethodName = "/gophkeeper.Gophkeeper/RegenerateRecoveryCodes"
+	Gophkeeper_ChangePassword_FullMethodName          = "/gophkeeper.Gophkeeper/ChangePassword"
+	Gophkeeper_DeleteAccount_FullMethodName           = "/gophkeeper.Gophkeeper/DeleteAccount"
+	Gophkeeper_ExportAccount_FullMethodName           = "/gophkeeper.Gophkeeper/ExportAccount"
+	Gophkeeper_ListVersions_FullMethodName            = "/gophkeeper.Gophkeeper/ListVersions"
+	Gophkeeper_GetVersion_FullMethodName              = "/gophkeeper.Gophkeeper/GetVersion"
+	Gophkeeper_SetHistoryRetention_FullMethodName     = "/gophkeeper.Gophkeeper/SetHistoryRetention"
+	Gophkeeper_ListTrash_FullMethodName               = "/gophkeeper.Gophkeeper/ListTrash"
+	Gophkeeper_RestoreData_FullMethodName             = "/gophkeeper.Gophkeeper/RestoreData"
+	Gophkeeper_EmptyTrash_FullMethodName              = "/gophkeeper.Gophkeeper/EmptyTrash"
+	Gophkeeper_UpdateData_FullMethodName              = "/gophkeeper.Gophkeeper/UpdateData"
+	Gophkeeper_ListPasswordPolicies_FullMethodName    = "/gophkeeper.Gophkeeper/ListPasswordPolicies"
+	Gophkeeper_SetPasswordPolicy_FullMethodName       = "/gophkeeper.Gophkeeper/SetPasswordPolicy"
+	Gophkeeper_ListDevices_FullMethodName             = "/gophkeeper.Gophkeeper/ListDevices"
+	Gophkeeper_RemoveDevice_FullMethodName            = "/gophkeeper.Gophkeeper/RemoveDevice"
 )
 
 // GophkeeperClient is the client API for Gophkeeper service.
@@ -41,6 +59,25 @@ type GophkeeperClient interface {
 	Sync(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*SynchronizationResponse, error)
 	ClientSync(ctx context.Context, in *ClientSyncRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
 	DelData(ctx context.Context, in *GetDataRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	EnrollTOTP(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*EnrollTOTPResponse, error)
+	ConfirmTOTP(ctx context.Context, in *TOTPCodeRequest, opts ...grpc.CallOption) (*RecoveryCodesResponse, error)
+	DisableTOTP(ctx context.Context, in *TOTPCodeRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	RegenerateRecoveryCodes(ctx context.Context, in *TOTPCodeRequest, opts ...grpc.CallOption) (*RecoveryCodesResponse, error)
+	ChangePassword(ctx context.Context, in *ChangePasswordRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	DeleteAccount(ctx context.Context, in *DeleteAccountRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// every record of the user including deleted ones
+	ExportAccount(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (Gophkeeper_ExportAccountClient, error)
+	ListVersions(ctx context.Context, in *GetDataRequest, opts ...grpc.CallOption) (*ListVersionsResponse, error)
+	GetVersion(ctx context.Context, in *GetVersionRequest, opts ...grpc.CallOption) (*GetDataResponse, error)
+	SetHistoryRetention(ctx context.Context, in *HistoryRetentionRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	ListTrash(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ListTrashResponse, error)
+	RestoreData(ctx context.Context, in *GetDataRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	EmptyTrash(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*EmptyTrashResponse, error)
+	UpdateData(ctx context.Context, in *UpdateDataRequest, opts ...grpc.CallOption) (*UpdateDataResponse, error)
+	ListPasswordPolicies(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*PasswordPoliciesResponse, error)
+	SetPasswordPolicy(ctx context.Context, in *FolderPasswordPolicy, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	ListDevices(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ListDevicesResponse, error)
+	RemoveDevice(ctx context.Context, in *DeviceRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
 }
 
 type gophkeeperClient struct {
@@ -114,6 +151,191 @@ func (c *gophkeeperClient) DelData(ctx context.Context, in *GetDataRequest, opts
 	return out, nil
 }
 
+func (c *gophkeeperClient) EnrollTOTP(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*EnrollTOTPResponse, error) {
+	out := new(EnrollTOTPResponse)
+	err := c.cc.Invoke(ctx, Gophkeeper_EnrollTOTP_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophkeeperClient) ConfirmTOTP(ctx context.Context, in *TOTPCodeRequest, opts ...grpc.CallOption) (*RecoveryCodesResponse, error) {
+	out := new(RecoveryCodesResponse)
+	err := c.cc.Invoke(ctx, Gophkeeper_ConfirmTOTP_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophkeeperClient) DisableTOTP(ctx context.Context, in *TOTPCodeRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Gophkeeper_DisableTOTP_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophkeeperClient) RegenerateRecoveryCodes(ctx context.Context, in *TOTPCodeRequest, opts ...grpc.CallOption) (*RecoveryCodesResponse, error) {
+	out := new(RecoveryCodesResponse)
+	err := c.cc.Invoke(ctx, Gophkeeper_RegenerateRecoveryCodes_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophkeeperClient) ChangePassword(ctx context.Context, in *ChangePasswordRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Gophkeeper_ChangePassword_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophkeeperClient) DeleteAccount(ctx context.Context, in *DeleteAccountRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Gophkeeper_DeleteAccount_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophkeeperClient) ExportAccount(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (Gophkeeper_ExportAccountClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Gophkeeper_ServiceDesc.Streams[0], Gophkeeper_ExportAccount_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &gophkeeperExportAccountClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Gophkeeper_ExportAccountClient interface {
+	Recv() (*Data, error)
+	grpc.ClientStream
+}
+
+type gophkeeperExportAccountClient struct {
+	grpc.ClientStream
+}
+
+func (x *gophkeeperExportAccountClient) Recv() (*Data, error) {
+	m := new(Data)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *gophkeeperClient) ListVersions(ctx context.Context, in *GetDataRequest, opts ...grpc.CallOption) (*ListVersionsResponse, error) {
+	out := new(ListVersionsResponse)
+	err := c.cc.Invoke(ctx, Gophkeeper_ListVersions_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophkeeperClient) GetVersion(ctx context.Context, in *GetVersionRequest, opts ...grpc.CallOption) (*GetDataResponse, error) {
+	out := new(GetDataResponse)
+	err := c.cc.Invoke(ctx, Gophkeeper_GetVersion_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophkeeperClient) SetHistoryRetention(ctx context.Context, in *HistoryRetentionRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Gophkeeper_SetHistoryRetention_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophkeeperClient) ListTrash(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ListTrashResponse, error) {
+	out := new(ListTrashResponse)
+	err := c.cc.Invoke(ctx, Gophkeeper_ListTrash_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophkeeperClient) RestoreData(ctx context.Context, in *GetDataRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Gophkeeper_RestoreData_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophkeeperClient) EmptyTrash(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*EmptyTrashResponse, error) {
+	out := new(EmptyTrashResponse)
+	err := c.cc.Invoke(ctx, Gophkeeper_EmptyTrash_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophkeeperClient) UpdateData(ctx context.Context, in *UpdateDataRequest, opts ...grpc.CallOption) (*UpdateDataResponse, error) {
+	out := new(UpdateDataResponse)
+	err := c.cc.Invoke(ctx, Gophkeeper_UpdateData_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophkeeperClient) ListPasswordPolicies(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*PasswordPoliciesResponse, error) {
+	out := new(PasswordPoliciesResponse)
+	err := c.cc.Invoke(ctx, Gophkeeper_ListPasswordPolicies_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophkeeperClient) SetPasswordPolicy(ctx context.Context, in *FolderPasswordPolicy, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Gophkeeper_SetPasswordPolicy_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophkeeperClient) ListDevices(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ListDevicesResponse, error) {
+	out := new(ListDevicesResponse)
+	err := c.cc.Invoke(ctx, Gophkeeper_ListDevices_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophkeeperClient) RemoveDevice(ctx context.Context, in *DeviceRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Gophkeeper_RemoveDevice_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // GophkeeperServer is the server API for Gophkeeper service.
 // All implementations must embed UnimplementedGophkeeperServer
 // for forward compatibility
@@ -125,6 +347,25 @@ type GophkeeperServer interface {
 	Sync(context.Context, *emptypb.Empty) (*SynchronizationResponse, error)
 	ClientSync(context.Context, *ClientSyncRequest) (*emptypb.Empty, error)
 	DelData(context.Context, *GetDataRequest) (*emptypb.Empty, error)
+	EnrollTOTP(context.Context, *emptypb.Empty) (*EnrollTOTPResponse, error)
+	ConfirmTOTP(context.Context, *TOTPCodeRequest) (*RecoveryCodesResponse, error)
+	DisableTOTP(context.Context, *TOTPCodeRequest) (*emptypb.Empty, error)
+	RegenerateRecoveryCodes(context.Context, *TOTPCodeRequest) (*RecoveryCodesResponse, error)
+	ChangePassword(context.Context, *ChangePasswordRequest) (*emptypb.Empty, error)
+	DeleteAccount(context.Context, *DeleteAccountRequest) (*emptypb.Empty, error)
+	// every record of the user including deleted ones
+	ExportAccount(*emptypb.Empty, Gophkeeper_ExportAccountServer) error
+	ListVersions(context.Context, *GetDataRequest) (*ListVersionsResponse, error)
+	GetVersion(context.Context, *GetVersionRequest) (*GetDataResponse, error)
+	SetHistoryRetention(context.Context, *HistoryRetentionRequest) (*emptypb.Empty, error)
+	ListTrash(context.Context, *emptypb.Empty) (*ListTrashResponse, error)
+	RestoreData(context.Context, *GetDataRequest) (*emptypb.Empty, error)
+	EmptyTrash(context.Context, *emptypb.Empty) (*EmptyTrashResponse, error)
+	UpdateData(context.Context, *UpdateDataRequest) (*UpdateDataResponse, error)
+	ListPasswordPolicies(context.Context, *emptypb.Empty) (*PasswordPoliciesResponse, error)
+	SetPasswordPolicy(context.Context, *FolderPasswordPolicy) (*emptypb.Empty, error)
+	ListDevices(context.Context, *emptypb.Empty) (*ListDevicesResponse, error)
+	RemoveDevice(context.Context, *DeviceRequest) (*emptypb.Empty, error)
 	mustEmbedUnimplementedGophkeeperServer()
 }
 
@@ -153,6 +394,60 @@ func (UnimplementedGophkeeperServer) ClientSync(context.Context, *ClientSyncRequ
 func (UnimplementedGophkeeperServer) DelData(context.Context, *GetDataRequest) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DelData not implemented")
 }
+func (UnimplementedGophkeeperServer) EnrollTOTP(context.Context, *emptypb.Empty) (*EnrollTOTPResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EnrollTOTP not implemented")
+}
+func (UnimplementedGophkeeperServer) ConfirmTOTP(context.Context, *TOTPCodeRequest) (*RecoveryCodesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ConfirmTOTP not implemented")
+}
+func (UnimplementedGophkeeperServer) DisableTOTP(context.Context, *TOTPCodeRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DisableTOTP not implemented")
+}
+func (UnimplementedGophkeeperServer) RegenerateRecoveryCodes(context.Context, *TOTPCodeRequest) (*RecoveryCodesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RegenerateRecoveryCodes not implemented")
+}
+func (UnimplementedGophkeeperServer) ChangePassword(context.Context, *ChangePasswordRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ChangePassword not implemented")
+}
+func (UnimplementedGophkeeperServer) DeleteAccount(context.Context, *DeleteAccountRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteAccount not implemented")
+}
+func (UnimplementedGophkeeperServer) ExportAccount(*emptypb.Empty, Gophkeeper_ExportAccountServer) error {
+	return status.Errorf(codes.Unimplemented, "method ExportAccount not implemented")
+}
+func (UnimplementedGophkeeperServer) ListVersions(context.Context, *GetDataRequest) (*ListVersionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListVersions not implemented")
+}
+func (UnimplementedGophkeeperServer) GetVersion(context.Context, *GetVersionRequest) (*GetDataResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetVersion not implemented")
+}
+func (UnimplementedGophkeeperServer) SetHistoryRetention(context.Context, *HistoryRetentionRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetHistoryRetention not implemented")
+}
+func (UnimplementedGophkeeperServer) ListTrash(context.Context, *emptypb.Empty) (*ListTrashResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListTrash not implemented")
+}
+func (UnimplementedGophkeeperServer) RestoreData(context.Context, *GetDataRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestoreData not implemented")
+}
+func (UnimplementedGophkeeperServer) EmptyTrash(context.Context, *emptypb.Empty) (*EmptyTrashResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EmptyTrash not implemented")
+}
+func (UnimplementedGophkeeperServer) UpdateData(context.Context, *UpdateDataRequest) (*UpdateDataResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateData not implemented")
+}
+func (UnimplementedGophkeeperServer) ListPasswordPolicies(context.Context, *emptypb.Empty) (*PasswordPoliciesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListPasswordPolicies not implemented")
+}
+func (UnimplementedGophkeeperServer) SetPasswordPolicy(context.Context, *FolderPasswordPolicy) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetPasswordPolicy not implemented")
+}
+func (UnimplementedGophkeeperServer) ListDevices(context.Context, *emptypb.Empty) (*ListDevicesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDevices not implemented")
+}
+func (UnimplementedGophkeeperServer) RemoveDevice(context.Context, *DeviceRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveDevice not implemented")
+}
 func (UnimplementedGophkeeperServer) mustEmbedUnimplementedGophkeeperServer() {}
 
 // UnsafeGophkeeperServer may be embedded to opt out of forward compatibility for this service.
@@ -292,6 +587,333 @@ func _Gophkeeper_DelData_Handler(srv interface{}, ctx context.Context, dec func(
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Gophkeeper_EnrollTOTP_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophkeeperServer).EnrollTOTP(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophkeeper_EnrollTOTP_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophkeeperServer).EnrollTOTP(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophkeeper_ConfirmTOTP_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TOTPCodeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophkeeperServer).ConfirmTOTP(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophkeeper_ConfirmTOTP_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophkeeperServer).ConfirmTOTP(ctx, req.(*TOTPCodeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophkeeper_DisableTOTP_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TOTPCodeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophkeeperServer).DisableTOTP(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophkeeper_DisableTOTP_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophkeeperServer).DisableTOTP(ctx, req.(*TOTPCodeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophkeeper_RegenerateRecoveryCodes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TOTPCodeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophkeeperServer).RegenerateRecoveryCodes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophkeeper_RegenerateRecoveryCodes_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophkeeperServer).RegenerateRecoveryCodes(ctx, req.(*TOTPCodeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophkeeper_ChangePassword_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChangePasswordRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophkeeperServer).ChangePassword(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophkeeper_ChangePassword_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophkeeperServer).ChangePassword(ctx, req.(*ChangePasswordRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophkeeper_DeleteAccount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteAccountRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophkeeperServer).DeleteAccount(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophkeeper_DeleteAccount_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophkeeperServer).DeleteAccount(ctx, req.(*DeleteAccountRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophkeeper_ExportAccount_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(emptypb.Empty)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(GophkeeperServer).ExportAccount(m, &gophkeeperExportAccountServer{stream})
+}
+
+type Gophkeeper_ExportAccountServer interface {
+	Send(*Data) error
+	grpc.ServerStream
+}
+
+type gophkeeperExportAccountServer struct {
+	grpc.ServerStream
+}
+
+func (x *gophkeeperExportAccountServer) Send(m *Data) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Gophkeeper_ListVersions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetDataRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophkeeperServer).ListVersions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophkeeper_ListVersions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophkeeperServer).ListVersions(ctx, req.(*GetDataRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophkeeper_GetVersion_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetVersionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophkeeperServer).GetVersion(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophkeeper_GetVersion_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophkeeperServer).GetVersion(ctx, req.(*GetVersionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophkeeper_SetHistoryRetention_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HistoryRetentionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophkeeperServer).SetHistoryRetention(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophkeeper_SetHistoryRetention_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophkeeperServer).SetHistoryRetention(ctx, req.(*HistoryRetentionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophkeeper_ListTrash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophkeeperServer).ListTrash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophkeeper_ListTrash_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophkeeperServer).ListTrash(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophkeeper_RestoreData_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetDataRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophkeeperServer).RestoreData(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophkeeper_RestoreData_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophkeeperServer).RestoreData(ctx, req.(*GetDataRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophkeeper_EmptyTrash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophkeeperServer).EmptyTrash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophkeeper_EmptyTrash_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophkeeperServer).EmptyTrash(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophkeeper_UpdateData_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateDataRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophkeeperServer).UpdateData(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophkeeper_UpdateData_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophkeeperServer).UpdateData(ctx, req.(*UpdateDataRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophkeeper_ListPasswordPolicies_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophkeeperServer).ListPasswordPolicies(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophkeeper_ListPasswordPolicies_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophkeeperServer).ListPasswordPolicies(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophkeeper_SetPasswordPolicy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FolderPasswordPolicy)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophkeeperServer).SetPasswordPolicy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophkeeper_SetPasswordPolicy_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophkeeperServer).SetPasswordPolicy(ctx, req.(*FolderPasswordPolicy))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophkeeper_ListDevices_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophkeeperServer).ListDevices(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophkeeper_ListDevices_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophkeeperServer).ListDevices(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophkeeper_RemoveDevice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeviceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophkeeperServer).RemoveDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophkeeper_RemoveDevice_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophkeeperServer).RemoveDevice(ctx, req.(*DeviceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Gophkeeper_ServiceDesc is the grpc.ServiceDesc for Gophkeeper service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -327,7 +949,81 @@ var Gophkeeper_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "DelData",
 			Handler:    _Gophkeeper_DelData_Handler,
 		},
+		{
+			MethodName: "EnrollTOTP",
+			Handler:    _Gophkeeper_EnrollTOTP_Handler,
+		},
+		{
+			MethodName: "ConfirmTOTP",
+			Handler:    _Gophkeeper_ConfirmTOTP_Handler,
+		},
+		{
+			MethodName: "DisableTOTP",
+			Handler:    _Gophkeeper_DisableTOTP_Handler,
+		},
+		{
+			MethodName: "RegenerateRecoveryCodes",
+			Handler:    _Gophkeeper_RegenerateRecoveryCodes_Handler,
+		},
+		{
+			MethodName: "ChangePassword",
+			Handler:    _Gophkeeper_ChangePassword_Handler,
+		},
+		{
+			MethodName: "DeleteAccount",
+			Handler:    _Gophkeeper_DeleteAccount_Handler,
+		},
+		{
+			MethodName: "ListVersions",
+			Handler:    _Gophkeeper_ListVersions_Handler,
+		},
+		{
+			MethodName: "GetVersion",
+			Handler:    _Gophkeeper_GetVersion_Handler,
+		},
+		{
+			MethodName: "SetHistoryRetention",
+			Handler:    _Gophkeeper_SetHistoryRetention_Handler,
+		},
+		{
+			MethodName: "ListTrash",
+			Handler:    _Gophkeeper_ListTrash_Handler,
+		},
+		{
+			MethodName: "RestoreData",
+			Handler:    _Gophkeeper_RestoreData_Handler,
+		},
+		{
+			MethodName: "EmptyTrash",
+			Handler:    _Gophkeeper_EmptyTrash_Handler,
+		},
+		{
+			MethodName: "UpdateData",
+			Handler:    _Gophkeeper_UpdateData_Handler,
+		},
+		{
+			MethodName: "ListPasswordPolicies",
+			Handler:    _Gophkeeper_ListPasswordPolicies_Handler,
+		},
+		{
+			MethodName: "SetPasswordPolicy",
+			Handler:    _Gophkeeper_SetPasswordPolicy_Handler,
+		},
+		{
+			MethodName: "ListDevices",
+			Handler:    _Gophkeeper_ListDevices_Handler,
+		},
+		{
+			MethodName: "RemoveDevice",
+			Handler:    _Gophkeeper_RemoveDevice_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ExportAccount",
+			Handler:       _Gophkeeper_ExportAccount_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/handlers.proto",
 }
@@ -0,0 +1,12 @@
+package proto
+
+import "log/slog"
+
+// LogValue - hides Data and MetaInfo from logs
+func (x *Data) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("data_id", x.GetDataId()),
+		slog.Time("changed_at", x.GetChangedAt().AsTime()),
+		slog.Bool("deleted", x.GetDeleted()),
+	)
+}
@@ -0,0 +1,8 @@
+package proto
+
+import _ "embed"
+
+// OpenAPI - OpenAPI document of the HTTP gateway generated by protoc-gen-openapiv2
+//
+//go:embed handlers.swagger.json
+var OpenAPI []byte
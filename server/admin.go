@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"gophkeeper/internal/datamodels"
+	"gophkeeper/internal/storage"
+)
+
+// adminUsage - commands of the admin mode
+const adminUsage = `usage: gophkeeper-server admin [-d dsn] <command> [arguments]
+
+commands:
+  users                                 list users with record counts and storage usage
+  show <login>                          show a single user
+  disable <login>                       forbid logins and end the sessions of the user
+  enable <login>                        allow logins again
+  logout <login>                        end every session of the user
+  unlock <login>                        reset failed logins and the lockout of the user
+  purge-tombstones [-older-than 720h] [login]
+                                        delete records marked as deleted, of every user when login is omitted`
+
+// errUsage - admin command called with wrong arguments
+var errUsage = errors.New(adminUsage)
+
+// runAdmin executes an operator command against the server database.
+func runAdmin(args []string) error {
+	fs := flag.NewFlagSet("admin", flag.ExitOnError)
+	fs.Usage = func() { fmt.Fprintln(fs.Output(), adminUsage) }
+	dsn := fs.String("d", "postgresql://localhost:5432/shvm", "database connection string")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 {
+		return errUsage
+	}
+	db, err := storage.NewDBStorage(*dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return admin(context.Background(), db, os.Stdout, fs.Args())
+}
+
+// admin - runs a single admin command, args starts with the command name
+func admin(ctx context.Context, db storage.ServerStorage, out io.Writer, args []string) error {
+	cmd, args := args[0], args[1:]
+	login := func() (string, error) {
+		if len(args) != 1 {
+			return "", errUsage
+		}
+		return args[0], nil
+	}
+	switch cmd {
+	case "users":
+		users, err := db.ListUsers(ctx)
+		if err != nil {
+			return err
+		}
+		printUsers(out, users)
+		return nil
+	case "show":
+		l, err := login()
+		if err != nil {
+			return err
+		}
+		users, err := db.ListUsers(ctx)
+		if err != nil {
+			return err
+		}
+		for _, u := range users {
+			if u.Login == l {
+				printUsers(out, []datamodels.UserStats{u})
+				return nil
+			}
+		}
+		return storage.ErrUserNotFound
+	case "disable", "enable":
+		l, err := login()
+		if err != nil {
+			return err
+		}
+		if err = db.SetDisabled(ctx, l, cmd == "disable"); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "login %s %sd\n", l, cmd)
+		return nil
+	case "logout":
+		l, err := login()
+		if err != nil {
+			return err
+		}
+		if err = db.RevokeSessions(ctx, l); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "sessions of %s revoked\n", l)
+		return nil
+	case "unlock":
+		l, err := login()
+		if err != nil {
+			return err
+		}
+		if err = db.ResetLoginFailures(ctx, l); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "login %s unlocked\n", l)
+		return nil
+	case "purge-tombstones":
+		fs := flag.NewFlagSet("purge-tombstones", flag.ContinueOnError)
+		olderThan := fs.Duration("older-than", 30*24*time.Hour, "minimum age of purged tombstones")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if fs.NArg() > 1 {
+			return errUsage
+		}
+		n, err := db.PurgeTombstones(ctx, fs.Arg(0), *olderThan)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%d tombstones purged\n", n)
+		return nil
+	}
+	return errUsage
+}
+
+// printUsers - writes users as a table
+func printUsers(out io.Writer, users []datamodels.UserStats) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tLOGIN\tSTATUS\t2FA\tFAILED LOGINS\tLOCKED UNTIL\tRECORDS\tDELETED\tBYTES")
+	for _, u := range users {
+		status := "active"
+		if u.Disabled {
+			status = "disabled"
+		}
+		locked := "-"
+		if u.LockedUntil.After(time.Now()) {
+			locked = u.LockedUntil.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%t\t%d\t%s\t%d\t%d\t%d\n", u.ID, u.Login, status, u.TOTP, u.FailedLogins, locked, u.Records, u.Deleted, u.Bytes)
+	}
+	w.Flush()
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"gophkeeper/internal/datamodels"
+	"gophkeeper/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// adminStorage - records the admin operations
+type adminStorage struct {
+	storage.ServerStorage
+	users     []datamodels.UserStats
+	disabled  map[string]bool
+	revoked   []string
+	unlocked  []string
+	purged    string
+	olderThan time.Duration
+}
+
+func (s *adminStorage) ListUsers(ctx context.Context) ([]datamodels.UserStats, error) {
+	return s.users, nil
+}
+
+func (s *adminStorage) SetDisabled(ctx context.Context, login string, disabled bool) error {
+	s.disabled[login] = disabled
+	return nil
+}
+
+func (s *adminStorage) RevokeSessions(ctx context.Context, login string) error {
+	s.revoked = append(s.revoked, login)
+	return nil
+}
+
+func (s *adminStorage) ResetLoginFailures(ctx context.Context, login string) error {
+	s.unlocked = append(s.unlocked, login)
+	return nil
+}
+
+func (s *adminStorage) PurgeTombstones(ctx context.Context, login string, olderThan time.Duration) (int64, error) {
+	s.purged, s.olderThan = login, olderThan
+	return 3, nil
+}
+
+func TestAdmin(t *testing.T) {
+	db := &adminStorage{
+		users: []datamodels.UserStats{
+			{ID: 1, Login: "alice", Records: 3, Deleted: 1, Bytes: 120},
+			{ID: 2, Login: "bob", Disabled: true},
+		},
+		disabled: make(map[string]bool),
+	}
+	ctx := context.Background()
+	var out bytes.Buffer
+
+	require.NoError(t, admin(ctx, db, &out, []string{"users"}))
+	assert.Contains(t, out.String(), "alice")
+	assert.Contains(t, out.String(), "disabled")
+
+	out.Reset()
+	require.NoError(t, admin(ctx, db, &out, []string{"show", "alice"}))
+	assert.Contains(t, out.String(), "120")
+	assert.NotContains(t, out.String(), "bob")
+	assert.ErrorIs(t, admin(ctx, db, &out, []string{"show", "carol"}), storage.ErrUserNotFound)
+
+	require.NoError(t, admin(ctx, db, &out, []string{"disable", "alice"}))
+	require.NoError(t, admin(ctx, db, &out, []string{"enable", "bob"}))
+	assert.Equal(t, map[string]bool{"alice": true, "bob": false}, db.disabled)
+
+	require.NoError(t, admin(ctx, db, &out, []string{"logout", "alice"}))
+	require.NoError(t, admin(ctx, db, &out, []string{"unlock", "bob"}))
+	assert.Equal(t, []string{"alice"}, db.revoked)
+	assert.Equal(t, []string{"bob"}, db.unlocked)
+
+	out.Reset()
+	require.NoError(t, admin(ctx, db, &out, []string{"purge-tombstones", "-older-than", "24h", "alice"}))
+	assert.Equal(t, "alice", db.purged)
+	assert.Equal(t, 24*time.Hour, db.olderThan)
+	assert.Equal(t, "3 tombstones purged\n", out.String())
+
+	assert.ErrorIs(t, admin(ctx, db, &out, []string{"logout"}), errUsage)
+	assert.ErrorIs(t, admin(ctx, db, &out, []string{"drop"}), errUsage)
+}
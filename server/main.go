@@ -1,26 +1,202 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
 	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"gophkeeper/internal/config"
+	"gophkeeper/internal/gateway"
 	"gophkeeper/internal/grpcfuncs"
+	"gophkeeper/internal/lockout"
+	"gophkeeper/internal/logger"
+	"gophkeeper/internal/metrics"
+	"gophkeeper/internal/ratelimit"
+	"gophkeeper/internal/tracing"
 	pb "gophkeeper/proto"
 
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 )
 
+func parseFlags() config.Server {
+	var cfg config.Server
+	flag.StringVar(&cfg.Address, "a", ":3200", "address to listen on")
+	flag.StringVar(&cfg.DSN, "d", "postgresql://localhost:5432/shvm", "database connection string")
+	flag.DurationVar(&cfg.MaxRequestDuration, "t", 30*time.Second, "maximum duration of a request")
+	flag.DurationVar(&cfg.DrainTimeout, "drain", 15*time.Second, "time given to in-flight requests on shutdown")
+	flag.DurationVar(&cfg.HealthInterval, "health-interval", 5*time.Second, "interval of database health checks")
+	flag.BoolVar(&cfg.Reflection, "reflection", false, "enable gRPC server reflection")
+	flag.StringVar(&cfg.LogLevel, "log-level", "info", "log level: debug, info, warn or error")
+	flag.StringVar(&cfg.LogFormat, "log-format", "text", "log format: text or json")
+	flag.StringVar(&cfg.MetricsAddress, "metrics-address", "", "address of the /metrics http listener, disabled when empty")
+	flag.StringVar(&cfg.TraceExporter, "trace-exporter", tracing.ExporterNone, "trace exporter: none, stdout or file")
+	flag.StringVar(&cfg.TraceFile, "trace-file", "server-traces.json", "file of the file trace exporter")
+	flag.StringVar(&cfg.HTTPAddress, "http-address", "", "address of the REST/JSON gateway, disabled when empty")
+	flag.StringVar(&cfg.TLSCert, "tls-cert", "", "certificate file of the REST/JSON gateway")
+	flag.StringVar(&cfg.TLSKey, "tls-key", "", "key file of the REST/JSON gateway")
+	flag.DurationVar(&cfg.RevocationInterval, "revocation-interval", 5*time.Second, "interval of checks for sessions revoked by admin commands")
+	flag.DurationVar(&cfg.TrashRetention, "trash-retention", 720*time.Hour, "deleted records older than this are purged once every device of the user synchronized")
+	flag.DurationVar(&cfg.DeviceExpiry, "device-expiry", 2160*time.Hour, "devices that did not synchronize for this long stop holding back the purge of deleted records")
+	flag.DurationVar(&cfg.PurgeInterval, "purge-interval", time.Hour, "interval of the purge of deleted records")
+	cfg.LoginPolicy = lockout.NewPolicy()
+	flag.IntVar(&cfg.LoginPolicy.MaxFailures, "max-login-failures", cfg.LoginPolicy.MaxFailures, "failed logins in a row before the lockout")
+	flag.DurationVar(&cfg.LoginPolicy.BaseDelay, "login-backoff", cfg.LoginPolicy.BaseDelay, "delay after the first failed login, doubled after every next one")
+	flag.DurationVar(&cfg.LoginPolicy.Lockout, "lockout", cfg.LoginPolicy.Lockout, "duration of the lockout")
+	cfg.RateLimits = ratelimit.Config{Default: ratelimit.Limit{Rate: 20, Burst: 40}}
+	flag.Func("rate-limit", "requests per second and burst of every user or peer, for example 20:40, 0:0 disables the limit", func(s string) (err error) {
+		cfg.RateLimits.Default, err = ratelimit.ParseLimit(s)
+		return err
+	})
+	flag.Func("method-rate-limits", "limits of single methods, for example ClientSync=1:5,Sync=2:10", func(s string) (err error) {
+		cfg.RateLimits.Methods, err = ratelimit.ParseMethods(s)
+		return err
+	})
+	flag.Func("global-rate-limit", "requests per second and burst of the whole server, disabled by default", func(s string) (err error) {
+		cfg.RateLimits.Global, err = ratelimit.ParseLimit(s)
+		return err
+	})
+	flag.Parse()
+	return cfg
+}
+
 func main() {
-	gophKeeper := grpcfuncs.NewGophKeeperServer()
-	listen, err := net.Listen("tcp", ":3200")
+	if len(os.Args) > 1 && os.Args[1] == "admin" {
+		if err := runAdmin(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	cfg := parseFlags()
+	log := logger.New(os.Stderr, cfg.LogLevel, cfg.LogFormat)
+	slog.SetDefault(log)
+	shutdownTracing, err := tracing.Setup(cfg.TraceExporter, cfg.TraceFile, "gophkeeper-server")
+	if err != nil {
+		log.Error("tracing setup failed", slog.Any("error", err))
+		os.Exit(1)
+	}
+	defer shutdownTracing(context.Background())
+	gophKeeper := grpcfuncs.NewGophKeeperServer(cfg)
+	defer gophKeeper.Close()
+	listen, err := net.Listen("tcp", cfg.Address)
 	if err != nil {
-		log.Fatal(err)
+		log.Error("listen failed", slog.Any("error", err))
+		os.Exit(1)
 	}
 
-	s := grpc.NewServer()
+	m := metrics.New()
+	m.RegisterSessions(gophKeeper.ActiveSessions)
+	m.RegisterDBStats(gophKeeper.DBStats)
+	limiter := ratelimit.NewLimiter(cfg.RateLimits)
+	limiter.OnLimit(m.RateLimited)
+
+	s := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(
+			otelgrpc.UnaryServerInterceptor(),
+			gophKeeper.AccessLogUnaryInterceptor(log),
+			m.UnaryInterceptor(),
+			limiter.UnaryInterceptor(gophKeeper.RateLimitKey),
+			grpcfuncs.TimeoutInterceptor(cfg.MaxRequestDuration),
+		),
+		grpc.ChainStreamInterceptor(
+			otelgrpc.StreamServerInterceptor(),
+			gophKeeper.AccessLogStreamInterceptor(log),
+			m.StreamInterceptor(),
+			limiter.StreamInterceptor(gophKeeper.RateLimitKey),
+			grpcfuncs.TimeoutStreamInterceptor(cfg.MaxRequestDuration),
+		),
+	)
 	pb.RegisterGophkeeperServer(s, &gophKeeper)
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(s, healthServer)
+	if cfg.Reflection {
+		reflection.Register(s)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go gophKeeper.WatchHealth(ctx, healthServer, cfg.HealthInterval)
+	go gophKeeper.WatchRevocations(ctx, cfg.RevocationInterval)
+	go gophKeeper.WatchTrash(ctx, cfg.PurgeInterval, cfg.TrashRetention, cfg.DeviceExpiry)
 
-	if err = s.Serve(listen); err != nil {
-		log.Fatal(err)
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- s.Serve(listen)
+	}()
+	log.Info("server started", slog.String("address", cfg.Address))
+
+	var metricsServer *http.Server
+	if cfg.MetricsAddress != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", m.Handler())
+		metricsServer = &http.Server{Addr: cfg.MetricsAddress, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+		go func() {
+			if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Error("metrics listener failed", slog.Any("error", err))
+			}
+		}()
+		log.Info("metrics listener started", slog.String("address", cfg.MetricsAddress))
+	}
+
+	var gatewayServer *http.Server
+	if cfg.HTTPAddress != "" {
+		if cfg.TLSCert == "" || cfg.TLSKey == "" {
+			log.Error("REST/JSON gateway needs -tls-cert and -tls-key")
+			os.Exit(1)
+		}
+		handler, err := gateway.New(ctx, cfg.Address, gophKeeper.GatewaySecret())
+		if err != nil {
+			log.Error("gateway setup failed", slog.Any("error", err))
+			os.Exit(1)
+		}
+		gatewayServer = &http.Server{Addr: cfg.HTTPAddress, Handler: handler, ReadHeaderTimeout: 5 * time.Second}
+		go func() {
+			if err := gatewayServer.ListenAndServeTLS(cfg.TLSCert, cfg.TLSKey); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Error("gateway listener failed", slog.Any("error", err))
+			}
+		}()
+		log.Info("REST/JSON gateway started", slog.String("address", cfg.HTTPAddress))
+	}
+
+	select {
+	case err = <-serveErr:
+		log.Error("serve failed", slog.Any("error", err))
+		os.Exit(1)
+	case <-ctx.Done():
+	}
+	log.Info("shutting down", slog.Duration("drain_timeout", cfg.DrainTimeout))
+	healthServer.Shutdown()
+	if gatewayServer != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.DrainTimeout)
+		defer cancel()
+		_ = gatewayServer.Shutdown(shutdownCtx)
+	}
+	stopped := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(cfg.DrainTimeout):
+		log.Warn("drain timeout exceeded, closing remaining connections")
+		s.Stop()
+	}
+	if metricsServer != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.DrainTimeout)
+		defer cancel()
+		_ = metricsServer.Shutdown(shutdownCtx)
 	}
 }